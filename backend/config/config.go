@@ -0,0 +1,374 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Config — полная конфигурация бэкенда.
+// Значения берутся в порядке: default-тег → файл (YAML/TOML) → переменные окружения.
+// Теги: env — имя переменной окружения; default — значение по умолчанию;
+// secret — поле маскируется в `config print`.
+type Config struct {
+	App       AppConfig       `yaml:"app" toml:"app"`
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	Database  DatabaseConfig  `yaml:"database" toml:"database"`
+	Auth      AuthConfig      `yaml:"auth" toml:"auth"`
+	Cookie    CookieConfig    `yaml:"refreshCookie" toml:"refreshCookie"`
+	Log       LogConfig       `yaml:"log" toml:"log"`
+	Metrics   MetricsConfig   `yaml:"metrics" toml:"metrics"`
+	Tracing   TracingConfig   `yaml:"tracing" toml:"tracing"`
+	Todo      TodoConfig      `yaml:"todo" toml:"todo"`
+	Security  SecurityConfig  `yaml:"security" toml:"security"`
+	Scheduler SchedulerConfig `yaml:"scheduler" toml:"scheduler"`
+	Mail      MailConfig      `yaml:"mail" toml:"mail"`
+	Password  PasswordConfig  `yaml:"password" toml:"password"`
+	// LoginThrottle — защита входа от перебора паролей.
+	LoginThrottle LoginThrottleConfig `yaml:"loginThrottle" toml:"loginThrottle"`
+}
+
+// Режимы окружения (APP_ENV). В EnvProd включаются строгие проверки безопасности.
+const (
+	EnvDev     = "dev"
+	EnvStaging = "staging"
+	EnvProd    = "prod"
+)
+
+type AppConfig struct {
+	Env string `yaml:"env" toml:"env" env:"APP_ENV" default:"dev"`
+}
+
+// IsProduction сообщает, запущен ли сервис в production-режиме.
+func (c AppConfig) IsProduction() bool {
+	return strings.EqualFold(strings.TrimSpace(c.Env), EnvProd)
+}
+
+type ServerConfig struct {
+	Addr                     string `yaml:"addr" toml:"addr" env:"HTTP_ADDR" default:":8080"`
+	CORSAllowedOrigin        string `yaml:"corsAllowedOrigin" toml:"corsAllowedOrigin" env:"CORS_ALLOWED_ORIGIN" default:"http://localhost:5173"`
+	ReadHeaderTimeoutSeconds int    `yaml:"readHeaderTimeoutSeconds" toml:"readHeaderTimeoutSeconds" env:"HTTP_READ_HEADER_TIMEOUT_SECONDS" default:"5"`
+	ReadTimeoutSeconds       int    `yaml:"readTimeoutSeconds" toml:"readTimeoutSeconds" env:"HTTP_READ_TIMEOUT_SECONDS" default:"15"`
+	WriteTimeoutSeconds      int    `yaml:"writeTimeoutSeconds" toml:"writeTimeoutSeconds" env:"HTTP_WRITE_TIMEOUT_SECONDS" default:"30"`
+	IdleTimeoutSeconds       int    `yaml:"idleTimeoutSeconds" toml:"idleTimeoutSeconds" env:"HTTP_IDLE_TIMEOUT_SECONDS" default:"60"`
+	RequestTimeoutMs         int    `yaml:"requestTimeoutMs" toml:"requestTimeoutMs" env:"REQUEST_TIMEOUT_MS" default:"10000"`
+	ShutdownDrainDelaySecs   int    `yaml:"shutdownDrainDelaySeconds" toml:"shutdownDrainDelaySeconds" env:"SHUTDOWN_DRAIN_DELAY_SECONDS" default:"5"`
+	ShutdownTimeoutSeconds   int    `yaml:"shutdownTimeoutSeconds" toml:"shutdownTimeoutSeconds" env:"SHUTDOWN_TIMEOUT_SECONDS" default:"20"`
+	ReadinessCheckTimeoutMs  int    `yaml:"readinessCheckTimeoutMs" toml:"readinessCheckTimeoutMs" env:"READINESS_CHECK_TIMEOUT_MS" default:"2000"`
+	// TrustedProxies — адреса и подсети (через запятую) прокси перед сервером, которым можно
+	// верить в X-Forwarded-For. Пусто — IP клиента берется из адреса соединения.
+	TrustedProxies string `yaml:"trustedProxies" toml:"trustedProxies" env:"TRUSTED_PROXIES"`
+}
+
+type DatabaseConfig struct {
+	Host           string `yaml:"host" toml:"host" env:"DB_HOST" default:"localhost"`
+	Port           int    `yaml:"port" toml:"port" env:"DB_PORT" default:"5432"`
+	User           string `yaml:"user" toml:"user" env:"DB_USER" default:"postgres"`
+	Password       string `yaml:"password" toml:"password" env:"DB_PASSWORD" secret:"true"`
+	Name           string `yaml:"name" toml:"name" env:"DB_NAME" default:"postgres"`
+	SSLMode        string `yaml:"sslMode" toml:"sslMode" env:"DB_SSLMODE" default:"disable"`
+	AutoMigrate    bool   `yaml:"autoMigrate" toml:"autoMigrate" env:"DB_AUTO_MIGRATE" default:"true"`
+	QueryTimeoutMs int    `yaml:"queryTimeoutMs" toml:"queryTimeoutMs" env:"DB_QUERY_TIMEOUT_MS" default:"5000"`
+}
+
+// AuthConfig — параметры JWT. Ключи подписи берутся из первого заданного источника:
+// JWTKeysDir (каталог с <kid>.key и файлом active, перечитывается по SIGHUP),
+// JWTKeys (список "kid:secret,..."), иначе JWTSecret как единственный ключ.
+type AuthConfig struct {
+	JWTSecret             string `yaml:"jwtSecret" toml:"jwtSecret" env:"JWT_SECRET" default:"dev-secret-change-me" secret:"true"`
+	JWTKeysDir            string `yaml:"jwtKeysDir" toml:"jwtKeysDir" env:"JWT_KEYS_DIR"`
+	JWTKeys               string `yaml:"jwtKeys" toml:"jwtKeys" env:"JWT_KEYS" secret:"true"`
+	JWTActiveKID          string `yaml:"jwtActiveKid" toml:"jwtActiveKid" env:"JWT_ACTIVE_KID"`
+	AccessTokenTTLMinutes int    `yaml:"accessTokenTTLMinutes" toml:"accessTokenTTLMinutes" env:"JWT_ACCESS_TTL_MINUTES" default:"60"`
+	RefreshTokenTTLHours  int    `yaml:"refreshTokenTTLHours" toml:"refreshTokenTTLHours" env:"JWT_REFRESH_TTL_HOURS" default:"168"`
+	Issuer                string `yaml:"issuer" toml:"issuer" env:"JWT_ISSUER" default:"gotodo"`
+	Audience              string `yaml:"audience" toml:"audience" env:"JWT_AUDIENCE" default:"gotodo-api"`
+	LeewaySeconds         int    `yaml:"leewaySeconds" toml:"leewaySeconds" env:"JWT_LEEWAY_SECONDS" default:"30"`
+	// RefreshTokenShortTTLHours — срок refresh-токена для входа без «запомнить меня».
+	RefreshTokenShortTTLHours int `yaml:"refreshTokenShortTTLHours" toml:"refreshTokenShortTTLHours" env:"JWT_REFRESH_SHORT_TTL_HOURS" default:"12"`
+	// RevocationCacheSeconds — сколько кэшируется проверка отзыва access-токена;
+	// столько же максимум отзыв идет до других инстансов. 0 — без кэша.
+	RevocationCacheSeconds int `yaml:"revocationCacheSeconds" toml:"revocationCacheSeconds" env:"JWT_REVOCATION_CACHE_SECONDS" default:"5"`
+	// RefreshGraceSeconds — сколько после ротации повторный refresh старым токеном
+	// (параллельные вкладки) получает ту же новую пару вместо отзыва семьи. 0 — выключено.
+	RefreshGraceSeconds int `yaml:"refreshGraceSeconds" toml:"refreshGraceSeconds" env:"JWT_REFRESH_GRACE_SECONDS" default:"10"`
+	// SessionMaxLifetimeHours — абсолютный срок жизни входа (семьи refresh-сессий) с момента логина;
+	// ротация его не продлевает. 0 — без ограничения.
+	SessionMaxLifetimeHours int `yaml:"sessionMaxLifetimeHours" toml:"sessionMaxLifetimeHours" env:"SESSION_MAX_LIFETIME_HOURS" default:"720"`
+	// SessionIdleTimeoutHours — вход завершается, если refresh не выполнялся дольше этого. 0 — выключено.
+	SessionIdleTimeoutHours int `yaml:"sessionIdleTimeoutHours" toml:"sessionIdleTimeoutHours" env:"SESSION_IDLE_TIMEOUT_HOURS" default:"72"`
+}
+
+type CookieConfig struct {
+	Name     string `yaml:"name" toml:"name" env:"REFRESH_COOKIE_NAME" default:"goTodo_refresh_token"`
+	Domain   string `yaml:"domain" toml:"domain" env:"REFRESH_COOKIE_DOMAIN"`
+	Path     string `yaml:"path" toml:"path" env:"REFRESH_COOKIE_PATH" default:"/api/auth"`
+	Secure   bool   `yaml:"secure" toml:"secure" env:"REFRESH_COOKIE_SECURE" default:"false"`
+	HTTPOnly bool   `yaml:"httpOnly" toml:"httpOnly" env:"REFRESH_COOKIE_HTTPONLY" default:"true"`
+	SameSite string `yaml:"sameSite" toml:"sameSite" env:"REFRESH_COOKIE_SAMESITE" default:"Lax"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level" toml:"level" env:"LOG_LEVEL" default:"info"`
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT" default:"text"`
+}
+
+type MetricsConfig struct {
+	// Addr — отдельный admin-адрес для /metrics; "off" отключает сервер метрик.
+	Addr string `yaml:"addr" toml:"addr" env:"METRICS_ADDR" default:"127.0.0.1:9090"`
+}
+
+type TracingConfig struct {
+	Exporter     string  `yaml:"exporter" toml:"exporter" env:"TRACING_EXPORTER" default:"none"`
+	ServiceName  string  `yaml:"serviceName" toml:"serviceName" env:"TRACING_SERVICE_NAME" default:"gotodo-backend"`
+	OTLPEndpoint string  `yaml:"otlpEndpoint" toml:"otlpEndpoint" env:"TRACING_OTLP_ENDPOINT" default:"localhost:4318"`
+	OTLPInsecure bool    `yaml:"otlpInsecure" toml:"otlpInsecure" env:"TRACING_OTLP_INSECURE" default:"true"`
+	SampleRatio  float64 `yaml:"sampleRatio" toml:"sampleRatio" env:"TRACING_SAMPLE_RATIO" default:"1.0"`
+}
+
+type TodoConfig struct {
+	UndoHistoryLimit int `yaml:"undoHistoryLimit" toml:"undoHistoryLimit" env:"UNDO_HISTORY_LIMIT" default:"20"`
+}
+
+// Каналы уведомлений о событиях безопасности (SECURITY_NOTIFIER).
+const (
+	NotifierNone  = "none"
+	NotifierLog   = "log"
+	NotifierEmail = "email"
+)
+
+type SecurityConfig struct {
+	Notifier                 string `yaml:"notifier" toml:"notifier" env:"SECURITY_NOTIFIER" default:"log"`
+	FailedLoginThreshold     int    `yaml:"failedLoginThreshold" toml:"failedLoginThreshold" env:"SECURITY_FAILED_LOGIN_THRESHOLD" default:"5"`
+	FailedLoginWindowMinutes int    `yaml:"failedLoginWindowMinutes" toml:"failedLoginWindowMinutes" env:"SECURITY_FAILED_LOGIN_WINDOW_MINUTES" default:"15"`
+	// PasswordResetURL — страница фронта со сбросом пароля; токен добавляется параметром token.
+	PasswordResetURL        string `yaml:"passwordResetUrl" toml:"passwordResetUrl" env:"PASSWORD_RESET_URL" default:"http://localhost:5173/reset-password"`
+	PasswordResetTTLMinutes int    `yaml:"passwordResetTTLMinutes" toml:"passwordResetTTLMinutes" env:"PASSWORD_RESET_TTL_MINUTES" default:"30"`
+	// EmailVerificationURL — страница фронта с подтверждением адреса почты; токен добавляется параметром token.
+	EmailVerificationURL      string `yaml:"emailVerificationUrl" toml:"emailVerificationUrl" env:"EMAIL_VERIFICATION_URL" default:"http://localhost:5173/verify-email"`
+	EmailVerificationTTLHours int    `yaml:"emailVerificationTTLHours" toml:"emailVerificationTTLHours" env:"EMAIL_VERIFICATION_TTL_HOURS" default:"24"`
+	// EmailVerificationCooldownSeconds и EmailVerificationMaxPerHour ограничивают частоту писем подтверждения одному пользователю.
+	EmailVerificationCooldownSeconds int `yaml:"emailVerificationCooldownSeconds" toml:"emailVerificationCooldownSeconds" env:"EMAIL_VERIFICATION_COOLDOWN_SECONDS" default:"60"`
+	EmailVerificationMaxPerHour      int `yaml:"emailVerificationMaxPerHour" toml:"emailVerificationMaxPerHour" env:"EMAIL_VERIFICATION_MAX_PER_HOUR" default:"5"`
+	// PasswordResetCooldownSeconds и PasswordResetMaxPerHour ограничивают частоту писем сброса одному
+	// пользователю, PasswordResetMaxPerIPPerHour — по запросам с одного IP (0 — без предела).
+	PasswordResetCooldownSeconds int `yaml:"passwordResetCooldownSeconds" toml:"passwordResetCooldownSeconds" env:"PASSWORD_RESET_COOLDOWN_SECONDS" default:"60"`
+	PasswordResetMaxPerHour      int `yaml:"passwordResetMaxPerHour" toml:"passwordResetMaxPerHour" env:"PASSWORD_RESET_MAX_PER_HOUR" default:"3"`
+	PasswordResetMaxPerIPPerHour int `yaml:"passwordResetMaxPerIPPerHour" toml:"passwordResetMaxPerIPPerHour" env:"PASSWORD_RESET_MAX_PER_IP_PER_HOUR" default:"20"`
+}
+
+func (c SecurityConfig) FailedLoginWindow() time.Duration {
+	return time.Duration(c.FailedLoginWindowMinutes) * time.Minute
+}
+
+func (c SecurityConfig) PasswordResetTTL() time.Duration {
+	return time.Duration(c.PasswordResetTTLMinutes) * time.Minute
+}
+
+func (c SecurityConfig) PasswordResetCooldown() time.Duration {
+	return time.Duration(c.PasswordResetCooldownSeconds) * time.Second
+}
+
+func (c SecurityConfig) EmailVerificationTTL() time.Duration {
+	return time.Duration(c.EmailVerificationTTLHours) * time.Hour
+}
+
+func (c SecurityConfig) EmailVerificationCooldown() time.Duration {
+	return time.Duration(c.EmailVerificationCooldownSeconds) * time.Second
+}
+
+// Способы доставки писем (MAILER).
+const (
+	MailerConsole = "console"
+	MailerFile    = "file"
+	MailerSMTP    = "smtp"
+)
+
+// MailConfig — доставка писем пользователям. console и file только печатают письма
+// (для разработки), smtp отправляет их через SMTP_HOST.
+type MailConfig struct {
+	Mailer       string `yaml:"mailer" toml:"mailer" env:"MAILER" default:"console"`
+	File         string `yaml:"file" toml:"file" env:"MAIL_FILE" default:"mail.log"`
+	From         string `yaml:"from" toml:"from" env:"MAIL_FROM" default:"goTodo <no-reply@localhost>"`
+	SMTPHost     string `yaml:"smtpHost" toml:"smtpHost" env:"SMTP_HOST"`
+	SMTPPort     int    `yaml:"smtpPort" toml:"smtpPort" env:"SMTP_PORT" default:"587"`
+	SMTPUsername string `yaml:"smtpUsername" toml:"smtpUsername" env:"SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtpPassword" toml:"smtpPassword" env:"SMTP_PASSWORD" secret:"true"`
+}
+
+// PasswordConfig — парольная политика для регистрации, смены и сброса пароля.
+// BreachedListFile — файл SHA-1 хешей утекших паролей в формате Pwned Passwords
+// ("HASH[:COUNT]" построчно, отсортированные по хешу); пустое значение выключает проверку.
+// Открывается заново по SIGHUP.
+type PasswordConfig struct {
+	MinLength        int    `yaml:"minLength" toml:"minLength" env:"PASSWORD_MIN_LENGTH" default:"8"`
+	MaxBytes         int    `yaml:"maxBytes" toml:"maxBytes" env:"PASSWORD_MAX_BYTES" default:"72"`
+	BreachedListFile string `yaml:"breachedListFile" toml:"breachedListFile" env:"PASSWORD_BREACHED_LIST_FILE"`
+}
+
+// Хранилища счетчиков неудачных входов (LOGIN_THROTTLE_STORE).
+const (
+	LoginThrottleNone     = "none"
+	LoginThrottleMemory   = "memory"
+	LoginThrottlePostgres = "postgres"
+)
+
+// LoginThrottleConfig — блокировка входа после серии неудач. memory считает неудачи
+// в памяти инстанса, postgres — общие для всех инстансов; none выключает защиту.
+type LoginThrottleConfig struct {
+	Store             string `yaml:"store" toml:"store" env:"LOGIN_THROTTLE_STORE" default:"memory"`
+	AccountThreshold  int    `yaml:"accountThreshold" toml:"accountThreshold" env:"LOGIN_THROTTLE_ACCOUNT_THRESHOLD" default:"5"`
+	IPThreshold       int    `yaml:"ipThreshold" toml:"ipThreshold" env:"LOGIN_THROTTLE_IP_THRESHOLD" default:"20"`
+	BaseDelaySeconds  int    `yaml:"baseDelaySeconds" toml:"baseDelaySeconds" env:"LOGIN_THROTTLE_BASE_DELAY_SECONDS" default:"1"`
+	MaxLockoutMinutes int    `yaml:"maxLockoutMinutes" toml:"maxLockoutMinutes" env:"LOGIN_THROTTLE_MAX_LOCKOUT_MINUTES" default:"15"`
+	WindowMinutes     int    `yaml:"windowMinutes" toml:"windowMinutes" env:"LOGIN_THROTTLE_WINDOW_MINUTES" default:"15"`
+}
+
+func (c LoginThrottleConfig) BaseDelay() time.Duration {
+	return time.Duration(c.BaseDelaySeconds) * time.Second
+}
+
+func (c LoginThrottleConfig) MaxLockout() time.Duration {
+	return time.Duration(c.MaxLockoutMinutes) * time.Minute
+}
+
+func (c LoginThrottleConfig) Window() time.Duration {
+	return time.Duration(c.WindowMinutes) * time.Minute
+}
+
+// SchedulerConfig — фоновые задачи внутри сервера. При нескольких инстансах каждый
+// запуск выполняет только тот, кто взял advisory lock задачи.
+type SchedulerConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled" env:"SCHEDULER_ENABLED" default:"true"`
+	// SessionCleanupIntervalMinutes — период очистки refresh-сессий (±10% jitter).
+	SessionCleanupIntervalMinutes int `yaml:"sessionCleanupIntervalMinutes" toml:"sessionCleanupIntervalMinutes" env:"SESSION_CLEANUP_INTERVAL_MINUTES" default:"60"`
+	// SessionRetentionDays — сколько хранить истекшие и отозванные refresh-сессии.
+	SessionRetentionDays int `yaml:"sessionRetentionDays" toml:"sessionRetentionDays" env:"SESSION_RETENTION_DAYS" default:"30"`
+	// SecurityEventRetentionDays — сколько хранить записи журнала событий безопасности.
+	SecurityEventRetentionDays int `yaml:"securityEventRetentionDays" toml:"securityEventRetentionDays" env:"SECURITY_EVENT_RETENTION_DAYS" default:"365"`
+}
+
+func (c SchedulerConfig) SessionCleanupInterval() time.Duration {
+	return time.Duration(c.SessionCleanupIntervalMinutes) * time.Minute
+}
+
+func (c SchedulerConfig) SessionRetention() time.Duration {
+	return time.Duration(c.SessionRetentionDays) * 24 * time.Hour
+}
+
+func (c SchedulerConfig) SecurityEventRetention() time.Duration {
+	return time.Duration(c.SecurityEventRetentionDays) * 24 * time.Hour
+}
+
+func (c ServerConfig) ReadHeaderTimeout() time.Duration {
+	return time.Duration(c.ReadHeaderTimeoutSeconds) * time.Second
+}
+
+func (c ServerConfig) ReadTimeout() time.Duration {
+	return time.Duration(c.ReadTimeoutSeconds) * time.Second
+}
+
+func (c ServerConfig) WriteTimeout() time.Duration {
+	return time.Duration(c.WriteTimeoutSeconds) * time.Second
+}
+
+func (c ServerConfig) IdleTimeout() time.Duration {
+	return time.Duration(c.IdleTimeoutSeconds) * time.Second
+}
+
+func (c ServerConfig) RequestTimeout() time.Duration {
+	return time.Duration(c.RequestTimeoutMs) * time.Millisecond
+}
+
+func (c ServerConfig) ShutdownDrainDelay() time.Duration {
+	return time.Duration(c.ShutdownDrainDelaySecs) * time.Second
+}
+
+func (c ServerConfig) ShutdownTimeout() time.Duration {
+	return time.Duration(c.ShutdownTimeoutSeconds) * time.Second
+}
+
+func (c ServerConfig) ReadinessCheckTimeout() time.Duration {
+	return time.Duration(c.ReadinessCheckTimeoutMs) * time.Millisecond
+}
+
+// TrustedProxyPrefixes разбирает TrustedProxies; одиночный адрес становится подсетью /32 (/128).
+func (c ServerConfig) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(c.TrustedProxies, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func (c DatabaseConfig) QueryTimeout() time.Duration {
+	return time.Duration(c.QueryTimeoutMs) * time.Millisecond
+}
+
+// UsesKeySet сообщает, заданы ли ключи через JWTKeysDir или JWTKeys вместо JWTSecret.
+func (c AuthConfig) UsesKeySet() bool {
+	return strings.TrimSpace(c.JWTKeysDir) != "" || strings.TrimSpace(c.JWTKeys) != ""
+}
+
+func (c AuthConfig) AccessTokenTTL() time.Duration {
+	return time.Duration(c.AccessTokenTTLMinutes) * time.Minute
+}
+
+func (c AuthConfig) RefreshTokenTTL() time.Duration {
+	return time.Duration(c.RefreshTokenTTLHours) * time.Hour
+}
+
+func (c AuthConfig) RefreshTokenShortTTL() time.Duration {
+	return time.Duration(c.RefreshTokenShortTTLHours) * time.Hour
+}
+
+func (c AuthConfig) RevocationCacheTTL() time.Duration {
+	return time.Duration(c.RevocationCacheSeconds) * time.Second
+}
+
+func (c AuthConfig) RefreshGrace() time.Duration {
+	return time.Duration(c.RefreshGraceSeconds) * time.Second
+}
+
+func (c AuthConfig) SessionMaxLifetime() time.Duration {
+	return time.Duration(c.SessionMaxLifetimeHours) * time.Hour
+}
+
+func (c AuthConfig) SessionIdleTimeout() time.Duration {
+	return time.Duration(c.SessionIdleTimeoutHours) * time.Hour
+}
+
+func (c AuthConfig) Leeway() time.Duration {
+	return time.Duration(c.LeewaySeconds) * time.Second
+}
+
+// SameSiteMode переводит строковое значение SameSite в http.SameSite.
+// Значение уже проверено в Validate, поэтому неизвестных вариантов здесь нет.
+func (c CookieConfig) SameSiteMode() http.SameSite {
+	switch strings.ToLower(strings.TrimSpace(c.SameSite)) {
+	case "none":
+		return http.SameSiteNoneMode
+	case "strict":
+		return http.SameSiteStrictMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// FileEnvVar — переменная окружения с путем к файлу конфигурации.
+const FileEnvVar = "CONFIG_FILE"
+
+// Load собирает конфигурацию: значения по умолчанию, затем файл path
+// (YAML или TOML по расширению; пустой path — файл не читается),
+// затем переменные окружения. Возвращает все ошибки разбора и валидации сразу.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+
+	var errs []error
+	walkFields(reflect.ValueOf(cfg).Elem(), func(field reflect.Value, sf reflect.StructField) {
+		if def, ok := sf.Tag.Lookup("default"); ok {
+			if err := setField(field, def); err != nil {
+				errs = append(errs, fmt.Errorf("default for %s: %w", sf.Tag.Get("env"), err))
+			}
+		}
+	})
+
+	if path != "" {
+		if err := loadFile(path, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	walkFields(reflect.ValueOf(cfg).Elem(), func(field reflect.Value, sf reflect.StructField) {
+		key := sf.Tag.Get("env")
+		if key == "" {
+			return
+		}
+		value, ok := os.LookupEnv(key)
+		if !ok || strings.TrimSpace(value) == "" {
+			return
+		}
+		if err := setField(field, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return cfg, nil
+}
+
+func loadFile(path string, cfg *Config) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.UnmarshalStrict(content, cfg); err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(content), cfg)
+		if err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown keys in config file %s: %v", path, undecoded)
+		}
+	default:
+		return fmt.Errorf("unsupported config file extension %q: expected .yaml, .yml or .toml", filepath.Ext(path))
+	}
+
+	return nil
+}
+
+// walkFields обходит листовые поля вложенных структур конфигурации.
+func walkFields(v reflect.Value, fn func(field reflect.Value, sf reflect.StructField)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		sf := t.Field(i)
+		if field.Kind() == reflect.Struct {
+			walkFields(field, fn)
+			continue
+		}
+		fn(field, sf)
+	}
+}
+
+func setField(field reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		field.SetInt(int64(i))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported field kind %s", field.Kind())
+	}
+
+	return nil
+}
+
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "on":
+		return true, nil
+	case "0", "false", "no", "off":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid boolean %q", value)
+	}
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// redacted подставляется вместо значений полей с тегом secret:"true".
+const redacted = "[REDACTED]"
+
+// Print выводит итоговую конфигурацию в YAML (в формате файла конфигурации),
+// заменяя секреты на [REDACTED]. Пустой секрет выводится как есть,
+// чтобы было видно, что он не задан.
+func (c *Config) Print(w io.Writer) error {
+	out, err := yaml.Marshal(redactedTree(reflect.ValueOf(c).Elem()))
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	_, err = w.Write(out)
+	return err
+}
+
+// redactedTree строит yaml.MapSlice, сохраняя порядок полей структуры.
+func redactedTree(v reflect.Value) yaml.MapSlice {
+	t := v.Type()
+	tree := make(yaml.MapSlice, 0, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		sf := t.Field(i)
+		key, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+
+		var value any
+		switch {
+		case field.Kind() == reflect.Struct:
+			value = redactedTree(field)
+		case sf.Tag.Get("secret") == "true" && !field.IsZero():
+			value = redacted
+		default:
+			value = field.Interface()
+		}
+
+		tree = append(tree, yaml.MapItem{Key: key, Value: value})
+	}
+
+	return tree
+}
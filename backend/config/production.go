@@ -0,0 +1,130 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// devJWTSecret — значение JWT_SECRET по умолчанию для локальной разработки.
+// Оно опубликовано в репозитории, поэтому в production запрещено.
+const devJWTSecret = "dev-secret-change-me"
+
+const (
+	// minProdSecretLength — минимальная длина JWT_SECRET в production (байт).
+	minProdSecretLength = 32
+	// minProdSecretEntropyBits — минимальная оценка энтропии JWT_SECRET в битах.
+	minProdSecretEntropyBits = 128
+)
+
+// validateProduction возвращает нарушения требований production-режима:
+// стойкий JWT-секрет, Secure-cookie, явный CORS origin, TLS до БД и настоящая почта.
+func (c *Config) validateProduction() []error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("APP_ENV=prod: "+format, args...))
+		}
+	}
+
+	// Ключи из JWT_KEYS_DIR проверяются не здесь, а при каждой загрузке каталога
+	// (в том числе по SIGHUP) через CheckProdSigningSecret.
+	switch {
+	case c.Auth.JWTKeys != "":
+		for _, entry := range strings.Split(c.Auth.JWTKeys, ",") {
+			if strings.TrimSpace(entry) == "" {
+				continue
+			}
+			kid, secret, _ := strings.Cut(strings.TrimSpace(entry), ":")
+			errs = append(errs, checkProdSecret(fmt.Sprintf("JWT_KEYS[%s]", kid), secret)...)
+		}
+	case c.Auth.JWTKeysDir == "":
+		check(c.Auth.JWTSecret != devJWTSecret, "JWT_SECRET must be set (the default dev secret is public)")
+		errs = append(errs, checkProdSecret("JWT_SECRET", c.Auth.JWTSecret)...)
+	}
+
+	check(c.Cookie.Secure, "REFRESH_COOKIE_SECURE must be true")
+
+	if err := validateProdOrigin(c.Server.CORSAllowedOrigin); err != nil {
+		check(false, "CORS_ALLOWED_ORIGIN %v", err)
+	}
+
+	check(!strings.EqualFold(strings.TrimSpace(c.Database.SSLMode), "disable"), "DB_SSLMODE must not be disable")
+
+	// console/file только печатают письма: ссылки сброса пароля до пользователей не дойдут.
+	check(strings.EqualFold(strings.TrimSpace(c.Mail.Mailer), MailerSMTP), "MAILER must be smtp")
+
+	return errs
+}
+
+// CheckProdSigningSecret применяет требования production к HMAC-секрету, загруженному
+// не из конфигурации (JWT_KEYS_DIR): те же длина и энтропия, что у JWT_SECRET.
+func CheckProdSigningSecret(name string, secret []byte) error {
+	return errors.Join(checkProdSecret(name, string(secret))...)
+}
+
+// checkProdSecret проверяет длину и оценку энтропии секрета подписи.
+func checkProdSecret(name, secret string) []error {
+	var errs []error
+	if len(secret) < minProdSecretLength {
+		errs = append(errs, fmt.Errorf("APP_ENV=prod: %s must be at least %d bytes, got %d", name, minProdSecretLength, len(secret)))
+	}
+	if bits := estimateEntropyBits(secret); bits < minProdSecretEntropyBits {
+		errs = append(errs, fmt.Errorf("APP_ENV=prod: %s is too predictable: estimated entropy %.0f bits, need at least %d (use e.g. `openssl rand -base64 48`)",
+			name, bits, minProdSecretEntropyBits))
+	}
+	return errs
+}
+
+// validateProdOrigin требует явный https-origin без wildcard и localhost.
+func validateProdOrigin(origin string) error {
+	origin = strings.TrimSpace(origin)
+	if origin == "" || origin == "*" {
+		return fmt.Errorf("must be an explicit origin, got %q", origin)
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("must be an absolute origin like https://app.example.com, got %q", origin)
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("must use https, got %q", origin)
+	}
+	if host := u.Hostname(); host == "localhost" || isLoopback(host) {
+		return fmt.Errorf("must not point to localhost, got %q", origin)
+	}
+
+	return nil
+}
+
+func isLoopback(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// estimateEntropyBits оценивает энтропию строки как длина × энтропия Шеннона
+// на символ. Оценка грубая, но отсекает повторяющиеся и словарные значения
+// вроде "aaaa…" или "secretsecretsecret…".
+func estimateEntropyBits(s string) float64 {
+	if s == "" {
+		return 0
+	}
+
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+
+	var perChar float64
+	for _, n := range counts {
+		p := float64(n) / float64(total)
+		perChar -= p * math.Log2(p)
+	}
+
+	return perChar * float64(total)
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+var (
+	validSSLModes   = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	validSameSite   = []string{"lax", "strict", "none"}
+	validLogLevels  = []string{"debug", "info", "warn", "error"}
+	validLogFormats = []string{"text", "json"}
+	validExporters  = []string{"none", "otlp", "stdout"}
+	validEnvs       = []string{EnvDev, EnvStaging, EnvProd}
+	validNotifiers  = []string{NotifierNone, NotifierLog, NotifierEmail}
+	validMailers    = []string{MailerConsole, MailerFile, MailerSMTP}
+	validThrottles  = []string{LoginThrottleNone, LoginThrottleMemory, LoginThrottlePostgres}
+)
+
+// Validate проверяет значения конфигурации и возвращает все найденные
+// проблемы одной ошибкой (errors.Join), чтобы их можно было исправить за один заход.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(oneOf(c.App.Env, validEnvs), "APP_ENV must be one of %s, got %q", strings.Join(validEnvs, "|"), c.App.Env)
+
+	s := c.Server
+	check(s.Addr != "", "HTTP_ADDR must not be empty")
+	check(s.ReadHeaderTimeoutSeconds > 0, "HTTP_READ_HEADER_TIMEOUT_SECONDS must be positive, got %d", s.ReadHeaderTimeoutSeconds)
+	check(s.ReadTimeoutSeconds > 0, "HTTP_READ_TIMEOUT_SECONDS must be positive, got %d", s.ReadTimeoutSeconds)
+	check(s.WriteTimeoutSeconds > 0, "HTTP_WRITE_TIMEOUT_SECONDS must be positive, got %d", s.WriteTimeoutSeconds)
+	check(s.IdleTimeoutSeconds > 0, "HTTP_IDLE_TIMEOUT_SECONDS must be positive, got %d", s.IdleTimeoutSeconds)
+	check(s.RequestTimeoutMs > 0, "REQUEST_TIMEOUT_MS must be positive, got %d", s.RequestTimeoutMs)
+	check(s.WriteTimeout() >= s.RequestTimeout(),
+		"HTTP_WRITE_TIMEOUT_SECONDS (%s) must not be shorter than REQUEST_TIMEOUT_MS (%s)", s.WriteTimeout(), s.RequestTimeout())
+	check(s.ShutdownDrainDelaySecs >= 0, "SHUTDOWN_DRAIN_DELAY_SECONDS must not be negative, got %d", s.ShutdownDrainDelaySecs)
+	check(s.ShutdownTimeoutSeconds > 0, "SHUTDOWN_TIMEOUT_SECONDS must be positive, got %d", s.ShutdownTimeoutSeconds)
+	check(s.ReadinessCheckTimeoutMs > 0, "READINESS_CHECK_TIMEOUT_MS must be positive, got %d", s.ReadinessCheckTimeoutMs)
+	if _, err := s.TrustedProxyPrefixes(); err != nil {
+		check(false, "TRUSTED_PROXIES: %v", err)
+	}
+
+	d := c.Database
+	check(d.Host != "", "DB_HOST must not be empty")
+	check(d.Port > 0 && d.Port <= 65535, "DB_PORT must be in range 1..65535, got %d", d.Port)
+	check(d.User != "", "DB_USER must not be empty")
+	check(d.Name != "", "DB_NAME must not be empty")
+	check(oneOf(d.SSLMode, validSSLModes), "DB_SSLMODE must be one of %s, got %q", strings.Join(validSSLModes, "|"), d.SSLMode)
+	check(d.QueryTimeoutMs > 0, "DB_QUERY_TIMEOUT_MS must be positive, got %d", d.QueryTimeoutMs)
+
+	a := c.Auth
+	check(a.UsesKeySet() || a.JWTSecret != "", "JWT_SECRET must not be empty")
+	check(a.JWTKeysDir == "" || a.JWTKeys == "", "JWT_KEYS_DIR and JWT_KEYS are mutually exclusive")
+	check(a.AccessTokenTTLMinutes > 0, "JWT_ACCESS_TTL_MINUTES must be positive, got %d", a.AccessTokenTTLMinutes)
+	check(a.RefreshTokenTTLHours > 0, "JWT_REFRESH_TTL_HOURS must be positive, got %d", a.RefreshTokenTTLHours)
+	check(a.RefreshTokenTTL() > a.AccessTokenTTL(), "JWT_REFRESH_TTL_HOURS must be longer than JWT_ACCESS_TTL_MINUTES")
+	check(a.RefreshTokenShortTTL() > a.AccessTokenTTL(), "JWT_REFRESH_SHORT_TTL_HOURS must be longer than JWT_ACCESS_TTL_MINUTES")
+	check(a.RefreshTokenShortTTLHours <= a.RefreshTokenTTLHours, "JWT_REFRESH_SHORT_TTL_HOURS must not exceed JWT_REFRESH_TTL_HOURS")
+	check(strings.TrimSpace(a.Issuer) != "", "JWT_ISSUER must not be empty")
+	check(strings.TrimSpace(a.Audience) != "", "JWT_AUDIENCE must not be empty")
+	check(a.RevocationCacheSeconds >= 0, "JWT_REVOCATION_CACHE_SECONDS must not be negative, got %d", a.RevocationCacheSeconds)
+	check(a.LeewaySeconds >= 0 && a.LeewaySeconds <= 300, "JWT_LEEWAY_SECONDS must be in range 0..300, got %d", a.LeewaySeconds)
+	check(a.SessionMaxLifetimeHours >= 0, "SESSION_MAX_LIFETIME_HOURS must not be negative, got %d", a.SessionMaxLifetimeHours)
+	check(a.SessionIdleTimeoutHours >= 0, "SESSION_IDLE_TIMEOUT_HOURS must not be negative, got %d", a.SessionIdleTimeoutHours)
+	check(a.RefreshGraceSeconds >= 0 && a.RefreshGraceSeconds <= 60, "JWT_REFRESH_GRACE_SECONDS must be in range 0..60, got %d", a.RefreshGraceSeconds)
+
+	ck := c.Cookie
+	check(ck.Name != "", "REFRESH_COOKIE_NAME must not be empty")
+	check(oneOf(ck.SameSite, validSameSite), "REFRESH_COOKIE_SAMESITE must be one of Lax|Strict|None, got %q", ck.SameSite)
+	check(!strings.EqualFold(ck.SameSite, "none") || ck.Secure, "REFRESH_COOKIE_SAMESITE=None requires REFRESH_COOKIE_SECURE=true")
+
+	l := c.Log
+	check(oneOf(l.Level, validLogLevels), "LOG_LEVEL must be one of %s, got %q", strings.Join(validLogLevels, "|"), l.Level)
+	check(oneOf(l.Format, validLogFormats), "LOG_FORMAT must be one of %s, got %q", strings.Join(validLogFormats, "|"), l.Format)
+
+	check(c.Metrics.Addr != "", "METRICS_ADDR must not be empty (use \"off\" to disable)")
+
+	t := c.Tracing
+	check(oneOf(t.Exporter, validExporters), "TRACING_EXPORTER must be one of %s, got %q", strings.Join(validExporters, "|"), t.Exporter)
+	check(t.SampleRatio >= 0 && t.SampleRatio <= 1, "TRACING_SAMPLE_RATIO must be in range 0..1, got %g", t.SampleRatio)
+	check(t.ServiceName != "", "TRACING_SERVICE_NAME must not be empty")
+
+	check(c.Todo.UndoHistoryLimit > 0, "UNDO_HISTORY_LIMIT must be positive, got %d", c.Todo.UndoHistoryLimit)
+
+	sec := c.Security
+	check(oneOf(sec.Notifier, validNotifiers), "SECURITY_NOTIFIER must be one of %s, got %q", strings.Join(validNotifiers, "|"), sec.Notifier)
+	check(sec.FailedLoginThreshold >= 0, "SECURITY_FAILED_LOGIN_THRESHOLD must not be negative, got %d", sec.FailedLoginThreshold)
+	check(sec.FailedLoginWindowMinutes > 0, "SECURITY_FAILED_LOGIN_WINDOW_MINUTES must be positive, got %d", sec.FailedLoginWindowMinutes)
+	check(sec.PasswordResetTTLMinutes > 0, "PASSWORD_RESET_TTL_MINUTES must be positive, got %d", sec.PasswordResetTTLMinutes)
+	if u, err := url.Parse(sec.PasswordResetURL); err != nil || u.Scheme == "" || u.Host == "" {
+		check(false, "PASSWORD_RESET_URL must be an absolute URL, got %q", sec.PasswordResetURL)
+	}
+	if u, err := url.Parse(sec.EmailVerificationURL); err != nil || u.Scheme == "" || u.Host == "" {
+		check(false, "EMAIL_VERIFICATION_URL must be an absolute URL, got %q", sec.EmailVerificationURL)
+	}
+	check(sec.EmailVerificationTTLHours > 0, "EMAIL_VERIFICATION_TTL_HOURS must be positive, got %d", sec.EmailVerificationTTLHours)
+	check(sec.EmailVerificationCooldownSeconds >= 0, "EMAIL_VERIFICATION_COOLDOWN_SECONDS must not be negative, got %d", sec.EmailVerificationCooldownSeconds)
+	check(sec.EmailVerificationMaxPerHour > 0, "EMAIL_VERIFICATION_MAX_PER_HOUR must be positive, got %d", sec.EmailVerificationMaxPerHour)
+	check(sec.PasswordResetCooldownSeconds >= 0, "PASSWORD_RESET_COOLDOWN_SECONDS must not be negative, got %d", sec.PasswordResetCooldownSeconds)
+	check(sec.PasswordResetMaxPerHour > 0, "PASSWORD_RESET_MAX_PER_HOUR must be positive, got %d", sec.PasswordResetMaxPerHour)
+	check(sec.PasswordResetMaxPerIPPerHour >= 0, "PASSWORD_RESET_MAX_PER_IP_PER_HOUR must not be negative, got %d", sec.PasswordResetMaxPerIPPerHour)
+
+	m := c.Mail
+	check(oneOf(m.Mailer, validMailers), "MAILER must be one of %s, got %q", strings.Join(validMailers, "|"), m.Mailer)
+	check(!strings.EqualFold(m.Mailer, MailerFile) || m.File != "", "MAIL_FILE is required for MAILER=file")
+	check(!strings.EqualFold(m.Mailer, MailerSMTP) || m.SMTPHost != "", "SMTP_HOST is required for MAILER=smtp")
+	check(m.SMTPPort > 0 && m.SMTPPort <= 65535, "SMTP_PORT must be in range 1..65535, got %d", m.SMTPPort)
+	if _, err := mail.ParseAddress(m.From); err != nil {
+		check(false, "MAIL_FROM must be a valid address, got %q", m.From)
+	}
+
+	pw := c.Password
+	check(pw.MinLength > 0, "PASSWORD_MIN_LENGTH must be positive, got %d", pw.MinLength)
+	// bcrypt учитывает только первые 72 байта пароля.
+	check(pw.MaxBytes > 0 && pw.MaxBytes <= 72, "PASSWORD_MAX_BYTES must be in range 1..72, got %d", pw.MaxBytes)
+	check(pw.MinLength <= pw.MaxBytes, "PASSWORD_MIN_LENGTH (%d) must not exceed PASSWORD_MAX_BYTES (%d)", pw.MinLength, pw.MaxBytes)
+
+	lt := c.LoginThrottle
+	check(oneOf(lt.Store, validThrottles), "LOGIN_THROTTLE_STORE must be one of %s, got %q", strings.Join(validThrottles, "|"), lt.Store)
+	check(lt.AccountThreshold >= 0, "LOGIN_THROTTLE_ACCOUNT_THRESHOLD must not be negative, got %d", lt.AccountThreshold)
+	check(lt.IPThreshold >= 0, "LOGIN_THROTTLE_IP_THRESHOLD must not be negative, got %d", lt.IPThreshold)
+	check(lt.BaseDelaySeconds > 0, "LOGIN_THROTTLE_BASE_DELAY_SECONDS must be positive, got %d", lt.BaseDelaySeconds)
+	check(lt.MaxLockoutMinutes > 0, "LOGIN_THROTTLE_MAX_LOCKOUT_MINUTES must be positive, got %d", lt.MaxLockoutMinutes)
+	check(lt.BaseDelay() <= lt.MaxLockout(), "LOGIN_THROTTLE_BASE_DELAY_SECONDS (%d) must not exceed LOGIN_THROTTLE_MAX_LOCKOUT_MINUTES (%d)", lt.BaseDelaySeconds, lt.MaxLockoutMinutes)
+	check(lt.WindowMinutes > 0, "LOGIN_THROTTLE_WINDOW_MINUTES must be positive, got %d", lt.WindowMinutes)
+
+	sch := c.Scheduler
+	check(sch.SessionCleanupIntervalMinutes > 0, "SESSION_CLEANUP_INTERVAL_MINUTES must be positive, got %d", sch.SessionCleanupIntervalMinutes)
+	check(sch.SessionRetentionDays > 0, "SESSION_RETENTION_DAYS must be positive, got %d", sch.SessionRetentionDays)
+	check(sch.SecurityEventRetentionDays > 0, "SECURITY_EVENT_RETENTION_DAYS must be positive, got %d", sch.SecurityEventRetentionDays)
+
+	if c.App.IsProduction() {
+		errs = append(errs, c.validateProduction()...)
+	}
+
+	return errors.Join(errs...)
+}
+
+func oneOf(value string, allowed []string) bool {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for _, candidate := range allowed {
+		if value == candidate {
+			return true
+		}
+	}
+	return false
+}
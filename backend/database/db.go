@@ -3,7 +3,7 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 
 	_ "github.com/lib/pq"
 )
@@ -40,6 +40,6 @@ func NewDB(cfg Config) (*sql.DB, error) {
 	db.SetMaxOpenConns(25)
 	db.SetMaxIdleConns(5)
 
-	log.Println("Database connection established successfully")
+	slog.Info("database connection established", "host", cfg.Host, "port", cfg.Port, "dbname", cfg.DBName)
 	return db, nil
 }
@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID — ключ pg_advisory_lock, чтобы несколько инстансов,
+// стартующих одновременно, не применяли миграции параллельно.
+const migrationLockID int64 = 7_302_114_001
+
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// ExpectedVersion возвращает версию схемы, с которой собран бинарник
+// (номер последней встроенной миграции). Ошибка означает битые встроенные файлы.
+func ExpectedVersion() (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, errors.New("no embedded migrations")
+	}
+
+	return migrations[len(migrations)-1].version, nil
+}
+
+// CurrentVersion возвращает последнюю примененную к БД версию схемы.
+// Если таблица schema_migrations еще не создана, возвращает 0.
+func CurrentVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var exists bool
+	if err := db.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return 0, fmt.Errorf("failed to check schema_migrations: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	var version int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	return version, nil
+}
+
+// Migrate применяет все встроенные миграции, которых еще нет в schema_migrations.
+// Каждая миграция выполняется в своей транзакции вместе с записью о версии.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection for migrations: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+	}()
+
+	createQuery := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INT PRIMARY KEY,
+			name       TEXT        NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`
+	if _, err := conn.ExecContext(ctx, createQuery); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		if err := applyMigration(ctx, conn, m); err != nil {
+			return err
+		}
+		slog.Info("migration applied", "version", m.version, "name", m.name)
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, m migration) (err error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %w", m.version, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, m.sql); err != nil {
+		return fmt.Errorf("failed to apply migration %d (%s): %w", m.version, m.name, err)
+	}
+
+	if _, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.version, m.name); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", m.version, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", m.version, err)
+	}
+
+	return nil
+}
+
+// loadMigrations читает встроенные файлы вида NNNN_name.sql, отсортированные по версии.
+func loadMigrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	migrations := make([]migration, 0, len(entries))
+	for _, entry := range entries {
+		fileName := entry.Name()
+		base := strings.TrimSuffix(fileName, ".sql")
+		versionPart, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name %q: expected NNNN_name.sql", fileName)
+		}
+
+		version, err := strconv.Atoi(versionPart)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q: %w", fileName, err)
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join("migrations", fileName))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %q: %w", fileName, err)
+		}
+
+		migrations = append(migrations, migration{version: version, name: name, sql: string(content)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// CheckSchemaVersion возвращает ошибку, если примененная версия схемы старше ExpectedVersion
+// (миграции не применены). БД новее бинарника — нормальное состояние rolling deploy: новый под
+// уже применил свои миграции, а старые продолжают обслуживать трафик (миграции обратно совместимы).
+func CheckSchemaVersion(ctx context.Context, db *sql.DB) error {
+	expected, err := ExpectedVersion()
+	if err != nil {
+		return err
+	}
+
+	current, err := CurrentVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	if current < expected {
+		return fmt.Errorf("schema version is %d, expected at least %d", current, expected)
+	}
+
+	return nil
+}
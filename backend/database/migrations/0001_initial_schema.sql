@@ -0,0 +1,36 @@
+-- Базовая схема, совпадающая с тем, что раньше создавалось вручную.
+-- IF NOT EXISTS позволяет применить миграцию к уже существующей БД.
+
+CREATE TABLE IF NOT EXISTS users (
+    id            BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    username      TEXT        NOT NULL UNIQUE,
+    password_hash TEXT        NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS todos (
+    id      BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    value   TEXT   NOT NULL,
+    date    TEXT   NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS todos_user_id_id_idx ON todos (user_id, id DESC);
+
+CREATE TABLE IF NOT EXISTS auth_refresh_sessions (
+    id                     BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id                BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash             TEXT        NOT NULL UNIQUE,
+    family_id              UUID        NOT NULL,
+    issued_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at             TIMESTAMPTZ NOT NULL,
+    consumed_at            TIMESTAMPTZ,
+    revoked_at             TIMESTAMPTZ,
+    revoke_reason          TEXT,
+    replaced_by_session_id BIGINT REFERENCES auth_refresh_sessions (id),
+    created_at             TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at             TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS auth_refresh_sessions_family_id_idx ON auth_refresh_sessions (family_id);
+CREATE INDEX IF NOT EXISTS auth_refresh_sessions_user_id_idx ON auth_refresh_sessions (user_id);
@@ -0,0 +1,16 @@
+-- Отзыв access-токенов до истечения exp.
+-- tokens_valid_after — watermark: токены пользователя с iat раньше него недействительны
+-- ("выйти на всех устройствах", смена пароля).
+-- revoked_access_tokens — deny list отдельных токенов по jti (logout текущего устройства).
+-- Строки можно удалять после expires_at: такой токен и так не пройдет проверку exp.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tokens_valid_after TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS revoked_access_tokens (
+    jti        TEXT PRIMARY KEY,
+    user_id    BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS revoked_access_tokens_expires_at_idx ON revoked_access_tokens (expires_at);
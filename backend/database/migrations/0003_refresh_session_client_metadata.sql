@@ -0,0 +1,8 @@
+-- Метаданные клиента для списка активных сессий (устройств).
+-- Записываются при создании сессии и при каждой ротации, поэтому у последней
+-- сессии семьи — актуальные user agent и IP.
+
+ALTER TABLE auth_refresh_sessions ADD COLUMN IF NOT EXISTS user_agent TEXT;
+ALTER TABLE auth_refresh_sessions ADD COLUMN IF NOT EXISTS ip_address TEXT;
+
+CREATE INDEX IF NOT EXISTS auth_refresh_sessions_user_id_family_id_idx ON auth_refresh_sessions (user_id, family_id);
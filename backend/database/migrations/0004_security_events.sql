@@ -0,0 +1,15 @@
+-- Журнал событий безопасности пользователя: повторное использование refresh-токена,
+-- попытка обновиться истекшим токеном, неудачные входы.
+
+CREATE TABLE IF NOT EXISTS security_events (
+    id         BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id    BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    event_type TEXT        NOT NULL,
+    ip_address TEXT        NOT NULL DEFAULT '',
+    user_agent TEXT        NOT NULL DEFAULT '',
+    details    JSONB       NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS security_events_user_id_created_at_idx ON security_events (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS security_events_user_id_type_created_at_idx ON security_events (user_id, event_type, created_at DESC);
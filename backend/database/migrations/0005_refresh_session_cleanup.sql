@@ -0,0 +1,13 @@
+-- Очистка auth_refresh_sessions фоновой задачей: удаляются сессии, истекшие или
+-- отозванные раньше срока хранения. Ссылка на удаленного преемника обнуляется,
+-- а не блокирует удаление.
+
+ALTER TABLE auth_refresh_sessions
+    DROP CONSTRAINT IF EXISTS auth_refresh_sessions_replaced_by_session_id_fkey;
+
+ALTER TABLE auth_refresh_sessions
+    ADD CONSTRAINT auth_refresh_sessions_replaced_by_session_id_fkey
+    FOREIGN KEY (replaced_by_session_id) REFERENCES auth_refresh_sessions (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS auth_refresh_sessions_expires_at_idx ON auth_refresh_sessions (expires_at);
+CREATE INDEX IF NOT EXISTS auth_refresh_sessions_revoked_at_idx ON auth_refresh_sessions (revoked_at) WHERE revoked_at IS NOT NULL;
@@ -0,0 +1,17 @@
+-- Время входа (создания семьи) копируется в каждую сессию семьи при ротации:
+-- по нему проверяется абсолютный срок жизни входа, который ротация не продлевает.
+
+ALTER TABLE auth_refresh_sessions ADD COLUMN IF NOT EXISTS family_issued_at TIMESTAMPTZ;
+
+UPDATE auth_refresh_sessions AS s
+SET family_issued_at = f.first_issued_at
+FROM (
+    SELECT family_id, MIN(issued_at) AS first_issued_at
+    FROM auth_refresh_sessions
+    GROUP BY family_id
+) AS f
+WHERE s.family_id = f.family_id AND s.family_issued_at IS NULL;
+
+ALTER TABLE auth_refresh_sessions
+    ALTER COLUMN family_issued_at SET DEFAULT NOW(),
+    ALTER COLUMN family_issued_at SET NOT NULL;
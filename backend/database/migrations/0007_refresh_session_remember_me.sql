@@ -0,0 +1,5 @@
+-- Режим входа «запомнить меня» хранится в каждой сессии семьи и переносится при ротации:
+-- без него refresh-токен живет меньше, а cookie выдается сессионной (без Expires).
+-- Существующие входы были постоянными.
+
+ALTER TABLE auth_refresh_sessions ADD COLUMN IF NOT EXISTS remember_me BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,12 @@
+-- Счетчики неудачных входов для защиты от перебора паролей (LOGIN_THROTTLE_STORE=postgres).
+-- key — "user:<id>", "login:<логин>" или "ip:<адрес>"; locked_until — до какого момента
+-- вход по ключу отклоняется с 429.
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key             TEXT        PRIMARY KEY,
+    failures        INTEGER     NOT NULL,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    locked_until    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS login_attempts_last_failure_at_idx ON login_attempts (last_failure_at);
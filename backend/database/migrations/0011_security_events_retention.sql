@@ -0,0 +1,4 @@
+-- Очистка журнала событий безопасности старше SECURITY_EVENT_RETENTION_DAYS
+-- (задача security_event_cleanup) выбирает строки по created_at без user_id.
+
+CREATE INDEX IF NOT EXISTS security_events_created_at_idx ON security_events (created_at);
@@ -0,0 +1,5 @@
+-- Лимит писем сброса пароля с одного IP (PASSWORD_RESET_MAX_PER_IP_PER_HOUR) считает
+-- токены по requested_ip за последний час; лимит на пользователя — по user_id.
+
+CREATE INDEX IF NOT EXISTS password_reset_tokens_requested_ip_created_at_idx ON password_reset_tokens (requested_ip, created_at);
+CREATE INDEX IF NOT EXISTS password_reset_tokens_user_id_created_at_idx ON password_reset_tokens (user_id, created_at);
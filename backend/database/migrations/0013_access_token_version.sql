@@ -0,0 +1,12 @@
+-- Отзыв всех access-токенов пользователя по версии вместо времени выпуска.
+-- Токен несет claim ver — значение token_version на момент выпуска; отзыв увеличивает версию,
+-- и токены с меньшей ver отклоняются. В отличие от сравнения iat с tokens_valid_after, это не
+-- зависит от точности iat: токен, выпущенный в ту же секунду после отзыва, уже несет новую версию.
+-- Пользователи с watermark получают версию 1: их токены без ver, выпущенные до обновления,
+-- отклоняются, и клиент получает новый через refresh.
+-- tokens_valid_after больше не читается; колонку можно удалить, когда в кластере не останется
+-- версий сервиса, которые ее используют.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version BIGINT NOT NULL DEFAULT 0;
+
+UPDATE users SET token_version = 1 WHERE tokens_valid_after IS NOT NULL AND token_version = 0;
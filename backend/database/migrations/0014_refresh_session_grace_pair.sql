@@ -0,0 +1,6 @@
+-- Пара, выданная при ротации, хранится на использованной сессии, чтобы повтор в grace-окне
+-- работал на любом инстансе. Пара зашифрована ключом, выведенным из сырого использованного
+-- refresh-токена (в БД его нет, только hash): расшифровать ее может лишь тот, кто предъявил
+-- этот токен, а утечка таблицы рабочих токенов не дает. Очищается при следующей ротации.
+
+ALTER TABLE auth_refresh_sessions ADD COLUMN IF NOT EXISTS grace_pair BYTEA;
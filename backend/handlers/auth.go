@@ -1,10 +1,10 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -12,6 +12,9 @@ import (
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 
+	"goTodo/backend/logger"
+	"goTodo/backend/metrics"
+	"goTodo/backend/middleware"
 	"goTodo/backend/models"
 	"goTodo/backend/repository"
 	"goTodo/backend/services"
@@ -26,8 +29,62 @@ type AuthHandler struct {
 	userRepo      repository.UserRepository
 	refreshRepo   repository.RefreshSessionRepository
 	auth          services.AuthService
-	refreshTTL    time.Duration
+	revocation    services.TokenRevocation
+	events        services.SecurityEvents
+	grace         services.RefreshGrace
+	passwords     services.PasswordPolicy
+	throttle      services.LoginThrottle
+	sessions      SessionPolicy
 	refreshCookie RefreshCookieConfig
+	metrics       *metrics.Metrics
+}
+
+// Коды ErrorResponse.Code, с которыми refresh завершает вход по политике сроков:
+// клиент показывает форму входа с пояснением, почему нужно войти снова.
+const (
+	ErrorCodeSessionLifetimeExceeded = "session_lifetime_exceeded"
+	ErrorCodeSessionIdleTimeout      = "session_idle_timeout"
+)
+
+// SessionPolicy — сроки жизни входа (семьи refresh-сессий).
+type SessionPolicy struct {
+	// RefreshTTL — срок одного refresh-токена; каждая ротация выдает новый.
+	RefreshTTL time.Duration
+	// ShortRefreshTTL — то же для входа без «запомнить меня» (сессионная cookie).
+	ShortRefreshTTL time.Duration
+	// MaxLifetime — абсолютный срок входа с момента логина, ротация его не продлевает; 0 — без ограничения.
+	MaxLifetime time.Duration
+	// IdleTimeout — максимальная пауза между refresh; 0 — выключено.
+	IdleTimeout time.Duration
+}
+
+// expiresAt возвращает срок новой сессии семьи: RefreshTTL (ShortRefreshTTL без rememberMe),
+// но не дальше абсолютного срока входа и idle timeout. По нему же выставляется Expires cookie.
+func (p SessionPolicy) expiresAt(familyIssuedAt time.Time, now time.Time, rememberMe bool) time.Time {
+	ttl := p.RefreshTTL
+	if !rememberMe {
+		ttl = p.ShortRefreshTTL
+	}
+	expiresAt := now.Add(ttl)
+	if p.IdleTimeout > 0 && now.Add(p.IdleTimeout).Before(expiresAt) {
+		expiresAt = now.Add(p.IdleTimeout)
+	}
+	if p.MaxLifetime > 0 && familyIssuedAt.Add(p.MaxLifetime).Before(expiresAt) {
+		expiresAt = familyIssuedAt.Add(p.MaxLifetime)
+	}
+	return expiresAt
+}
+
+// endReason возвращает код и сообщение, если вход пора завершить по политике сроков,
+// или пустой код. Последняя активность семьи — выпуск текущей сессии (последний refresh).
+func (p SessionPolicy) endReason(session *models.RefreshSession, now time.Time) (string, string) {
+	if p.MaxLifetime > 0 && !now.Before(session.FamilyIssuedAt.Add(p.MaxLifetime)) {
+		return ErrorCodeSessionLifetimeExceeded, "Session has reached its maximum lifetime, please log in again"
+	}
+	if p.IdleTimeout > 0 && !now.Before(session.IssuedAt.Add(p.IdleTimeout)) {
+		return ErrorCodeSessionIdleTimeout, "Session expired due to inactivity, please log in again"
+	}
+	return "", ""
 }
 
 type RefreshCookieConfig struct {
@@ -40,32 +97,50 @@ type RefreshCookieConfig struct {
 }
 
 // NewAuthHandler создает новый обработчик для auth-эндпоинтов.
-// Параметры: userRepo — слой доступа к users; auth — сервис bcrypt/JWT.
+// Параметры: userRepo — слой доступа к users; auth — сервис bcrypt/JWT;
+// revocation — отзыв access-токенов при logout; events — журнал событий безопасности;
+// grace — окно повторной выдачи пары при параллельном refresh; passwords — парольная политика;
+// throttle — блокировка входа после серии неудач; sessions — сроки жизни входа;
+// metrics — счетчики исходов авторизации (может быть nil).
 // Возвращает: инициализированный AuthHandler.
 func NewAuthHandler(
 	userRepo repository.UserRepository,
 	refreshRepo repository.RefreshSessionRepository,
 	auth services.AuthService,
-	refreshTTL time.Duration,
+	revocation services.TokenRevocation,
+	events services.SecurityEvents,
+	grace services.RefreshGrace,
+	passwords services.PasswordPolicy,
+	throttle services.LoginThrottle,
+	sessions SessionPolicy,
 	refreshCookie RefreshCookieConfig,
+	metrics *metrics.Metrics,
 ) *AuthHandler {
 	return &AuthHandler{
 		userRepo:      userRepo,
 		refreshRepo:   refreshRepo,
 		auth:          auth,
-		refreshTTL:    refreshTTL,
+		revocation:    revocation,
+		events:        events,
+		grace:         grace,
+		passwords:     passwords,
+		throttle:      throttle,
+		sessions:      sessions,
 		refreshCookie: refreshCookie,
+		metrics:       metrics,
 	}
 }
 
 // Register godoc
 // @Summary Register user
+// @Description Пароль проверяется политикой; при нарушении — 400 с code password_policy_violation и списком violations.
+// @Description Логин не может содержать "@" (такие строки считаются адресами почты) — 400.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param request body models.RegisterRequest true "Register request"
 // @Success 201 {object} models.AuthResponse
-// @Failure 400 {object} models.ErrorResponse
+// @Failure 400 {object} models.PasswordPolicyErrorResponse
 // @Failure 409 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /auth/register [post]
@@ -82,15 +157,26 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "Fields 'username' and 'password' are required")
 		return
 	}
+	// "@" оставлен адресам почты: иначе логин одного пользователя мог бы совпасть с адресом другого.
+	if strings.Contains(username, "@") {
+		respondWithError(w, http.StatusBadRequest, "Field 'username' must not contain '@'")
+		return
+	}
+
+	if err := h.passwords.Validate(r.Context(), req.Password, username); err != nil {
+		if !respondWithPasswordPolicyError(w, err) {
+			respondWithServerError(w, r, err, "failed to validate password", "Failed to register user")
+		}
+		return
+	}
 
 	passwordHash, err := h.auth.HashPassword(req.Password)
 	if err != nil {
-		log.Printf("Error hashing password: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to register user")
+		respondWithServerError(w, r, err, "failed to hash password", "Failed to register user")
 		return
 	}
 
-	user, err := h.userRepo.CreateUser(username, passwordHash)
+	user, err := h.userRepo.CreateUser(r.Context(), username, passwordHash)
 	if err != nil {
 		var pgErr *pq.Error
 		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolationCode {
@@ -98,21 +184,19 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		log.Printf("Error creating user: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to register user")
+		respondWithServerError(w, r, err, "failed to create user", "Failed to register user")
 		return
 	}
 
-	accessToken, err := h.auth.GenerateAccessToken(user.ID, user.Username)
+	familyID := uuid.NewString()
+	accessToken, err := h.auth.GenerateAccessToken(user, familyID)
 	if err != nil {
-		log.Printf("Error generating access token: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to register user")
+		respondWithServerError(w, r, err, "failed to generate access token", "Failed to register user")
 		return
 	}
 
-	if err := h.issueRefreshSessionAndSetCookie(w, user.ID); err != nil {
-		log.Printf("Error issuing refresh session on register: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to register user")
+	if err := h.issueRefreshSessionAndSetCookie(w, r, user.ID, familyID, true); err != nil {
+		respondWithServerError(w, r, err, "failed to issue refresh session on register", "Failed to register user")
 		return
 	}
 
@@ -124,6 +208,8 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 
 // Login godoc
 // @Summary Login user
+// @Description Поле username принимает логин или подтвержденный адрес почты.
+// @Description После серии неудач по аккаунту или с одного IP вход временно блокируется: 429 с Retry-After.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -131,6 +217,7 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} models.AuthResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
@@ -147,42 +234,67 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.userRepo.FindByUsername(username)
+	user, err := h.userRepo.FindByLogin(r.Context(), username)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithServerError(w, r, err, "failed to find user", "Failed to login")
+		return
+	}
+
+	var userID int64
+	if user != nil {
+		userID = user.ID
+	}
+	account := services.LoginAccountKey(userID, username)
+	ip := clientMetadata(r).IPAddress
+
+	// Попытка засчитывается до bcrypt: параллельные запросы не проскочат порог, пока идет проверка.
+	reservation, err := h.throttle.Reserve(r.Context(), account, ip)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			respondWithError(w, http.StatusUnauthorized, "Invalid username or password")
-			return
-		}
+		respondWithServerError(w, r, err, "failed to check login throttle", "Failed to login")
+		return
+	}
+	if reservation.RetryAfter > 0 {
+		h.metrics.AuthEvent(metrics.AuthLoginThrottled)
+		respondWithRetryAfter(w, reservation.RetryAfter, "Too many failed login attempts, try again later")
+		return
+	}
 
-		log.Printf("Error finding user: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to login")
+	if user == nil {
+		// bcrypt против фиктивного хеша: ответ для неизвестного логина не быстрее, чем для неверного пароля.
+		h.auth.VerifyDummyPassword(req.Password)
+		h.rejectLogin(w)
 		return
 	}
 
 	if err := h.auth.VerifyPassword(req.Password, user.PasswordHash); err != nil {
 		if errors.Is(err, services.ErrInvalidCredentials) {
-			respondWithError(w, http.StatusUnauthorized, "Invalid username or password")
+			h.recordSecurityEvent(r, user.ID, services.SecurityEventLoginFailed, nil)
+			h.rejectLogin(w)
 			return
 		}
 
-		log.Printf("Error verifying password: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to login")
+		respondWithServerError(w, r, err, "failed to verify password", "Failed to login")
 		return
 	}
 
-	accessToken, err := h.auth.GenerateAccessToken(user.ID, user.Username)
+	if err := h.throttle.RecordSuccess(r.Context(), reservation); err != nil {
+		logger.FromContext(r.Context()).Error("failed to reset login throttle", "error", err)
+	}
+
+	familyID := uuid.NewString()
+	accessToken, err := h.auth.GenerateAccessToken(user, familyID)
 	if err != nil {
-		log.Printf("Error generating access token: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to login")
+		respondWithServerError(w, r, err, "failed to generate access token", "Failed to login")
 		return
 	}
 
-	if err := h.issueRefreshSessionAndSetCookie(w, user.ID); err != nil {
-		log.Printf("Error issuing refresh session on login: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to login")
+	rememberMe := req.RememberMe == nil || *req.RememberMe
+	if err := h.issueRefreshSessionAndSetCookie(w, r, user.ID, familyID, rememberMe); err != nil {
+		respondWithServerError(w, r, err, "failed to issue refresh session on login", "Failed to login")
 		return
 	}
 
+	h.metrics.AuthEvent(metrics.AuthLoginSuccess)
 	respondWithJSON(w, http.StatusOK, models.AuthResponse{
 		AccessToken: accessToken,
 		User:        toUserResponse(user),
@@ -191,10 +303,16 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 // Refresh godoc
 // @Summary Refresh access token
+// @Description Повторный запрос с только что использованным токеном (параллельные вкладки)
+// @Description в течение grace-окна получает ту же новую пару на любом инстансе. 409 — пару выдала версия сервиса, которая ее не сохраняет
+// @Description (rolling deploy): клиент повторяет запрос с новой cookie.
+// @Description Вход, превысивший абсолютный срок или простоявший дольше idle timeout, завершается: 401 с code
+// @Description session_lifetime_exceeded или session_idle_timeout.
 // @Tags auth
 // @Produce json
 // @Success 200 {object} models.AuthResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /auth/refresh [post]
 func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
@@ -205,7 +323,12 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 	}
 
 	hashedToken := h.auth.HashRefreshToken(refreshToken)
-	session, err := h.refreshRepo.FindByTokenHash(hashedToken)
+	// Вторая вкладка с тем же токеном ждет первую и получает ее пару из grace-окна.
+	// Между инстансами гонку разрешает RotateSession (см. resolveRotationConflict).
+	unlock := h.grace.Lock(hashedToken)
+	defer unlock()
+
+	session, err := h.refreshRepo.FindByTokenHash(r.Context(), hashedToken)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			h.clearRefreshCookie(w)
@@ -213,19 +336,28 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		log.Printf("Error reading refresh session: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to refresh session")
+		respondWithServerError(w, r, err, "failed to read refresh session", "Failed to refresh session")
 		return
 	}
 
-	if session.RevokedAt != nil || session.ConsumedAt != nil || session.ReplacedBySessionID != nil || session.ExpiresAt.Before(time.Now().UTC()) {
-		_ = h.refreshRepo.RevokeFamily(session.FamilyID, "refresh token reuse or expired token")
-		h.clearRefreshCookie(w)
-		respondWithError(w, http.StatusUnauthorized, "Refresh token is not active")
+	if h.replayWithinGrace(w, r, refreshToken, session) {
 		return
 	}
 
-	user, err := h.userRepo.FindByID(session.UserID)
+	now := time.Now().UTC()
+	isActive := session.RevokedAt == nil && session.ConsumedAt == nil && session.ReplacedBySessionID == nil
+	if isActive {
+		if code, message := h.sessions.endReason(session, now); code != "" {
+			h.endSessionByPolicy(w, r, session, code, message)
+			return
+		}
+	}
+	if !isActive || session.ExpiresAt.Before(now) {
+		h.rejectInactiveRefresh(w, r, session)
+		return
+	}
+
+	user, err := h.userRepo.FindByID(r.Context(), session.UserID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			h.clearRefreshCookie(w)
@@ -233,100 +365,303 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		log.Printf("Error finding user during refresh: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to refresh session")
+		respondWithServerError(w, r, err, "failed to find user during refresh", "Failed to refresh session")
 		return
 	}
 
-	accessToken, err := h.auth.GenerateAccessToken(user.ID, user.Username)
+	accessToken, err := h.auth.GenerateAccessToken(user, session.FamilyID)
 	if err != nil {
-		log.Printf("Error generating access token on refresh: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to refresh session")
+		respondWithServerError(w, r, err, "failed to generate access token on refresh", "Failed to refresh session")
 		return
 	}
 
 	newRefreshToken, newRefreshHash, err := h.auth.GenerateRefreshToken()
 	if err != nil {
-		log.Printf("Error generating refresh token on refresh: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to refresh session")
+		respondWithServerError(w, r, err, "failed to generate refresh token on refresh", "Failed to refresh session")
 		return
 	}
 
+	newExpiresAt := h.sessions.expiresAt(session.FamilyIssuedAt, now, session.RememberMe)
+	// Пара сохраняется вместе с ротацией: повтор в grace-окне возможен с любого инстанса.
+	gracePair, err := h.grace.Seal(refreshToken, services.RotatedTokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     newRefreshToken,
+		RefreshExpiresAt: newExpiresAt,
+		RememberMe:       session.RememberMe,
+	})
+	if err != nil {
+		respondWithServerError(w, r, err, "failed to seal rotated token pair", "Failed to refresh session")
+		return
+	}
 	if err := h.refreshRepo.RotateSession(
+		r.Context(),
 		session.ID,
 		session.UserID,
 		session.FamilyID,
 		newRefreshHash,
-		time.Now().UTC().Add(h.refreshTTL),
+		newExpiresAt,
+		gracePair,
+		clientMetadata(r),
 	); err != nil {
-		log.Printf("Error rotating refresh token: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to refresh session")
+		if errors.Is(err, repository.ErrRefreshRotationConflict) {
+			h.resolveRotationConflict(w, r, refreshToken, hashedToken)
+			return
+		}
+		respondWithServerError(w, r, err, "failed to rotate refresh token", "Failed to refresh session")
 		return
 	}
 
-	h.setRefreshCookie(w, newRefreshToken, time.Now().UTC().Add(h.refreshTTL))
+	h.setRefreshCookie(w, newRefreshToken, newExpiresAt, session.RememberMe)
 	respondWithJSON(w, http.StatusOK, models.AuthResponse{
 		AccessToken: accessToken,
 		User:        toUserResponse(user),
 	})
 }
 
+// rejectInactiveRefresh отвечает 401 на refresh неактивным токеном. Повторное использование
+// или истекший токен отзывают всю семью и пишутся в журнал событий безопасности.
+func (h *AuthHandler) rejectInactiveRefresh(w http.ResponseWriter, r *http.Request, session *models.RefreshSession) {
+	isReused := session.RevokedAt != nil || session.ConsumedAt != nil || session.ReplacedBySessionID != nil
+	details := map[string]string{"family_id": session.FamilyID}
+	if isReused {
+		h.metrics.AuthEvent(metrics.AuthRefreshReuseDetected)
+		h.recordSecurityEvent(r, session.UserID, services.SecurityEventRefreshReuse, details)
+	} else {
+		h.recordSecurityEvent(r, session.UserID, services.SecurityEventRefreshExpired, details)
+	}
+	// Отзыв семьи не должен прерываться, если клиент (возможно, атакующий) оборвал соединение.
+	revokeCtx := context.WithoutCancel(r.Context())
+	if err := h.refreshRepo.RevokeFamily(revokeCtx, session.FamilyID, "refresh token reuse or expired token"); err != nil {
+		logger.FromContext(r.Context()).Error("failed to revoke refresh family", "error", err)
+	} else {
+		h.metrics.AuthEvent(metrics.AuthFamilyRevoked)
+	}
+	h.clearRefreshCookie(w)
+	respondWithError(w, http.StatusUnauthorized, "Refresh token is not active")
+}
+
+// endSessionByPolicy завершает вход, исчерпавший абсолютный срок или idle timeout:
+// семья отзывается, а клиент получает 401 с кодом причины.
+func (h *AuthHandler) endSessionByPolicy(w http.ResponseWriter, r *http.Request, session *models.RefreshSession, code string, message string) {
+	if err := h.refreshRepo.RevokeFamily(context.WithoutCancel(r.Context()), session.FamilyID, code); err != nil {
+		logger.FromContext(r.Context()).Error("failed to revoke refresh family", "error", err)
+	}
+	logger.FromContext(r.Context()).Info("session ended by lifetime policy", "user_id", session.UserID, "family_id", session.FamilyID, "reason", code)
+	h.clearRefreshCookie(w)
+	respondWithErrorCode(w, http.StatusUnauthorized, code, message)
+}
+
+// resolveRotationConflict обрабатывает проигранную гонку ротации: параллельный запрос
+// с тем же токеном успел первым. Сессия перечитывается, и дальше действуют обычные правила —
+// grace-окно для вкладок либо отзыв семьи при повторном использовании.
+func (h *AuthHandler) resolveRotationConflict(w http.ResponseWriter, r *http.Request, refreshToken string, tokenHash string) {
+	session, err := h.refreshRepo.FindByTokenHash(r.Context(), tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.clearRefreshCookie(w)
+			respondWithError(w, http.StatusUnauthorized, "Invalid refresh token")
+			return
+		}
+
+		respondWithServerError(w, r, err, "failed to re-read refresh session after rotation conflict", "Failed to refresh session")
+		return
+	}
+
+	if h.replayWithinGrace(w, r, refreshToken, session) {
+		return
+	}
+	h.rejectInactiveRefresh(w, r, session)
+}
+
+// replayWithinGrace обрабатывает повторный refresh уже использованным токеном в grace-окне.
+// Условия: сессия не отозвана, использована недавно, а ее преемник еще не использован
+// и не отозван — значит, это гонка вкладок, а не кража токена. Пара расшифровывается из
+// grace_pair сессии сырым refreshToken. Возвращает true, если ответ записан.
+func (h *AuthHandler) replayWithinGrace(w http.ResponseWriter, r *http.Request, refreshToken string, session *models.RefreshSession) bool {
+	if session.RevokedAt != nil || session.ConsumedAt == nil || session.ReplacedBySessionID == nil {
+		return false
+	}
+	if !h.grace.Within(*session.ConsumedAt) {
+		return false
+	}
+
+	successor, err := h.refreshRepo.FindByID(r.Context(), *session.ReplacedBySessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false
+		}
+		respondWithServerError(w, r, err, "failed to read successor refresh session", "Failed to refresh session")
+		return true
+	}
+	if successor.RevokedAt != nil || successor.ConsumedAt != nil || !successor.ExpiresAt.After(time.Now().UTC()) {
+		return false
+	}
+
+	pair, err := h.grace.Open(refreshToken, session.GracePair)
+	if err != nil {
+		// Пару не сохранила прежняя версия сервиса (rolling deploy) или ее не удалось
+		// расшифровать: семью не трогаем, браузер уже получил новую cookie и повторит запрос с ней.
+		if !errors.Is(err, services.ErrGracePairUnavailable) {
+			logger.FromContext(r.Context()).Warn("failed to open grace pair", "family_id", session.FamilyID, "error", err)
+		}
+		respondWithError(w, http.StatusConflict, "Refresh token was just rotated, retry the request")
+		return true
+	}
+
+	user, err := h.userRepo.FindByID(r.Context(), session.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.clearRefreshCookie(w)
+			respondWithError(w, http.StatusUnauthorized, "User not found")
+			return true
+		}
+		respondWithServerError(w, r, err, "failed to find user during refresh", "Failed to refresh session")
+		return true
+	}
+
+	h.metrics.AuthEvent(metrics.AuthRefreshGraceReplay)
+	logger.FromContext(r.Context()).Info("refresh replayed within grace window", "user_id", session.UserID, "family_id", session.FamilyID)
+	h.setRefreshCookie(w, pair.RefreshToken, pair.RefreshExpiresAt, pair.RememberMe)
+	respondWithJSON(w, http.StatusOK, models.AuthResponse{
+		AccessToken: pair.AccessToken,
+		User:        toUserResponse(user),
+	})
+	return true
+}
+
 // Logout godoc
 // @Summary Logout user
+// @Description Отзывает refresh-сессию из cookie и, если передан валидный Bearer-токен, сам access-токен.
 // @Tags auth
 // @Success 204 "No Content"
 // @Failure 500 {object} models.ErrorResponse
 // @Router /auth/logout [post]
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	// Logout доступен и без access-токена (он мог истечь), поэтому Bearer опционален.
+	if token, err := middleware.BearerToken(r); err == nil {
+		if claims, err := h.auth.ValidateAccessToken(token); err == nil {
+			if err := h.revocation.RevokeToken(r.Context(), claims); err != nil {
+				respondWithServerError(w, r, err, "failed to revoke access token on logout", "Failed to logout")
+				return
+			}
+		}
+	}
+
 	refreshToken, err := h.readRefreshCookie(r)
 	if err == nil && strings.TrimSpace(refreshToken) != "" {
 		tokenHash := h.auth.HashRefreshToken(refreshToken)
-		if revokeErr := h.refreshRepo.RevokeByTokenHash(tokenHash, "user logout"); revokeErr != nil {
-			log.Printf("Error revoking refresh session on logout: %v", revokeErr)
-			respondWithError(w, http.StatusInternalServerError, "Failed to logout")
+		if revokeErr := h.refreshRepo.RevokeByTokenHash(r.Context(), tokenHash, "user logout"); revokeErr != nil {
+			respondWithServerError(w, r, revokeErr, "failed to revoke refresh session on logout", "Failed to logout")
+			return
+		}
+	}
+
+	h.clearRefreshCookie(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll godoc
+// @Summary Logout from all devices
+// @Description Отзывает все access-токены пользователя (версия токенов) и все его refresh-сессии.
+// @Tags auth
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/logout-all [post]
+func (h *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Доводим отзыв до конца, даже если клиент оборвал соединение.
+	ctx := context.WithoutCancel(r.Context())
+	if _, err := h.revocation.RevokeAllForUser(ctx, userID); err != nil {
+		respondWithServerError(w, r, err, "failed to revoke access tokens", "Failed to logout")
+		return
+	}
+	if claims, ok := middleware.AccessClaimsFromContext(r.Context()); ok {
+		if err := h.revocation.RevokeToken(ctx, claims); err != nil {
+			respondWithServerError(w, r, err, "failed to revoke current access token", "Failed to logout")
 			return
 		}
 	}
+	if _, err := h.refreshRepo.RevokeAllForUser(ctx, userID, "logout everywhere"); err != nil {
+		respondWithServerError(w, r, err, "failed to revoke refresh sessions", "Failed to logout")
+		return
+	}
 
 	h.clearRefreshCookie(w)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// recordSecurityEvent пишет событие безопасности с данными клиента из запроса.
+func (h *AuthHandler) recordSecurityEvent(r *http.Request, userID int64, eventType string, details map[string]string) {
+	client := clientMetadata(r)
+	h.events.Record(r.Context(), models.SecurityEvent{
+		UserID:    userID,
+		Type:      eventType,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		Details:   details,
+	})
+}
+
+// rejectLogin отвечает 401 на неверный логин или пароль. В LoginThrottle неудача уже
+// засчитана резервом попытки, поэтому здесь ничего не пишется.
+func (h *AuthHandler) rejectLogin(w http.ResponseWriter) {
+	h.metrics.AuthEvent(metrics.AuthLoginFailure)
+	respondWithError(w, http.StatusUnauthorized, "Invalid username or password")
+}
+
 func toUserResponse(user *models.User) models.UserResponse {
-	return models.UserResponse{
+	response := models.UserResponse{
 		ID:        user.ID,
 		Username:  user.Username,
 		CreatedAt: user.CreatedAt,
 	}
+	if user.Email != nil {
+		response.Email = *user.Email
+	}
+	return response
 }
 
-func (h *AuthHandler) issueRefreshSessionAndSetCookie(w http.ResponseWriter, userID int64) error {
+// issueRefreshSessionAndSetCookie начинает новый вход: первую сессию семьи familyID
+// (тот же id несет claim sid выданного вместе с ней access-токена).
+func (h *AuthHandler) issueRefreshSessionAndSetCookie(w http.ResponseWriter, r *http.Request, userID int64, familyID string, rememberMe bool) error {
 	refreshToken, refreshHash, err := h.auth.GenerateRefreshToken()
 	if err != nil {
 		return err
 	}
 
-	familyID := uuid.NewString()
-	expiresAt := time.Now().UTC().Add(h.refreshTTL)
-	if _, err := h.refreshRepo.CreateSession(userID, familyID, refreshHash, expiresAt); err != nil {
+	now := time.Now().UTC()
+	expiresAt := h.sessions.expiresAt(now, now, rememberMe)
+	if _, err := h.refreshRepo.CreateSession(r.Context(), userID, familyID, refreshHash, expiresAt, rememberMe, clientMetadata(r)); err != nil {
 		return err
 	}
 
-	h.setRefreshCookie(w, refreshToken, expiresAt)
+	h.setRefreshCookie(w, refreshToken, expiresAt, rememberMe)
 	return nil
 }
 
-func (h *AuthHandler) setRefreshCookie(w http.ResponseWriter, token string, expiresAt time.Time) {
-	http.SetCookie(w, &http.Cookie{
+// setRefreshCookie выставляет refresh cookie. Непостоянная (persistent=false) выдается без
+// Expires/Max-Age и живет до закрытия браузера; срок на сервере ограничен самой сессией.
+func (h *AuthHandler) setRefreshCookie(w http.ResponseWriter, token string, expiresAt time.Time, persistent bool) {
+	cookie := &http.Cookie{
 		Name:     h.refreshCookie.Name,
 		Value:    token,
 		Path:     h.refreshCookie.Path,
 		Domain:   h.refreshCookie.Domain,
-		Expires:  expiresAt,
 		HttpOnly: h.refreshCookie.HTTPOnly,
 		Secure:   h.refreshCookie.Secure,
 		SameSite: h.refreshCookie.SameSite,
-	})
+	}
+	if persistent {
+		cookie.Expires = expiresAt
+	}
+	http.SetCookie(w, cookie)
 }
 
 func (h *AuthHandler) clearRefreshCookie(w http.ResponseWriter) {
@@ -0,0 +1,551 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"goTodo/backend/models"
+	"goTodo/backend/repository"
+	"goTodo/backend/services"
+)
+
+const (
+	testRefreshCookie = "refresh_token"
+	testRefreshGrace  = 10 * time.Second
+)
+
+// fakeRefreshRepo — refresh-сессии в памяти с той же семантикой RotateSession, что у Postgres:
+// ротирует только неиспользованную и неотозванную сессию, иначе ErrRefreshRotationConflict.
+type fakeRefreshRepo struct {
+	repository.RefreshSessionRepository
+
+	mu       sync.Mutex
+	sessions map[int64]*models.RefreshSession
+	nextID   int64
+	// rotations — успешные ротации, revokedFamilies — семьи, отозванные RevokeFamily.
+	rotations       int
+	revokedFamilies []string
+	// beforeRotate вызывается один раз перед ротацией — чтобы вклинить параллельный запрос.
+	beforeRotate func()
+	// commitLatency — задержка ответа RotateSession после записи, как сетевой round-trip COMMIT:
+	// остальные запросы уже видят использованную сессию, а победитель еще не запомнил пару.
+	commitLatency time.Duration
+}
+
+func newFakeRefreshRepo() *fakeRefreshRepo {
+	return &fakeRefreshRepo{sessions: make(map[int64]*models.RefreshSession)}
+}
+
+func (r *fakeRefreshRepo) add(userID int64, familyID string, tokenHash string, now time.Time) *models.RefreshSession {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	session := &models.RefreshSession{
+		ID:             r.nextID,
+		UserID:         userID,
+		TokenHash:      tokenHash,
+		FamilyID:       familyID,
+		FamilyIssuedAt: now,
+		RememberMe:     true,
+		IssuedAt:       now,
+		ExpiresAt:      now.Add(time.Hour),
+	}
+	r.sessions[session.ID] = session
+	return session
+}
+
+func (r *fakeRefreshRepo) CreateSession(_ context.Context, userID int64, familyID string, tokenHash string, expiresAt time.Time, rememberMe bool, _ models.ClientMetadata) (int64, error) {
+	session := r.add(userID, familyID, tokenHash, time.Now().UTC())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session.ExpiresAt = expiresAt
+	session.RememberMe = rememberMe
+	return session.ID, nil
+}
+
+func (r *fakeRefreshRepo) FindByTokenHash(_ context.Context, tokenHash string) (*models.RefreshSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, session := range r.sessions {
+		if session.TokenHash == tokenHash {
+			copied := *session
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *fakeRefreshRepo) FindByID(_ context.Context, sessionID int64) (*models.RefreshSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[sessionID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (r *fakeRefreshRepo) RotateSession(_ context.Context, oldSessionID int64, userID int64, familyID string, newTokenHash string, newExpiresAt time.Time, gracePair []byte, _ models.ClientMetadata) error {
+	r.mu.Lock()
+	hook := r.beforeRotate
+	r.beforeRotate = nil
+	r.mu.Unlock()
+	if hook != nil {
+		hook()
+	}
+
+	r.mu.Lock()
+	old := r.sessions[oldSessionID]
+	if old == nil || old.ConsumedAt != nil || old.RevokedAt != nil {
+		r.mu.Unlock()
+		return repository.ErrRefreshRotationConflict
+	}
+
+	now := time.Now().UTC()
+	r.nextID++
+	successor := &models.RefreshSession{
+		ID:             r.nextID,
+		UserID:         userID,
+		TokenHash:      newTokenHash,
+		FamilyID:       familyID,
+		FamilyIssuedAt: old.FamilyIssuedAt,
+		RememberMe:     old.RememberMe,
+		IssuedAt:       now,
+		ExpiresAt:      newExpiresAt,
+	}
+	r.sessions[successor.ID] = successor
+	old.ConsumedAt = &now
+	old.ReplacedBySessionID = &successor.ID
+	old.GracePair = gracePair
+	for _, session := range r.sessions {
+		if session.ReplacedBySessionID != nil && *session.ReplacedBySessionID == oldSessionID {
+			session.GracePair = nil
+		}
+	}
+	r.rotations++
+	latency := r.commitLatency
+	r.mu.Unlock()
+
+	time.Sleep(latency)
+	return nil
+}
+
+func (r *fakeRefreshRepo) RevokeFamily(_ context.Context, familyID string, _ string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	for _, session := range r.sessions {
+		if session.FamilyID == familyID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+		}
+	}
+	r.revokedFamilies = append(r.revokedFamilies, familyID)
+	return nil
+}
+
+func (r *fakeRefreshRepo) RevokeOtherFamilies(_ context.Context, userID int64, keepFamilyID string, _ string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	revoked := make(map[string]bool)
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.FamilyID != keepFamilyID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			revoked[session.FamilyID] = true
+		}
+	}
+	for familyID := range revoked {
+		r.revokedFamilies = append(r.revokedFamilies, familyID)
+	}
+	return int64(len(revoked)), nil
+}
+
+// familyRevoked сообщает, отозваны ли все сессии семьи.
+func (r *fakeRefreshRepo) familyRevoked(familyID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, session := range r.sessions {
+		if session.FamilyID == familyID && session.RevokedAt == nil {
+			return false
+		}
+	}
+	return true
+}
+
+type fakeUserRepo struct {
+	repository.UserRepository
+
+	mu   sync.Mutex
+	user *models.User
+}
+
+func (r *fakeUserRepo) FindByID(_ context.Context, id int64) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.user == nil || r.user.ID != id {
+		return nil, sql.ErrNoRows
+	}
+	copied := *r.user
+	return &copied, nil
+}
+
+func (r *fakeUserRepo) FindByLogin(_ context.Context, login string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.user == nil || (r.user.Username != login && (r.user.Email == nil || *r.user.Email != strings.ToLower(login))) {
+		return nil, sql.ErrNoRows
+	}
+	copied := *r.user
+	return &copied, nil
+}
+
+func (r *fakeUserRepo) UpdatePassword(_ context.Context, id int64, passwordHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.user == nil || r.user.ID != id {
+		return sql.ErrNoRows
+	}
+	r.user.PasswordHash = passwordHash
+	return nil
+}
+
+// fakeTokenRevocation — версии токенов пользователей в памяти, как users.token_version.
+type fakeTokenRevocation struct {
+	mu       sync.Mutex
+	versions map[int64]int64
+}
+
+func (f *fakeTokenRevocation) Check(_ context.Context, claims *services.AccessTokenClaims) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if claims.TokenVersion < f.versions[claims.UserID] {
+		return services.ErrTokenRevoked
+	}
+	return nil
+}
+
+func (f *fakeTokenRevocation) RevokeToken(context.Context, *services.AccessTokenClaims) error {
+	return nil
+}
+
+func (f *fakeTokenRevocation) RevokeAllForUser(_ context.Context, userID int64) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.versions == nil {
+		f.versions = make(map[int64]int64)
+	}
+	f.versions[userID]++
+	return f.versions[userID], nil
+}
+
+// fakeSecurityEvents запоминает типы записанных событий.
+type fakeSecurityEvents struct {
+	mu    sync.Mutex
+	types []string
+}
+
+func (e *fakeSecurityEvents) Record(_ context.Context, event models.SecurityEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.types = append(e.types, event.Type)
+}
+
+func (e *fakeSecurityEvents) List(context.Context, int64, int) ([]models.SecurityEvent, error) {
+	return nil, nil
+}
+
+func (e *fakeSecurityEvents) recorded(eventType string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, recorded := range e.types {
+		if recorded == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type refreshTestEnv struct {
+	auth       services.AuthService
+	repo       *fakeRefreshRepo
+	users      *fakeUserRepo
+	events     *fakeSecurityEvents
+	revocation *fakeTokenRevocation
+	throttle   services.LoginThrottle
+	sessions   SessionPolicy
+	cookie     RefreshCookieConfig
+	token      string
+	family     string
+}
+
+// newRefreshTestEnv создает пользователя с одной активной refresh-сессией; token — ее cookie.
+func newRefreshTestEnv(t *testing.T) *refreshTestEnv {
+	t.Helper()
+
+	keys := services.NewStaticKeySource([]services.SigningKey{
+		services.NewHMACKey("test", []byte("0123456789abcdef0123456789abcdef")),
+	}, "test")
+	ring, err := services.NewKeyRing(keys, time.Minute, nil)
+	if err != nil {
+		t.Fatalf("NewKeyRing: %v", err)
+	}
+	auth, err := services.NewAuthService(ring, time.Minute, time.Hour, services.TokenClaimsConfig{Issuer: "test", Audience: "test"})
+	if err != nil {
+		t.Fatalf("NewAuthService: %v", err)
+	}
+
+	token, tokenHash, err := auth.GenerateRefreshToken()
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+
+	env := &refreshTestEnv{
+		auth:       auth,
+		repo:       newFakeRefreshRepo(),
+		users:      &fakeUserRepo{user: &models.User{ID: 1, Username: "alice"}},
+		events:     &fakeSecurityEvents{},
+		revocation: &fakeTokenRevocation{},
+		throttle:   services.NewNopLoginThrottle(),
+		sessions:   SessionPolicy{RefreshTTL: time.Hour, ShortRefreshTTL: time.Hour},
+		cookie:     RefreshCookieConfig{Name: testRefreshCookie, Path: "/"},
+		token:      token,
+		family:     "family-1",
+	}
+	env.repo.add(1, env.family, tokenHash, time.Now().UTC())
+	return env
+}
+
+// newHandler создает AuthHandler со своими блокировками refresh — как отдельный инстанс сервера.
+func (e *refreshTestEnv) newHandler() *AuthHandler {
+	return NewAuthHandler(
+		e.users,
+		e.repo,
+		e.auth,
+		e.revocation,
+		e.events,
+		services.NewRefreshGrace(testRefreshGrace),
+		services.NewPasswordPolicy(services.PasswordPolicyConfig{MinLength: 8}, nil),
+		e.throttle,
+		e.sessions,
+		e.cookie,
+		nil,
+	)
+}
+
+func (e *refreshTestEnv) refresh(h *AuthHandler) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: testRefreshCookie, Value: e.token})
+	rec := httptest.NewRecorder()
+	h.Refresh(rec, req)
+	return rec
+}
+
+func refreshCookieValue(rec *httptest.ResponseRecorder) string {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == testRefreshCookie {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+func TestRefreshConcurrentSameTokenRotatesOnce(t *testing.T) {
+	env := newRefreshTestEnv(t)
+	env.repo.commitLatency = 20 * time.Millisecond
+	h := env.newHandler()
+
+	const requests = 8
+	var (
+		wg      sync.WaitGroup
+		start   = make(chan struct{})
+		results = make([]*httptest.ResponseRecorder, requests)
+	)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			results[i] = env.refresh(h)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	if env.repo.rotations != 1 {
+		t.Fatalf("rotations = %d, want 1", env.repo.rotations)
+	}
+
+	cookie := refreshCookieValue(results[0])
+	if cookie == "" || cookie == env.token {
+		t.Fatalf("first response did not set a new refresh cookie: %q", cookie)
+	}
+	for i, rec := range results {
+		if rec.Code != http.StatusOK {
+			t.Fatalf("response %d: status = %d, want 200 (body %s)", i, rec.Code, rec.Body)
+		}
+		if got := refreshCookieValue(rec); got != cookie {
+			t.Fatalf("response %d: refresh cookie = %q, want the grace replay %q", i, got, cookie)
+		}
+	}
+
+	if len(env.repo.revokedFamilies) != 0 {
+		t.Fatalf("families revoked: %v, want none", env.repo.revokedFamilies)
+	}
+	if env.events.recorded(services.SecurityEventRefreshReuse) {
+		t.Fatal("refresh_token_reuse recorded for a tab race")
+	}
+}
+
+func TestRefreshRotationConflictWithinGraceReplaysPair(t *testing.T) {
+	env := newRefreshTestEnv(t)
+	h := env.newHandler()
+
+	// Пока этот инстанс ротирует сессию, тот же токен успевает повернуть другой
+	// инстанс (other): ротация здесь проигрывает гонку в RotateSession.
+	other := env.newHandler()
+	var winner *httptest.ResponseRecorder
+	env.repo.beforeRotate = func() { winner = env.refresh(other) }
+
+	loser := env.refresh(h)
+
+	if env.repo.rotations != 1 {
+		t.Fatalf("rotations = %d, want 1", env.repo.rotations)
+	}
+	if winner == nil || winner.Code != http.StatusOK {
+		t.Fatalf("winner response = %v, want 200", winner)
+	}
+	// Пару выдал другой инстанс, но она сохранена в БД — проигравший отдает ту же пару.
+	if loser.Code != http.StatusOK {
+		t.Fatalf("loser status = %d, want 200 (body %s)", loser.Code, loser.Body)
+	}
+	if got, want := refreshCookieValue(loser), refreshCookieValue(winner); got == "" || got != want {
+		t.Fatalf("loser refresh cookie = %q, want the winner's %q", got, want)
+	}
+	if len(env.repo.revokedFamilies) != 0 {
+		t.Fatalf("families revoked: %v, want none", env.repo.revokedFamilies)
+	}
+}
+
+func TestRefreshReplayOnAnotherInstance(t *testing.T) {
+	tests := []struct {
+		name       string
+		legacyRow  bool
+		wantStatus int
+	}{
+		{name: "pair stored with the rotation", wantStatus: http.StatusOK},
+		// Ротацию выполнила версия без grace_pair (rolling deploy).
+		{name: "rotated by an older version", legacyRow: true, wantStatus: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newRefreshTestEnv(t)
+			first := env.refresh(env.newHandler())
+			if first.Code != http.StatusOK {
+				t.Fatalf("first refresh status = %d, want 200 (body %s)", first.Code, first.Body)
+			}
+			if tt.legacyRow {
+				env.repo.mu.Lock()
+				for _, session := range env.repo.sessions {
+					session.GracePair = nil
+				}
+				env.repo.mu.Unlock()
+			}
+
+			// Вторая вкладка попадает на другой инстанс, который ничего не знает о первой ротации.
+			replay := env.refresh(env.newHandler())
+			if replay.Code != tt.wantStatus {
+				t.Fatalf("replay status = %d, want %d (body %s)", replay.Code, tt.wantStatus, replay.Body)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if got, want := refreshCookieValue(replay), refreshCookieValue(first); got != want {
+					t.Fatalf("replay refresh cookie = %q, want %q", got, want)
+				}
+			}
+			if env.repo.rotations != 1 {
+				t.Fatalf("rotations = %d, want 1", env.repo.rotations)
+			}
+			if len(env.repo.revokedFamilies) != 0 {
+				t.Fatalf("families revoked: %v, want none", env.repo.revokedFamilies)
+			}
+		})
+	}
+}
+
+func TestRefreshGracePairIsClearedAfterNextRotation(t *testing.T) {
+	env := newRefreshTestEnv(t)
+	h := env.newHandler()
+
+	first := env.refresh(h)
+	if first.Code != http.StatusOK {
+		t.Fatalf("first refresh status = %d, want 200 (body %s)", first.Code, first.Body)
+	}
+	original := env.token
+	env.token = refreshCookieValue(first)
+	if second := env.refresh(h); second.Code != http.StatusOK {
+		t.Fatalf("second refresh status = %d, want 200 (body %s)", second.Code, second.Body)
+	}
+
+	env.repo.mu.Lock()
+	defer env.repo.mu.Unlock()
+	for _, session := range env.repo.sessions {
+		if session.TokenHash == env.auth.HashRefreshToken(original) && session.GracePair != nil {
+			t.Fatal("grace pair of the first session survived the rotation of its successor")
+		}
+		if session.TokenHash == env.auth.HashRefreshToken(env.token) && session.GracePair == nil {
+			t.Fatal("grace pair of the just rotated session is missing")
+		}
+	}
+}
+
+func TestRefreshReplayOutsideGraceRevokesFamily(t *testing.T) {
+	env := newRefreshTestEnv(t)
+	h := env.newHandler()
+
+	if rec := env.refresh(h); rec.Code != http.StatusOK {
+		t.Fatalf("first refresh status = %d, want 200 (body %s)", rec.Code, rec.Body)
+	}
+
+	// Сдвигаем момент использования за grace-окно.
+	env.repo.mu.Lock()
+	for _, session := range env.repo.sessions {
+		if session.ConsumedAt != nil {
+			consumedAt := session.ConsumedAt.Add(-2 * testRefreshGrace)
+			session.ConsumedAt = &consumedAt
+		}
+	}
+	env.repo.mu.Unlock()
+
+	rec := env.refresh(h)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("replay status = %d, want 401 (body %s)", rec.Code, rec.Body)
+	}
+	if len(env.repo.revokedFamilies) != 1 || env.repo.revokedFamilies[0] != env.family {
+		t.Fatalf("revoked families = %v, want [%s]", env.repo.revokedFamilies, env.family)
+	}
+	if !env.events.recorded(services.SecurityEventRefreshReuse) {
+		t.Fatal("refresh_token_reuse was not recorded")
+	}
+	if env.repo.rotations != 1 {
+		t.Fatalf("rotations = %d, want 1", env.repo.rotations)
+	}
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"goTodo/backend/metrics"
+	"goTodo/backend/middleware"
+	"goTodo/backend/models"
+	"goTodo/backend/repository"
+	"goTodo/backend/services"
+)
+
+// emailVerificationSentMessage — один ответ и для свободного, и для занятого адреса,
+// чтобы по нему нельзя было узнать, зарегистрирован ли адрес.
+const emailVerificationSentMessage = "If the address can be used, a verification link has been sent to it"
+
+// EmailHandler обрабатывает привязку и подтверждение адреса почты.
+type EmailHandler struct {
+	verification services.EmailVerification
+	userRepo     repository.UserRepository
+	auth         services.AuthService
+	throttle     services.LoginThrottle
+	metrics      *metrics.Metrics
+}
+
+// NewEmailHandler создает обработчик адреса почты.
+// Параметры: verification — подтверждение адреса; userRepo и auth — проверка текущего пароля;
+// throttle — счетчик неудачных проверок пароля (общий со входом); metrics — может быть nil.
+func NewEmailHandler(
+	verification services.EmailVerification,
+	userRepo repository.UserRepository,
+	auth services.AuthService,
+	throttle services.LoginThrottle,
+	metrics *metrics.Metrics,
+) *EmailHandler {
+	return &EmailHandler{
+		verification: verification,
+		userRepo:     userRepo,
+		auth:         auth,
+		throttle:     throttle,
+		metrics:      metrics,
+	}
+}
+
+// ChangeEmail godoc
+// @Summary Set email address
+// @Description Отправляет ссылку подтверждения на новый адрес. Адрес привязывается к аккаунту
+// @Description (и по нему можно входить) только после перехода по ссылке. Частота писем ограничена: 429 с Retry-After.
+// @Description Требует текущий пароль (403 при неверном). Ответ для занятого адреса тот же 202, ссылка на него не отправляется.
+// @Tags me
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.ChangeEmailRequest true "New email"
+// @Success 202 {object} models.MessageResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /me/email [post]
+func (h *EmailHandler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.ChangeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.Email == "" || req.CurrentPassword == "" {
+		respondWithError(w, http.StatusBadRequest, "Fields 'email' and 'currentPassword' are required")
+		return
+	}
+
+	user, err := h.userRepo.FindByID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusUnauthorized, "User not found")
+			return
+		}
+
+		respondWithServerError(w, r, err, "failed to find user", "Failed to change email")
+		return
+	}
+
+	if !verifyCurrentPassword(w, r, h.auth, h.throttle, h.metrics, user, req.CurrentPassword, "Failed to change email") {
+		return
+	}
+
+	if err := h.verification.Request(r.Context(), userID, req.Email); err != nil {
+		h.respondWithVerificationError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, models.MessageResponse{Message: emailVerificationSentMessage})
+}
+
+// ResendEmailVerification godoc
+// @Summary Resend email verification
+// @Description Повторно отправляет ссылку на адрес, ожидающий подтверждения. Частота писем ограничена: 429 с Retry-After.
+// @Tags me
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} models.MessageResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /me/email/resend [post]
+func (h *EmailHandler) ResendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.verification.Resend(r.Context(), userID); err != nil {
+		h.respondWithVerificationError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, models.MessageResponse{Message: "Verification link has been sent again"})
+}
+
+// VerifyEmail godoc
+// @Summary Confirm email address
+// @Description Подтверждает адрес по токену из письма. Не требует входа: ссылку могут открыть в другом браузере.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.VerifyEmailRequest true "Verification token"
+// @Success 204
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/email/verify [post]
+func (h *EmailHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req models.VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.Token == "" {
+		respondWithError(w, http.StatusBadRequest, "Field 'token' is required")
+		return
+	}
+
+	if err := h.verification.Confirm(r.Context(), req.Token, clientMetadata(r)); err != nil {
+		h.respondWithVerificationError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *EmailHandler) respondWithVerificationError(w http.ResponseWriter, r *http.Request, err error) {
+	var rateLimited *services.RateLimitError
+	switch {
+	case errors.As(err, &rateLimited):
+		respondWithRetryAfter(w, rateLimited.RetryAfter, "Too many verification emails, try again later")
+	case errors.Is(err, services.ErrInvalidEmail):
+		respondWithError(w, http.StatusBadRequest, "Field 'email' must be a valid email address")
+	case errors.Is(err, services.ErrInvalidVerificationToken):
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired verification token")
+	case errors.Is(err, services.ErrEmailTaken):
+		// Адрес заняли, пока письмо ждало подтверждения; 409 выдал бы, что он зарегистрирован.
+		respondWithError(w, http.StatusBadRequest, "This address cannot be linked to the account")
+	case errors.Is(err, services.ErrEmailAlreadyVerified):
+		respondWithError(w, http.StatusConflict, "Email is already verified for this account")
+	case errors.Is(err, services.ErrNoPendingEmail):
+		respondWithError(w, http.StatusNotFound, "No email is waiting for verification")
+	default:
+		respondWithServerError(w, r, err, "failed to verify email", "Failed to verify email")
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+
+	"goTodo/backend/health"
+	"goTodo/backend/logger"
+	"goTodo/backend/models"
+)
+
+// HealthHandler отдает liveness/readiness пробы.
+// Liveness отвечает, жив ли процесс; readiness — готов ли инстанс принимать трафик
+// (доступны ли зависимости, применены ли миграции, не идет ли остановка).
+type HealthHandler struct {
+	checks *health.Registry
+}
+
+func NewHealthHandler(checks *health.Registry) *HealthHandler {
+	return &HealthHandler{checks: checks}
+}
+
+// Livez всегда отвечает 200, пока процесс способен обрабатывать запросы.
+func (h *HealthHandler) Livez(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, models.HealthResponse{Status: health.StatusOK})
+}
+
+// Readyz выполняет все зарегистрированные проверки и отдает детали по каждой.
+// Если хотя бы одна проверка не прошла, отвечает 503.
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := h.checks.Run(r.Context())
+
+	response := models.HealthResponse{
+		Status: report.Status,
+		Checks: make(map[string]models.HealthCheckResponse, len(report.Checks)),
+	}
+	for _, check := range report.Checks {
+		response.Checks[check.Name] = models.HealthCheckResponse{
+			Status:     check.Status,
+			DurationMs: float64(check.Duration.Microseconds()) / 1000,
+			Error:      check.Error,
+		}
+	}
+
+	code := http.StatusOK
+	if report.Status != health.StatusOK {
+		code = http.StatusServiceUnavailable
+		logger.FromContext(r.Context()).Warn("readiness check failed", "checks", response.Checks)
+	}
+
+	respondWithJSON(w, code, response)
+}
+
+// Health — устаревшая проба в текстовом формате, оставлена для совместимости.
+// Теперь отражает readiness, а не просто факт запуска процесса.
+func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
+	if report := h.checks.Run(r.Context()); report.Status != health.StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("UNAVAILABLE"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"goTodo/backend/services"
+)
+
+// JWKSHandler публикует публичные ключи проверки access-токенов,
+// чтобы другие сервисы могли валидировать их без общего HMAC-секрета.
+type JWKSHandler struct {
+	auth services.AuthService
+}
+
+func NewJWKSHandler(auth services.AuthService) *JWKSHandler {
+	return &JWKSHandler{auth: auth}
+}
+
+// JWKS отдает набор ключей в формате RFC 7517. Кэш короткий: после ротации
+// (SIGHUP) новый ключ должен появиться у потребителей до того, как им начнут подписывать.
+func (h *JWKSHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, h.auth.PublicJWKS())
+}
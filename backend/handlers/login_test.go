@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"goTodo/backend/models"
+	"goTodo/backend/services"
+)
+
+const testLoginPassword = "correct-password"
+
+// login отправляет POST /api/auth/login с телом body.
+func (e *refreshTestEnv) login(h *AuthHandler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(body))
+	req.RemoteAddr = "203.0.113.10:5000"
+	rec := httptest.NewRecorder()
+	h.Login(rec, req)
+	return rec
+}
+
+func (e *refreshTestEnv) setPassword(t *testing.T, password string) {
+	t.Helper()
+	hash, err := e.auth.HashPassword(password)
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	e.users.user.PasswordHash = hash
+}
+
+func refreshCookie(rec *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == testRefreshCookie {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestLoginRememberMe(t *testing.T) {
+	const (
+		refreshTTL      = 7 * 24 * time.Hour
+		shortRefreshTTL = 12 * time.Hour
+	)
+
+	tests := []struct {
+		name           string
+		rememberMe     string
+		wantPersistent bool
+		wantTTL        time.Duration
+	}{
+		{name: "omitted keeps the persistent cookie", rememberMe: "", wantPersistent: true, wantTTL: refreshTTL},
+		{name: "true", rememberMe: `,"rememberMe":true`, wantPersistent: true, wantTTL: refreshTTL},
+		{name: "false issues a session cookie", rememberMe: `,"rememberMe":false`, wantPersistent: false, wantTTL: shortRefreshTTL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newRefreshTestEnv(t)
+			env.sessions = SessionPolicy{RefreshTTL: refreshTTL, ShortRefreshTTL: shortRefreshTTL}
+			env.setPassword(t, testLoginPassword)
+			h := env.newHandler()
+
+			before := time.Now().UTC()
+			rec := env.login(h, `{"username":"alice","password":"`+testLoginPassword+`"`+tt.rememberMe+`}`)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("login status = %d, want 200 (body %s)", rec.Code, rec.Body)
+			}
+
+			cookie := refreshCookie(rec)
+			if cookie == nil {
+				t.Fatal("login did not set the refresh cookie")
+			}
+			if persistent := !cookie.Expires.IsZero() || cookie.MaxAge > 0; persistent != tt.wantPersistent {
+				t.Fatalf("cookie persistent = %v (Expires %s, MaxAge %d), want %v", persistent, cookie.Expires, cookie.MaxAge, tt.wantPersistent)
+			}
+
+			session, err := env.repo.FindByTokenHash(context.Background(), env.auth.HashRefreshToken(cookie.Value))
+			if err != nil {
+				t.Fatalf("session of the new cookie: %v", err)
+			}
+			wantRememberMe := tt.wantPersistent
+			if session.RememberMe != wantRememberMe {
+				t.Fatalf("session RememberMe = %v, want %v", session.RememberMe, wantRememberMe)
+			}
+			if ttl := session.ExpiresAt.Sub(before); ttl < tt.wantTTL || ttl > tt.wantTTL+time.Minute {
+				t.Fatalf("session ttl = %s, want %s", ttl, tt.wantTTL)
+			}
+
+			// Ротация сохраняет режим входа: сессионная cookie остается сессионной.
+			env.token = cookie.Value
+			refreshed := env.refresh(h)
+			if refreshed.Code != http.StatusOK {
+				t.Fatalf("refresh status = %d, want 200 (body %s)", refreshed.Code, refreshed.Body)
+			}
+			next := refreshCookie(refreshed)
+			if next == nil || next.Value == cookie.Value {
+				t.Fatal("refresh did not rotate the cookie")
+			}
+			if persistent := !next.Expires.IsZero() || next.MaxAge > 0; persistent != tt.wantPersistent {
+				t.Fatalf("rotated cookie persistent = %v, want %v", persistent, tt.wantPersistent)
+			}
+			successor, err := env.repo.FindByTokenHash(context.Background(), env.auth.HashRefreshToken(next.Value))
+			if err != nil {
+				t.Fatalf("successor session: %v", err)
+			}
+			if successor.RememberMe != wantRememberMe {
+				t.Fatalf("successor RememberMe = %v, want %v", successor.RememberMe, wantRememberMe)
+			}
+
+			var response models.AuthResponse
+			if err := json.NewDecoder(refreshed.Body).Decode(&response); err != nil || response.AccessToken == "" {
+				t.Fatalf("refresh response without an access token: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	type attempt struct {
+		login      string
+		password   string
+		wantStatus int
+	}
+	const wrong = "wrong-password"
+	accountPolicy := services.LoginThrottlePolicy{AccountThreshold: 3, IPThreshold: 100, BaseDelay: time.Minute, MaxLockout: time.Hour, Window: time.Hour}
+
+	tests := []struct {
+		name     string
+		policy   services.LoginThrottlePolicy
+		attempts []attempt
+	}{
+		{
+			name:   "account locks after the threshold",
+			policy: accountPolicy,
+			attempts: []attempt{
+				{login: "alice", password: wrong, wantStatus: http.StatusUnauthorized},
+				{login: "alice", password: wrong, wantStatus: http.StatusUnauthorized},
+				{login: "alice", password: wrong, wantStatus: http.StatusUnauthorized},
+				// Под блокировкой не проверяется даже верный пароль.
+				{login: "alice", password: testLoginPassword, wantStatus: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name:   "username and email share the account counter",
+			policy: accountPolicy,
+			attempts: []attempt{
+				{login: "alice", password: wrong, wantStatus: http.StatusUnauthorized},
+				{login: "alice@example.com", password: wrong, wantStatus: http.StatusUnauthorized},
+				{login: "alice", password: wrong, wantStatus: http.StatusUnauthorized},
+				{login: "Alice@Example.com", password: testLoginPassword, wantStatus: http.StatusTooManyRequests},
+			},
+		},
+		{
+			// Иначе по 429 можно было бы отличить существующий логин от несуществующего.
+			name:   "unknown login locks the same way",
+			policy: accountPolicy,
+			attempts: []attempt{
+				{login: "mallory", password: wrong, wantStatus: http.StatusUnauthorized},
+				{login: "mallory", password: wrong, wantStatus: http.StatusUnauthorized},
+				{login: "Mallory", password: wrong, wantStatus: http.StatusUnauthorized},
+				{login: "mallory", password: wrong, wantStatus: http.StatusTooManyRequests},
+				{login: "alice", password: testLoginPassword, wantStatus: http.StatusOK},
+			},
+		},
+		{
+			name:   "successful login resets the account counter",
+			policy: accountPolicy,
+			attempts: []attempt{
+				{login: "alice", password: wrong, wantStatus: http.StatusUnauthorized},
+				{login: "alice", password: wrong, wantStatus: http.StatusUnauthorized},
+				{login: "alice", password: testLoginPassword, wantStatus: http.StatusOK},
+				{login: "alice", password: wrong, wantStatus: http.StatusUnauthorized},
+				{login: "alice", password: wrong, wantStatus: http.StatusUnauthorized},
+				{login: "alice", password: wrong, wantStatus: http.StatusUnauthorized},
+				{login: "alice", password: wrong, wantStatus: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name:   "ip locks across accounts",
+			policy: services.LoginThrottlePolicy{AccountThreshold: 100, IPThreshold: 3, BaseDelay: time.Minute, MaxLockout: time.Hour, Window: time.Hour},
+			attempts: []attempt{
+				{login: "bob", password: wrong, wantStatus: http.StatusUnauthorized},
+				{login: "carol", password: wrong, wantStatus: http.StatusUnauthorized},
+				{login: "alice", password: wrong, wantStatus: http.StatusUnauthorized},
+				{login: "alice", password: testLoginPassword, wantStatus: http.StatusTooManyRequests},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newRefreshTestEnv(t)
+			env.throttle = services.NewLoginThrottle(services.NewMemoryLoginAttemptStore(time.Hour), tt.policy)
+			env.setPassword(t, testLoginPassword)
+			email := "alice@example.com"
+			env.users.user.Email = &email
+			h := env.newHandler()
+
+			for i, a := range tt.attempts {
+				rec := env.login(h, `{"username":"`+a.login+`","password":"`+a.password+`"}`)
+				if rec.Code != a.wantStatus {
+					t.Fatalf("attempt %d (%s): status = %d, want %d (body %s)", i, a.login, rec.Code, a.wantStatus, rec.Body)
+				}
+				if a.wantStatus != http.StatusTooManyRequests {
+					continue
+				}
+				// Блокировка на BaseDelay с момента последней неудачи.
+				if retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After")); err != nil || retryAfter < 1 || retryAfter > 60 {
+					t.Fatalf("attempt %d: Retry-After = %q, want 1..60 seconds", i, rec.Header().Get("Retry-After"))
+				}
+			}
+		})
+	}
+}
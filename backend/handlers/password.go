@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"goTodo/backend/logger"
+	"goTodo/backend/metrics"
+	"goTodo/backend/middleware"
+	"goTodo/backend/models"
+	"goTodo/backend/services"
+)
+
+// ChangePassword godoc
+// @Summary Change password
+// @Description Меняет пароль после проверки текущего. Все остальные входы завершаются, выданные
+// @Description access-токены отзываются; текущее устройство получает новый access-токен и остается в системе.
+// @Description Неверный текущий пароль засчитывается в блокировку входа (LOGIN_THROTTLE_*): при блокировке — 429 с Retry-After.
+// @Description Новый пароль проверяется политикой; при нарушении — 400 с code password_policy_violation и списком violations.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.ChangePasswordRequest true "Change password request"
+// @Success 200 {object} models.AuthResponse
+// @Failure 400 {object} models.PasswordPolicyErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /me/password [post]
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		respondWithError(w, http.StatusBadRequest, "Fields 'currentPassword' and 'newPassword' are required")
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		respondWithError(w, http.StatusBadRequest, "New password must differ from the current one")
+		return
+	}
+
+	user, err := h.userRepo.FindByID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusUnauthorized, "User not found")
+			return
+		}
+
+		respondWithServerError(w, r, err, "failed to find user", "Failed to change password")
+		return
+	}
+
+	if !verifyCurrentPassword(w, r, h.auth, h.throttle, h.metrics, user, req.CurrentPassword, "Failed to change password") {
+		return
+	}
+
+	if err := h.passwords.Validate(r.Context(), req.NewPassword, user.Username); err != nil {
+		if !respondWithPasswordPolicyError(w, err) {
+			respondWithServerError(w, r, err, "failed to validate password", "Failed to change password")
+		}
+		return
+	}
+
+	passwordHash, err := h.auth.HashPassword(req.NewPassword)
+	if err != nil {
+		respondWithServerError(w, r, err, "failed to hash password", "Failed to change password")
+		return
+	}
+
+	// Пароль уже сменен — доводим отзыв сессий до конца, даже если клиент оборвал соединение.
+	ctx := context.WithoutCancel(r.Context())
+	if err := h.userRepo.UpdatePassword(ctx, userID, passwordHash); err != nil {
+		respondWithServerError(w, r, err, "failed to update password", "Failed to change password")
+		return
+	}
+
+	tokenVersion, err := h.revocation.RevokeAllForUser(ctx, userID)
+	if err != nil {
+		respondWithServerError(w, r, err, "failed to revoke access tokens after password change", "Failed to change password")
+		return
+	}
+	// Текущая семья — из claim sid: refresh cookie (Path /api/auth) на этот путь не приходит.
+	currentFamilyID := h.currentFamilyID(r, userID)
+	if _, err := h.refreshRepo.RevokeOtherFamilies(ctx, userID, currentFamilyID, "password changed"); err != nil {
+		respondWithServerError(w, r, err, "failed to revoke other sessions after password change", "Failed to change password")
+		return
+	}
+
+	// Новый токен несет версию после отзыва, поэтому остается валидным.
+	user.TokenVersion = tokenVersion
+	accessToken, err := h.auth.GenerateAccessToken(user, currentFamilyID)
+	if err != nil {
+		respondWithServerError(w, r, err, "failed to generate access token after password change", "Failed to change password")
+		return
+	}
+
+	h.recordSecurityEvent(r, userID, services.SecurityEventPasswordChanged, nil)
+	respondWithJSON(w, http.StatusOK, models.AuthResponse{
+		AccessToken: accessToken,
+		User:        toUserResponse(user),
+	})
+}
+
+// verifyCurrentPassword проверяет текущий пароль пользователя перед изменением аккаунта.
+// Попытки считаются тем же LoginThrottle, что и вход, по ключу аккаунта: с украденным
+// access-токеном пароль нельзя перебирать быстрее, чем через форму входа.
+// Возвращает false, если ответ уже записан: 429 при блокировке, 403 при неверном пароле
+// (не 401: клиент аутентифицирован, и 401 заставил бы фронт обновлять токен) или 500.
+func verifyCurrentPassword(
+	w http.ResponseWriter,
+	r *http.Request,
+	auth services.AuthService,
+	throttle services.LoginThrottle,
+	m *metrics.Metrics,
+	user *models.User,
+	password string,
+	failureMessage string,
+) bool {
+	account := services.LoginAccountKey(user.ID, user.Username)
+	ip := clientMetadata(r).IPAddress
+
+	reservation, err := throttle.Reserve(r.Context(), account, ip)
+	if err != nil {
+		respondWithServerError(w, r, err, "failed to check login throttle", failureMessage)
+		return false
+	}
+	if reservation.RetryAfter > 0 {
+		m.AuthEvent(metrics.AuthLoginThrottled)
+		respondWithRetryAfter(w, reservation.RetryAfter, "Too many failed password attempts, try again later")
+		return false
+	}
+
+	// Неудача уже засчитана резервом; при успехе попытка возвращается.
+	if err := auth.VerifyPassword(password, user.PasswordHash); err != nil {
+		if !errors.Is(err, services.ErrInvalidCredentials) {
+			respondWithServerError(w, r, err, "failed to verify password", failureMessage)
+			return false
+		}
+		respondWithError(w, http.StatusForbidden, "Current password is incorrect")
+		return false
+	}
+
+	if err := throttle.RecordSuccess(r.Context(), reservation); err != nil {
+		logger.FromContext(r.Context()).Error("failed to reset login throttle", "error", err)
+	}
+	return true
+}
+
+// ErrorCodePasswordPolicy — код ответа 400, когда пароль не прошел парольную политику.
+const ErrorCodePasswordPolicy = "password_policy_violation"
+
+// respondWithPasswordPolicyError отвечает 400 со списком нарушенных правил, если err —
+// *services.PasswordPolicyError. Возвращает false для остальных ошибок.
+func respondWithPasswordPolicyError(w http.ResponseWriter, err error) bool {
+	var policyErr *services.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	respondWithJSON(w, http.StatusBadRequest, models.PasswordPolicyErrorResponse{
+		Error:      "Password does not meet the requirements",
+		Code:       ErrorCodePasswordPolicy,
+		Violations: policyErr.Violations,
+	})
+	return true
+}
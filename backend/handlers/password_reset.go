@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"goTodo/backend/models"
+	"goTodo/backend/services"
+)
+
+// passwordResetRequestedMessage — один ответ для любого логина, чтобы по нему нельзя было
+// проверить существование аккаунта.
+const passwordResetRequestedMessage = "If the account exists, a password reset link has been sent"
+
+// PasswordResetHandler обрабатывает восстановление пароля по ссылке из письма.
+type PasswordResetHandler struct {
+	reset services.PasswordReset
+}
+
+func NewPasswordResetHandler(reset services.PasswordReset) *PasswordResetHandler {
+	return &PasswordResetHandler{reset: reset}
+}
+
+// RequestPasswordReset godoc
+// @Summary Request password reset
+// @Description Отправляет ссылку для сброса пароля на подтвержденный адрес; username — логин или адрес почты.
+// @Description Ответ одинаковый для существующих и неизвестных логинов и для аккаунтов без адреса:
+// @Description по нему нельзя проверить, зарегистрирован ли пользователь.
+// @Description Сверх лимитов писем (на пользователя и на IP) письмо молча не отправляется, ответ тот же.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.PasswordResetRequest true "Password reset request"
+// @Success 202 {object} models.MessageResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/password-reset/request [post]
+func (h *PasswordResetHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req models.PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	username := strings.TrimSpace(req.Username)
+	if username == "" {
+		respondWithError(w, http.StatusBadRequest, "Field 'username' is required")
+		return
+	}
+
+	if err := h.reset.Request(r.Context(), username, clientMetadata(r)); err != nil {
+		respondWithServerError(w, r, err, "failed to request password reset", "Failed to request password reset")
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, models.MessageResponse{Message: passwordResetRequestedMessage})
+}
+
+// ConfirmPasswordReset godoc
+// @Summary Confirm password reset
+// @Description Устанавливает новый пароль по одноразовому токену из письма. Все входы пользователя
+// @Description завершаются, выданные access-токены отзываются.
+// @Description Новый пароль проверяется политикой (400 с code password_policy_violation); токен при этом не гасится.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.PasswordResetConfirmRequest true "Password reset confirmation"
+// @Success 204
+// @Failure 400 {object} models.PasswordPolicyErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/password-reset/confirm [post]
+func (h *PasswordResetHandler) ConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req models.PasswordResetConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.Token == "" || req.NewPassword == "" {
+		respondWithError(w, http.StatusBadRequest, "Fields 'token' and 'newPassword' are required")
+		return
+	}
+
+	if err := h.reset.Confirm(r.Context(), req.Token, req.NewPassword, clientMetadata(r)); err != nil {
+		if errors.Is(err, services.ErrInvalidResetToken) {
+			respondWithError(w, http.StatusBadRequest, "Invalid or expired reset token")
+			return
+		}
+		if respondWithPasswordPolicyError(w, err) {
+			return
+		}
+
+		respondWithServerError(w, r, err, "failed to reset password", "Failed to reset password")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
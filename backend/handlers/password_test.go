@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"goTodo/backend/middleware"
+	"goTodo/backend/models"
+	"goTodo/backend/services"
+)
+
+const testCurrentPassword = "old-password-1"
+
+// changePassword отправляет POST /api/me/password через AuthMiddleware с Bearer-токеном и
+// теми cookie, которые браузер прислал бы на этот путь.
+func (e *refreshTestEnv) changePassword(t *testing.T, h *AuthHandler, accessToken string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("cookiejar.New: %v", err)
+	}
+	authURL, _ := url.Parse("http://localhost/api/auth/refresh")
+	jar.SetCookies(authURL, []*http.Cookie{{Name: e.cookie.Name, Value: e.token, Path: e.cookie.Path}})
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/api/me/password", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	for _, cookie := range jar.Cookies(req.URL) {
+		req.AddCookie(cookie)
+	}
+
+	rec := httptest.NewRecorder()
+	middleware.AuthMiddleware(e.auth, e.revocation)(http.HandlerFunc(h.ChangePassword)).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestChangePasswordKeepsCurrentSessionWithDefaultCookiePath(t *testing.T) {
+	env := newRefreshTestEnv(t)
+	// Путь cookie по умолчанию (REFRESH_COOKIE_PATH): на /api/me/password она не приходит.
+	env.cookie.Path = "/api/auth"
+	h := env.newHandler()
+
+	hash, err := env.auth.HashPassword(testCurrentPassword)
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	env.users.user.PasswordHash = hash
+	env.repo.add(1, "family-2", "other-device", time.Now().UTC())
+
+	accessToken, err := env.auth.GenerateAccessToken(env.users.user, env.family)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+
+	rec := env.changePassword(t, h, accessToken, `{"currentPassword":"old-password-1","newPassword":"new-password-2"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body)
+	}
+	if len(rec.Result().Cookies()) != 0 {
+		t.Fatalf("response sets cookies %v, want none", rec.Result().Cookies())
+	}
+
+	if env.repo.familyRevoked(env.family) {
+		t.Fatal("current family was revoked by a password change")
+	}
+	if !env.repo.familyRevoked("family-2") {
+		t.Fatal("other family survived a password change")
+	}
+
+	var response models.AuthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	claims, err := env.auth.ValidateAccessToken(response.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken(new): %v", err)
+	}
+	if claims.SessionID != env.family {
+		t.Fatalf("new access token sid = %q, want %q", claims.SessionID, env.family)
+	}
+	if err := env.revocation.Check(context.Background(), claims); err != nil {
+		t.Fatalf("new access token is revoked: %v", err)
+	}
+
+	oldClaims, err := env.auth.ValidateAccessToken(accessToken)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken(old): %v", err)
+	}
+	if err := env.revocation.Check(context.Background(), oldClaims); err != services.ErrTokenRevoked {
+		t.Fatalf("old access token check = %v, want ErrTokenRevoked", err)
+	}
+	if !env.events.recorded(services.SecurityEventPasswordChanged) {
+		t.Fatal("password_changed was not recorded")
+	}
+}
+
+func TestChangePasswordRejectsWrongCurrentPassword(t *testing.T) {
+	env := newRefreshTestEnv(t)
+	h := env.newHandler()
+
+	hash, err := env.auth.HashPassword(testCurrentPassword)
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	env.users.user.PasswordHash = hash
+	accessToken, err := env.auth.GenerateAccessToken(env.users.user, env.family)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+
+	rec := env.changePassword(t, h, accessToken, `{"currentPassword":"wrong-password","newPassword":"new-password-2"}`)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403 (body %s)", rec.Code, rec.Body)
+	}
+	if env.users.user.PasswordHash != hash {
+		t.Fatal("password changed after a wrong current password")
+	}
+	if len(env.repo.revokedFamilies) != 0 {
+		t.Fatalf("families revoked: %v, want none", env.repo.revokedFamilies)
+	}
+}
+
+func TestChangePasswordThrottlesCurrentPassword(t *testing.T) {
+	type step struct {
+		login      bool
+		password   string
+		wantStatus int
+	}
+	const wrong = "wrong-password"
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "locks after the threshold",
+			steps: []step{
+				{password: wrong, wantStatus: http.StatusForbidden},
+				{password: wrong, wantStatus: http.StatusForbidden},
+				{password: wrong, wantStatus: http.StatusForbidden},
+				// Под блокировкой не проверяется даже верный пароль.
+				{password: testCurrentPassword, wantStatus: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name: "lockout is shared with login",
+			steps: []step{
+				{password: wrong, wantStatus: http.StatusForbidden},
+				{password: wrong, wantStatus: http.StatusForbidden},
+				{password: wrong, wantStatus: http.StatusForbidden},
+				{login: true, password: testCurrentPassword, wantStatus: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name: "successful login resets the account counter",
+			steps: []step{
+				{password: wrong, wantStatus: http.StatusForbidden},
+				{password: wrong, wantStatus: http.StatusForbidden},
+				{login: true, password: testCurrentPassword, wantStatus: http.StatusOK},
+				{password: wrong, wantStatus: http.StatusForbidden},
+				{password: wrong, wantStatus: http.StatusForbidden},
+				{password: wrong, wantStatus: http.StatusForbidden},
+				{password: wrong, wantStatus: http.StatusTooManyRequests},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newRefreshTestEnv(t)
+			env.throttle = services.NewLoginThrottle(services.NewMemoryLoginAttemptStore(time.Hour), services.LoginThrottlePolicy{
+				AccountThreshold: 3,
+				BaseDelay:        time.Minute,
+				MaxLockout:       time.Hour,
+				Window:           time.Hour,
+			})
+			env.setPassword(t, testCurrentPassword)
+			h := env.newHandler()
+
+			accessToken, err := env.auth.GenerateAccessToken(env.users.user, env.family)
+			if err != nil {
+				t.Fatalf("GenerateAccessToken: %v", err)
+			}
+
+			for i, s := range tt.steps {
+				var rec *httptest.ResponseRecorder
+				if s.login {
+					rec = env.login(h, `{"username":"alice","password":"`+s.password+`"}`)
+				} else {
+					rec = env.changePassword(t, h, accessToken, `{"currentPassword":"`+s.password+`","newPassword":"new-password-2"}`)
+				}
+				if rec.Code != s.wantStatus {
+					t.Fatalf("step %d: status = %d, want %d (body %s)", i, rec.Code, s.wantStatus, rec.Body)
+				}
+				if s.wantStatus == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+					t.Fatalf("step %d: 429 without Retry-After", i)
+				}
+			}
+
+			if env.events.recorded(services.SecurityEventPasswordChanged) {
+				t.Fatal("password changed without the correct current password")
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"goTodo/backend/middleware"
+	"goTodo/backend/models"
+	"goTodo/backend/services"
+)
+
+const (
+	defaultSecurityEventsLimit = 50
+	maxSecurityEventsLimit     = 200
+)
+
+// SecurityEventHandler отдает пользователю журнал событий безопасности его аккаунта.
+type SecurityEventHandler struct {
+	events services.SecurityEvents
+}
+
+func NewSecurityEventHandler(events services.SecurityEvents) *SecurityEventHandler {
+	return &SecurityEventHandler{events: events}
+}
+
+// ListSecurityEvents godoc
+// @Summary List security events
+// @Description Последние события безопасности аккаунта: reuse refresh-токена, refresh истекшим токеном, неудачные входы.
+// @Tags me
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Max events (default 50, max 200)"
+// @Success 200 {array} models.SecurityEventResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /me/security-events [get]
+func (h *SecurityEventHandler) ListSecurityEvents(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit := defaultSecurityEventsLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > maxSecurityEventsLimit {
+			respondWithError(w, http.StatusBadRequest, "Query parameter 'limit' must be between 1 and 200")
+			return
+		}
+		limit = parsed
+	}
+
+	events, err := h.events.List(r.Context(), userID, limit)
+	if err != nil {
+		respondWithServerError(w, r, err, "failed to list security events", "Failed to list security events")
+		return
+	}
+
+	response := make([]models.SecurityEventResponse, 0, len(events))
+	for _, event := range events {
+		response = append(response, models.SecurityEventResponse{
+			ID:        event.ID,
+			Type:      event.Type,
+			IPAddress: event.IPAddress,
+			UserAgent: event.UserAgent,
+			Details:   event.Details,
+			CreatedAt: event.CreatedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
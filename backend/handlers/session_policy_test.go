@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"goTodo/backend/models"
+	"goTodo/backend/services"
+)
+
+func TestSessionPolicyExpiresAt(t *testing.T) {
+	login := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	policy := SessionPolicy{
+		RefreshTTL:      7 * 24 * time.Hour,
+		ShortRefreshTTL: 12 * time.Hour,
+		MaxLifetime:     30 * 24 * time.Hour,
+		IdleTimeout:     72 * time.Hour,
+	}
+
+	tests := []struct {
+		name       string
+		policy     SessionPolicy
+		now        time.Time
+		rememberMe bool
+		want       time.Time
+	}{
+		{
+			name:       "idle timeout caps the refresh ttl",
+			policy:     policy,
+			now:        login,
+			rememberMe: true,
+			want:       login.Add(72 * time.Hour),
+		},
+		{
+			name:   "short ttl without remember me",
+			policy: policy,
+			now:    login,
+			want:   login.Add(12 * time.Hour),
+		},
+		{
+			name:       "rotation does not extend past the absolute lifetime",
+			policy:     policy,
+			now:        login.Add(29 * 24 * time.Hour),
+			rememberMe: true,
+			want:       login.Add(30 * 24 * time.Hour),
+		},
+		{
+			name:       "zero limits leave the refresh ttl",
+			policy:     SessionPolicy{RefreshTTL: 7 * 24 * time.Hour, ShortRefreshTTL: 12 * time.Hour},
+			now:        login.Add(100 * 24 * time.Hour),
+			rememberMe: true,
+			want:       login.Add(107 * 24 * time.Hour),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.expiresAt(login, tt.now, tt.rememberMe); !got.Equal(tt.want) {
+				t.Fatalf("expiresAt = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionPolicyEndReason(t *testing.T) {
+	login := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	policy := SessionPolicy{MaxLifetime: 30 * 24 * time.Hour, IdleTimeout: 72 * time.Hour}
+
+	tests := []struct {
+		name         string
+		policy       SessionPolicy
+		lastRefresh  time.Time
+		now          time.Time
+		wantCode     string
+		wantNoReason bool
+	}{
+		{name: "active session", policy: policy, lastRefresh: login, now: login.Add(time.Hour), wantNoReason: true},
+		{name: "idle just before the timeout", policy: policy, lastRefresh: login, now: login.Add(72*time.Hour - time.Second), wantNoReason: true},
+		{name: "idle at the timeout", policy: policy, lastRefresh: login, now: login.Add(72 * time.Hour), wantCode: ErrorCodeSessionIdleTimeout},
+		{name: "lifetime reached despite activity", policy: policy, lastRefresh: login.Add(30*24*time.Hour - time.Hour), now: login.Add(30 * 24 * time.Hour), wantCode: ErrorCodeSessionLifetimeExceeded},
+		{name: "lifetime wins over idle", policy: policy, lastRefresh: login, now: login.Add(31 * 24 * time.Hour), wantCode: ErrorCodeSessionLifetimeExceeded},
+		{name: "limits disabled", policy: SessionPolicy{}, lastRefresh: login, now: login.Add(365 * 24 * time.Hour), wantNoReason: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &models.RefreshSession{FamilyIssuedAt: login, IssuedAt: tt.lastRefresh}
+			code, message := tt.policy.endReason(session, tt.now)
+			if tt.wantNoReason {
+				if code != "" {
+					t.Fatalf("endReason = %q, want none", code)
+				}
+				return
+			}
+			if code != tt.wantCode || message == "" {
+				t.Fatalf("endReason = (%q, %q), want code %q with a message", code, message, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestRefreshEndsSessionByPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   SessionPolicy
+		age      time.Duration
+		wantCode string
+	}{
+		{
+			name:     "absolute lifetime exceeded",
+			policy:   SessionPolicy{RefreshTTL: time.Hour, ShortRefreshTTL: time.Hour, MaxLifetime: 2 * time.Hour},
+			age:      3 * time.Hour,
+			wantCode: ErrorCodeSessionLifetimeExceeded,
+		},
+		{
+			name:     "idle timeout exceeded",
+			policy:   SessionPolicy{RefreshTTL: time.Hour, ShortRefreshTTL: time.Hour, IdleTimeout: 30 * time.Minute},
+			age:      45 * time.Minute,
+			wantCode: ErrorCodeSessionIdleTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newRefreshTestEnv(t)
+			env.sessions = tt.policy
+			// Вход и последний refresh были age назад; сам refresh-токен еще не истек.
+			env.repo.mu.Lock()
+			for _, session := range env.repo.sessions {
+				session.FamilyIssuedAt = session.FamilyIssuedAt.Add(-tt.age)
+				session.IssuedAt = session.IssuedAt.Add(-tt.age)
+			}
+			env.repo.mu.Unlock()
+
+			rec := env.refresh(env.newHandler())
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want 401 (body %s)", rec.Code, rec.Body)
+			}
+			var response models.ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if response.Code != tt.wantCode {
+				t.Fatalf("code = %q, want %q", response.Code, tt.wantCode)
+			}
+			if !env.repo.familyRevoked(env.family) {
+				t.Fatal("family was not revoked")
+			}
+			if env.repo.rotations != 0 {
+				t.Fatalf("rotations = %d, want 0", env.repo.rotations)
+			}
+			// Политика сроков — не кража: reuse в журнал не пишется.
+			if env.events.recorded(services.SecurityEventRefreshReuse) {
+				t.Fatal("refresh_token_reuse recorded for an expired session")
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"goTodo/backend/middleware"
+	"goTodo/backend/models"
+)
+
+// maxUserAgentLength ограничивает размер сохраняемого User-Agent.
+const maxUserAgentLength = 512
+
+// ListSessions godoc
+// @Summary List active sessions
+// @Description Активные входы пользователя (семьи refresh-сессий). current — вход, которому выдан access-токен запроса.
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.SessionResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/sessions [get]
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	families, err := h.refreshRepo.ListActiveFamilies(r.Context(), userID)
+	if err != nil {
+		respondWithServerError(w, r, err, "failed to list sessions", "Failed to list sessions")
+		return
+	}
+
+	currentFamilyID := h.currentFamilyID(r, userID)
+	sessions := make([]models.SessionResponse, 0, len(families))
+	for _, family := range families {
+		sessions = append(sessions, models.SessionResponse{
+			ID:              family.FamilyID,
+			CreatedAt:       family.CreatedAt,
+			LastRefreshedAt: family.LastRefreshedAt,
+			UserAgent:       family.UserAgent,
+			IPAddress:       family.IPAddress,
+			Current:         family.FamilyID == currentFamilyID,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, sessions)
+}
+
+// RevokeSession godoc
+// @Summary Revoke session
+// @Description Завершает один вход (семью refresh-сессий). Access-токены этого устройства живут до exp.
+// @Tags auth
+// @Security BearerAuth
+// @Param familyId path string true "Session ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/sessions/{familyId} [delete]
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	familyID := mux.Vars(r)["familyId"]
+	if err := h.refreshRepo.RevokeFamilyForUser(r.Context(), familyID, userID, "revoked by user"); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Session not found")
+			return
+		}
+
+		respondWithServerError(w, r, err, "failed to revoke session", "Failed to revoke session")
+		return
+	}
+
+	if familyID == h.currentFamilyID(r, userID) {
+		h.clearRefreshCookie(w)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeOtherSessions godoc
+// @Summary Logout all other sessions
+// @Description Завершает все входы пользователя, кроме текущего (того, которому выдан access-токен запроса).
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.RevokeSessionsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/sessions [delete]
+func (h *AuthHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Если текущий вход неизвестен, непонятно, какую сессию оставить; отзыв всех — это /auth/logout-all.
+	currentFamilyID := h.currentFamilyID(r, userID)
+	if currentFamilyID == "" {
+		respondWithError(w, http.StatusBadRequest, "Active refresh session cookie is required")
+		return
+	}
+
+	revoked, err := h.refreshRepo.RevokeOtherFamilies(r.Context(), userID, currentFamilyID, "other sessions revoked by user")
+	if err != nil {
+		respondWithServerError(w, r, err, "failed to revoke other sessions", "Failed to revoke sessions")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.RevokeSessionsResponse{Revoked: revoked})
+}
+
+// currentFamilyID возвращает семью текущего входа: claim sid access-токена запроса, а для
+// токенов без sid — семью активной refresh-сессии из cookie (она приходит только на путь cookie).
+// Пустая строка — если вход определить не удалось.
+func (h *AuthHandler) currentFamilyID(r *http.Request, userID int64) string {
+	if claims, ok := middleware.AccessClaimsFromContext(r.Context()); ok && claims.UserID == userID && claims.SessionID != "" {
+		return claims.SessionID
+	}
+
+	refreshToken, err := h.readRefreshCookie(r)
+	if err != nil {
+		return ""
+	}
+
+	session, err := h.refreshRepo.FindByTokenHash(r.Context(), h.auth.HashRefreshToken(refreshToken))
+	if err != nil || session.UserID != userID || session.RevokedAt != nil || session.ConsumedAt != nil {
+		return ""
+	}
+
+	return session.FamilyID
+}
+
+// clientMetadata собирает данные клиента для списка сессий.
+// IP определяет middleware.ClientIP (X-Forwarded-For учитывается только от TRUSTED_PROXIES);
+// без него — RemoteAddr.
+func clientMetadata(r *http.Request) models.ClientMetadata {
+	userAgent := strings.TrimSpace(r.UserAgent())
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	// TEXT в Postgres не принимает невалидный UTF-8 (в том числе после обрезки посреди символа).
+	userAgent = strings.ToValidUTF8(userAgent, "")
+
+	ip, ok := middleware.ClientIPFromContext(r.Context())
+	if !ok {
+		ip = r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			ip = host
+		}
+	}
+
+	return models.ClientMetadata{UserAgent: userAgent, IPAddress: ip}
+}
@@ -1,26 +1,34 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"log"
+	"log/slog"
+	"math"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 
+	"goTodo/backend/logger"
+	"goTodo/backend/metrics"
 	"goTodo/backend/middleware"
 	"goTodo/backend/models"
 	"goTodo/backend/repository"
+	"goTodo/backend/services"
 )
 
 type TodoHandler struct {
-	repo repository.TodoRepository
+	repo    repository.TodoRepository
+	history services.TodoHistory
+	metrics *metrics.Metrics
 }
 
-func NewTodoHandler(repo repository.TodoRepository) *TodoHandler {
-	return &TodoHandler{repo: repo}
+func NewTodoHandler(repo repository.TodoRepository, history services.TodoHistory, metrics *metrics.Metrics) *TodoHandler {
+	return &TodoHandler{repo: repo, history: history, metrics: metrics}
 }
 
 // CreateTodo godoc
@@ -57,12 +65,14 @@ func (h *TodoHandler) CreateTodo(w http.ResponseWriter, r *http.Request) {
 		Value: req.Value,
 	}
 
-	if err := h.repo.Create(todo, userID); err != nil {
-		log.Printf("Error creating todo: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to create todo")
+	if err := h.repo.Create(r.Context(), todo, userID); err != nil {
+		respondWithServerError(w, r, err, "failed to create todo", "Failed to create todo")
 		return
 	}
 
+	h.history.Record(userID, services.NewCreateTodoCommand(*todo))
+	h.metrics.TodoCreated()
+
 	respondWithJSON(w, http.StatusCreated, todo)
 }
 
@@ -80,10 +90,9 @@ func (h *TodoHandler) GetAllTodos(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	todos, err := h.repo.GetAllByUserID(userID)
+	todos, err := h.repo.GetAllByUserID(r.Context(), userID)
 	if err != nil {
-		log.Printf("Error getting todos: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to get todos")
+		respondWithServerError(w, r, err, "failed to get todos", "Failed to get todos")
 		return
 	}
 
@@ -115,15 +124,14 @@ func (h *TodoHandler) GetTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	todo, err := h.repo.GetByIDForUser(id, userID)
+	todo, err := h.repo.GetByIDForUser(r.Context(), id, userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			respondWithError(w, http.StatusNotFound, "Todo not found")
 			return
 		}
 
-		log.Printf("Error getting todo: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to get todo")
+		respondWithServerError(w, r, err, "failed to get todo", "Failed to get todo")
 		return
 	}
 
@@ -154,26 +162,87 @@ func (h *TodoHandler) DeleteTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.repo.DeleteForUser(id, userID); err != nil {
+	todo, err := h.repo.DeleteForUser(r.Context(), id, userID)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			respondWithError(w, http.StatusNotFound, "Todo not found")
 			return
 		}
 
-		log.Printf("Error deleting todo: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to delete todo")
+		respondWithServerError(w, r, err, "failed to delete todo", "Failed to delete todo")
 		return
 	}
 
+	h.history.Record(userID, services.NewDeleteTodoCommand(*todo))
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// Undo godoc
+// @Summary Undo last todo operation
+// @Tags todos
+// @Produce json
+// @Success 200 {object} models.TodoHistoryResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /undo [post]
+func (h *TodoHandler) Undo(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	result, err := h.history.Undo(r.Context(), userID)
+	h.respondWithHistoryResult(w, r, result, err, "Failed to undo")
+}
+
+// Redo godoc
+// @Summary Redo last undone todo operation
+// @Tags todos
+// @Produce json
+// @Success 200 {object} models.TodoHistoryResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /redo [post]
+func (h *TodoHandler) Redo(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	result, err := h.history.Redo(r.Context(), userID)
+	h.respondWithHistoryResult(w, r, result, err, "Failed to redo")
+}
+
+func (h *TodoHandler) respondWithHistoryResult(w http.ResponseWriter, r *http.Request, result *services.TodoHistoryResult, err error, failureMessage string) {
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNothingToUndo):
+			respondWithError(w, http.StatusConflict, "Nothing to undo")
+		case errors.Is(err, services.ErrNothingToRedo):
+			respondWithError(w, http.StatusConflict, "Nothing to redo")
+		case errors.Is(err, services.ErrTodoChanged):
+			respondWithError(w, http.StatusConflict, "Todo has changed since the operation")
+		default:
+			respondWithServerError(w, r, err, "failed to apply todo history", failureMessage)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.TodoHistoryResponse{
+		Operation: result.Operation,
+		Todo:      *result.Todo,
+	})
+}
+
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 
 	response, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("Error marshaling JSON: %v", err)
+		slog.Error("failed to marshal JSON response", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(`{"error":"Internal server error"}`))
 		return
@@ -186,3 +255,42 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 func respondWithError(w http.ResponseWriter, code int, message string) {
 	respondWithJSON(w, code, models.ErrorResponse{Error: message})
 }
+
+// respondWithErrorCode отвечает ошибкой с машиночитаемым кодом, по которому клиент
+// выбирает реакцию (например, показать форму входа с пояснением).
+func respondWithErrorCode(w http.ResponseWriter, code int, errorCode string, message string) {
+	respondWithJSON(w, code, models.ErrorResponse{Error: message, Code: errorCode})
+}
+
+// respondWithRetryAfter отвечает 429 и заголовком Retry-After (секунды, с округлением вверх).
+func respondWithRetryAfter(w http.ResponseWriter, retryAfter time.Duration, message string) {
+	seconds := int64(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	respondWithError(w, http.StatusTooManyRequests, message)
+}
+
+// statusClientClosedRequest — нестандартный код (как в nginx) для запросов,
+// которые клиент оборвал раньше, чем сервер успел ответить.
+const statusClientClosedRequest = 499
+
+// respondWithServerError отвечает на непредвиденную ошибку и пишет её в лог запроса.
+// Истекший дедлайн запроса/SQL отдается как 503, а отмена запроса клиентом
+// не считается ошибкой сервера и логируется на уровне info.
+func respondWithServerError(w http.ResponseWriter, r *http.Request, err error, logMessage string, clientMessage string) {
+	log := logger.FromContext(r.Context())
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		log.Info(logMessage+": request canceled by client", "error", err)
+		w.WriteHeader(statusClientClosedRequest)
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Warn(logMessage+": deadline exceeded", "error", err)
+		respondWithError(w, http.StatusServiceUnavailable, "Request timed out")
+	default:
+		log.Error(logMessage, "error", err)
+		respondWithError(w, http.StatusInternalServerError, clientMessage)
+	}
+}
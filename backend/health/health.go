@@ -0,0 +1,116 @@
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// CheckFunc проверяет одну зависимость. nil — зависимость доступна.
+// Функция должна уважать дедлайн ctx.
+type CheckFunc func(ctx context.Context) error
+
+// CheckResult — результат одной проверки.
+type CheckResult struct {
+	Name     string
+	Status   string
+	Duration time.Duration
+	Error    string
+}
+
+// Report — сводный результат всех проверок.
+type Report struct {
+	Status string
+	Checks []CheckResult
+}
+
+// Registry хранит readiness-проверки. Новые зависимости (кэш, SMTP и т.д.)
+// регистрируют себя через Register, не трогая HTTP-слой.
+type Registry struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks map[string]CheckFunc
+}
+
+// NewRegistry создает реестр проверок. timeout — дедлайн на каждую проверку.
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{
+		timeout: timeout,
+		checks:  make(map[string]CheckFunc),
+	}
+}
+
+// Register добавляет (или заменяет) проверку с указанным именем.
+func (r *Registry) Register(name string, check CheckFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checks[name] = check
+}
+
+// Run выполняет все проверки параллельно, каждую со своим таймаутом.
+// Report.Status равен StatusOK, только если все проверки прошли.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := make(map[string]CheckFunc, len(r.checks))
+	for name, check := range r.checks {
+		checks[name] = check
+	}
+	r.mu.RUnlock()
+
+	results := make([]CheckResult, 0, len(checks))
+	var resultsMu sync.Mutex
+	var wg sync.WaitGroup
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check CheckFunc) {
+			defer wg.Done()
+
+			result := r.runCheck(ctx, name, check)
+
+			resultsMu.Lock()
+			results = append(results, result)
+			resultsMu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, result := range results {
+		if result.Status != StatusOK {
+			report.Status = StatusFail
+			break
+		}
+	}
+
+	return report
+}
+
+func (r *Registry) runCheck(ctx context.Context, name string, check CheckFunc) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := CheckResult{
+		Name:     name,
+		Status:   StatusOK,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+
+	return result
+}
@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type contextKey struct{}
+
+// Config описывает настройки логгера: уровень (debug/info/warn/error)
+// и формат вывода (text/json).
+type Config struct {
+	Level  string
+	Format string
+}
+
+// New создает slog.Logger по конфигурации.
+// Возвращает ошибку, если уровень или формат не распознаны.
+func New(cfg Config, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(cfg.Level))); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", cfg.Level, err)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	switch strings.ToLower(strings.TrimSpace(cfg.Format)) {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q: expected text or json", cfg.Format)
+	}
+}
+
+// WithContext кладет логгер в context, чтобы его подхватили хендлеры и репозитории.
+func WithContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext возвращает логгер запроса или slog.Default, если логгер не был положен в context.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(contextKey{}).(*slog.Logger); ok && l != nil {
+		return l
+	}
+
+	return slog.Default()
+}
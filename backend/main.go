@@ -8,12 +8,15 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -21,11 +24,17 @@ import (
 	"github.com/rs/cors"
 	httpSwagger "github.com/swaggo/http-swagger"
 
+	"goTodo/backend/config"
 	"goTodo/backend/database"
 	"goTodo/backend/handlers"
+	"goTodo/backend/health"
+	"goTodo/backend/logger"
+	"goTodo/backend/metrics"
 	"goTodo/backend/middleware"
 	"goTodo/backend/repository"
+	"goTodo/backend/scheduler"
 	"goTodo/backend/services"
+	"goTodo/backend/tracing"
 
 	_ "goTodo/backend/docs"
 )
@@ -34,144 +43,511 @@ func main() {
 	// Загружаем переменные окружения из .env (если файл есть)
 	_ = godotenv.Load()
 
+	configPath := flag.String("config", os.Getenv(config.FileEnvVar), "path to YAML/TOML config file")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+
+	switch args := flag.Args(); {
+	case len(args) == 0:
+		run(cfg)
+	case len(args) == 2 && args[0] == "config" && args[1] == "print":
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\nusage: backend [-config file] [config print]\n", strings.Join(args, " "))
+		os.Exit(2)
+	}
+}
+
+// run поднимает зависимости и HTTP-серверы и блокируется до остановки.
+func run(cfg *config.Config) {
+	appLogger, err := logger.New(logger.Config{
+		Level:  cfg.Log.Level,
+		Format: cfg.Log.Format,
+	}, os.Stdout)
+	if err != nil {
+		slog.Error("failed to initialize logger", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(appLogger)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:     cfg.Tracing.Exporter,
+		ServiceName:  cfg.Tracing.ServiceName,
+		OTLPEndpoint: cfg.Tracing.OTLPEndpoint,
+		OTLPInsecure: cfg.Tracing.OTLPInsecure,
+		SampleRatio:  cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("failed to initialize tracing", err)
+	}
+
 	db, err := database.NewDB(database.Config{
-		Host:     getEnv("DB_HOST", "localhost"),
-		Port:     getEnvInt("DB_PORT", 5432),
-		User:     getEnv("DB_USER", "postgres"),
-		Password: getEnv("DB_PASSWORD", ""),
-		DBName:   getEnv("DB_NAME", "postgres"),
-		SSLMode:  getEnv("DB_SSLMODE", "disable"),
+		Host:     cfg.Database.Host,
+		Port:     cfg.Database.Port,
+		User:     cfg.Database.User,
+		Password: cfg.Database.Password,
+		DBName:   cfg.Database.Name,
+		SSLMode:  cfg.Database.SSLMode,
 	})
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("failed to connect to database", err)
+	}
+
+	if cfg.Database.AutoMigrate {
+		migrateCtx, cancelMigrate := context.WithTimeout(context.Background(), time.Minute)
+		err := database.Migrate(migrateCtx, db)
+		cancelMigrate()
+		if err != nil {
+			fatal("failed to apply database migrations", err)
+		}
 	}
-	defer db.Close()
 
-	todoRepo := repository.NewTodoRepository(db)
-	userRepo := repository.NewUserRepository(db)
-	refreshSessionRepo := repository.NewRefreshSessionRepository(db)
+	appMetrics := metrics.New(db)
 
-	refreshTokenTTL := time.Duration(getEnvInt("JWT_REFRESH_TTL_HOURS", 168)) * time.Hour
+	queryTimeout := cfg.Database.QueryTimeout()
+	todoRepo := repository.NewTodoRepository(db, queryTimeout)
+	userRepo := repository.NewUserRepository(db, queryTimeout)
+	refreshSessionRepo := repository.NewRefreshSessionRepository(db, queryTimeout)
+	revocationRepo := repository.NewAccessTokenRevocationRepository(db, queryTimeout)
+	securityEventRepo := repository.NewSecurityEventRepository(db, queryTimeout)
+	passwordResetRepo := repository.NewPasswordResetRepository(db, queryTimeout)
+	emailVerificationRepo := repository.NewEmailVerificationRepository(db, queryTimeout)
+	loginAttemptRepo := repository.NewLoginAttemptRepository(db, queryTimeout)
+
+	refreshTokenTTL := cfg.Auth.RefreshTokenTTL()
+
+	keySource, err := newKeySource(cfg.Auth)
+	if err != nil {
+		fatal("invalid jwt key configuration", err)
+	}
+	// Выведенный из ротации ключ принимаем еще один access TTL — столько живут выданные им токены.
+	keyRing, err := services.NewKeyRing(keySource, cfg.Auth.AccessTokenTTL(), newKeyPolicy(cfg.App))
+	if err != nil {
+		fatal("failed to load jwt keys", err)
+	}
+	reloadOnSIGHUP("jwt keys", keyRing.Reload)
 
 	authService, err := services.NewAuthService(
-		getEnv("JWT_SECRET", "dev-secret-change-me"),
-		time.Duration(getEnvInt("JWT_ACCESS_TTL_MINUTES", 60))*time.Minute,
+		keyRing,
+		cfg.Auth.AccessTokenTTL(),
 		refreshTokenTTL,
+		services.TokenClaimsConfig{
+			Issuer:   cfg.Auth.Issuer,
+			Audience: cfg.Auth.Audience,
+			Leeway:   cfg.Auth.Leeway(),
+		},
 	)
 	if err != nil {
-		log.Fatalf("Failed to initialize auth service: %v", err)
+		fatal("failed to initialize auth service", err)
+	}
+
+	mailer, err := newMailer(cfg.Mail)
+	if err != nil {
+		fatal("failed to initialize mailer", err)
 	}
 
-	todoHandler := handlers.NewTodoHandler(todoRepo)
+	passwordPolicy, err := newPasswordPolicy(cfg.Password)
+	if err != nil {
+		fatal("failed to initialize password policy", err)
+	}
+
+	tokenRevocation := services.NewTokenRevocation(revocationRepo, cfg.Auth.RevocationCacheTTL())
+	securityEvents := services.NewSecurityEvents(securityEventRepo, newNotifier(cfg.Security, userRepo, mailer), services.FailedLoginPolicy{
+		Threshold: cfg.Security.FailedLoginThreshold,
+		Window:    cfg.Security.FailedLoginWindow(),
+	})
+
+	passwordReset := services.NewPasswordReset(
+		passwordResetRepo,
+		userRepo,
+		refreshSessionRepo,
+		authService,
+		passwordPolicy,
+		tokenRevocation,
+		securityEvents,
+		mailer,
+		services.PasswordResetConfig{
+			TokenTTL:        cfg.Security.PasswordResetTTL(),
+			URL:             cfg.Security.PasswordResetURL,
+			Cooldown:        cfg.Security.PasswordResetCooldown(),
+			MaxPerHour:      cfg.Security.PasswordResetMaxPerHour,
+			MaxPerIPPerHour: cfg.Security.PasswordResetMaxPerIPPerHour,
+		},
+	)
+	emailVerification := services.NewEmailVerification(
+		emailVerificationRepo,
+		userRepo,
+		securityEvents,
+		mailer,
+		services.EmailVerificationConfig{
+			TokenTTL:   cfg.Security.EmailVerificationTTL(),
+			URL:        cfg.Security.EmailVerificationURL,
+			Cooldown:   cfg.Security.EmailVerificationCooldown(),
+			MaxPerHour: cfg.Security.EmailVerificationMaxPerHour,
+		},
+	)
+
+	todoHistory, err := services.NewTodoHistory(todoRepo, cfg.Todo.UndoHistoryLimit)
+	if err != nil {
+		fatal("failed to initialize todo history", err)
+	}
+
+	todoHandler := handlers.NewTodoHandler(todoRepo, todoHistory, appMetrics)
+	securityEventHandler := handlers.NewSecurityEventHandler(securityEvents)
+	passwordResetHandler := handlers.NewPasswordResetHandler(passwordReset)
+	// Один счетчик на вход и проверки текущего пароля: in-memory хранилище должно быть общим.
+	loginThrottle := newLoginThrottle(cfg.LoginThrottle, loginAttemptRepo)
+	emailHandler := handlers.NewEmailHandler(emailVerification, userRepo, authService, loginThrottle, appMetrics)
 	authHandler := handlers.NewAuthHandler(
 		userRepo,
 		refreshSessionRepo,
 		authService,
-		refreshTokenTTL,
+		tokenRevocation,
+		securityEvents,
+		services.NewRefreshGrace(cfg.Auth.RefreshGrace()),
+		passwordPolicy,
+		loginThrottle,
+		handlers.SessionPolicy{
+			RefreshTTL:      refreshTokenTTL,
+			ShortRefreshTTL: cfg.Auth.RefreshTokenShortTTL(),
+			MaxLifetime:     cfg.Auth.SessionMaxLifetime(),
+			IdleTimeout:     cfg.Auth.SessionIdleTimeout(),
+		},
 		handlers.RefreshCookieConfig{
-			Name:     getEnv("REFRESH_COOKIE_NAME", "goTodo_refresh_token"),
-			Domain:   getEnv("REFRESH_COOKIE_DOMAIN", ""),
-			Path:     getEnv("REFRESH_COOKIE_PATH", "/api/auth"),
-			Secure:   getEnvBool("REFRESH_COOKIE_SECURE", false),
-			HTTPOnly: getEnvBool("REFRESH_COOKIE_HTTPONLY", true),
-			SameSite: parseSameSite(getEnv("REFRESH_COOKIE_SAMESITE", "Lax")),
+			Name:     cfg.Cookie.Name,
+			Domain:   cfg.Cookie.Domain,
+			Path:     cfg.Cookie.Path,
+			Secure:   cfg.Cookie.Secure,
+			HTTPOnly: cfg.Cookie.HTTPOnly,
+			SameSite: cfg.Cookie.SameSiteMode(),
 		},
+		appMetrics,
 	)
 
+	trustedProxies, err := cfg.Server.TrustedProxyPrefixes()
+	if err != nil {
+		fatal("invalid trusted proxies", err)
+	}
+
 	router := mux.NewRouter()
+	router.Use(
+		middleware.Tracing(),
+		middleware.MetricsRoute(),
+		middleware.Timeout(cfg.Server.RequestTimeout()),
+	)
 
 	api := router.PathPrefix("/api").Subrouter()
 	api.HandleFunc("/auth/register", authHandler.Register).Methods("POST")
 	api.HandleFunc("/auth/login", authHandler.Login).Methods("POST")
 	api.HandleFunc("/auth/refresh", authHandler.Refresh).Methods("POST")
 	api.HandleFunc("/auth/logout", authHandler.Logout).Methods("POST")
+	api.HandleFunc("/auth/password-reset/request", passwordResetHandler.RequestPasswordReset).Methods("POST")
+	api.HandleFunc("/auth/password-reset/confirm", passwordResetHandler.ConfirmPasswordReset).Methods("POST")
+	api.HandleFunc("/auth/email/verify", emailHandler.VerifyEmail).Methods("POST")
 
-	// Все todo-эндпоинты требуют валидный Bearer access-токен.
-	authRequired := middleware.AuthMiddleware(authService)
+	// Эндпоинты ниже требуют валидный и не отозванный Bearer access-токен.
+	authRequired := middleware.AuthMiddleware(authService, tokenRevocation)
+	api.Handle("/auth/logout-all", authRequired(http.HandlerFunc(authHandler.LogoutAll))).Methods("POST")
+	api.Handle("/auth/sessions", authRequired(http.HandlerFunc(authHandler.ListSessions))).Methods("GET")
+	api.Handle("/auth/sessions", authRequired(http.HandlerFunc(authHandler.RevokeOtherSessions))).Methods("DELETE")
+	api.Handle("/auth/sessions/{familyId:[0-9a-fA-F-]{36}}", authRequired(http.HandlerFunc(authHandler.RevokeSession))).Methods("DELETE")
+	api.Handle("/me/password", authRequired(http.HandlerFunc(authHandler.ChangePassword))).Methods("POST")
+	api.Handle("/me/email", authRequired(http.HandlerFunc(emailHandler.ChangeEmail))).Methods("POST")
+	api.Handle("/me/email/resend", authRequired(http.HandlerFunc(emailHandler.ResendEmailVerification))).Methods("POST")
+	api.Handle("/me/security-events", authRequired(http.HandlerFunc(securityEventHandler.ListSecurityEvents))).Methods("GET")
 	api.Handle("/todos", authRequired(http.HandlerFunc(todoHandler.GetAllTodos))).Methods("GET")
 	api.Handle("/todos", authRequired(http.HandlerFunc(todoHandler.CreateTodo))).Methods("POST")
 	api.Handle("/todos/{id:[0-9]+}", authRequired(http.HandlerFunc(todoHandler.GetTodo))).Methods("GET")
 	api.Handle("/todos/{id:[0-9]+}", authRequired(http.HandlerFunc(todoHandler.DeleteTodo))).Methods("DELETE")
+	api.Handle("/undo", authRequired(http.HandlerFunc(todoHandler.Undo))).Methods("POST")
+	api.Handle("/redo", authRequired(http.HandlerFunc(todoHandler.Redo))).Methods("POST")
+
+	// draining выставляется при получении SIGTERM: readiness начинает отдавать 503,
+	// чтобы балансировщик перестал слать трафик до закрытия listener.
+	var draining atomic.Bool
+
+	readiness := health.NewRegistry(cfg.Server.ReadinessCheckTimeout())
+	readiness.Register("database", db.PingContext)
+	readiness.Register("migrations", func(ctx context.Context) error {
+		return database.CheckSchemaVersion(ctx, db)
+	})
+	readiness.Register("shutdown", func(context.Context) error {
+		if draining.Load() {
+			return errors.New("server is shutting down")
+		}
+		return nil
+	})
 
-	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	}).Methods("GET")
+	healthHandler := handlers.NewHealthHandler(readiness)
+	router.HandleFunc("/livez", healthHandler.Livez).Methods("GET")
+	router.HandleFunc("/readyz", healthHandler.Readyz).Methods("GET")
+	router.HandleFunc("/health", healthHandler.Health).Methods("GET")
+
+	jwksHandler := handlers.NewJWKSHandler(authService)
+	router.HandleFunc("/.well-known/jwks.json", jwksHandler.JWKS).Methods("GET")
 
 	router.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
-	port := ":8080"
-	fmt.Printf("Server starting on http://localhost%s\n", port)
-	fmt.Println("API endpoints:")
-	fmt.Println("  POST   /api/auth/register")
-	fmt.Println("  POST   /api/auth/login")
-	fmt.Println("  POST   /api/auth/refresh")
-	fmt.Println("  POST   /api/auth/logout")
-	fmt.Println("  GET    /api/todos")
-	fmt.Println("  POST   /api/todos")
-	fmt.Println("  GET    /api/todos/{id}")
-	fmt.Println("  DELETE /api/todos/{id}")
-	fmt.Println("Swagger UI:")
-	fmt.Println("  GET    /swagger/index.html")
+	slog.Info("server starting", "env", cfg.App.Env, "addr", cfg.Server.Addr, "swagger", "/swagger/index.html")
+	logRoutes(router)
 
 	corsHandler := cors.New(cors.Options{
-		AllowedOrigins: []string{getEnv("CORS_ALLOWED_ORIGIN", "http://localhost:5173")},
+		AllowedOrigins: []string{cfg.Server.CORSAllowedOrigin},
 		AllowedMethods: []string{"GET", "POST", "DELETE", "OPTIONS"},
 		// Authorization нужен для Bearer JWT; Cookie/Set-Cookie — для refresh flow.
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
-		ExposedHeaders:   []string{"Set-Cookie"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", middleware.RequestIDHeader},
+		ExposedHeaders:   []string{"Set-Cookie", middleware.RequestIDHeader},
 		AllowCredentials: true,
-	}).Handler(router)
+	}).Handler(middleware.Metrics(appMetrics)(router))
+
+	// RequestID снаружи всего, чтобы request_id был и в access-логе, и в CORS preflight.
+	handler := middleware.RequestID(appLogger)(middleware.ClientIP(trustedProxies)(middleware.AccessLog(corsHandler)))
+
+	timeouts := ServerTimeouts{
+		ReadHeader: cfg.Server.ReadHeaderTimeout(),
+		Read:       cfg.Server.ReadTimeout(),
+		Write:      cfg.Server.WriteTimeout(),
+		Idle:       cfg.Server.IdleTimeout(),
+	}
+	servers := []*http.Server{newHTTPServer(cfg.Server.Addr, handler, timeouts)}
+
+	// /metrics не публикуется на основном порту: только на отдельном admin-адресе
+	// (по умолчанию слушает localhost), чтобы не светить метрики наружу.
+	if metricsAddr := cfg.Metrics.Addr; metricsAddr != "off" {
+		adminRouter := http.NewServeMux()
+		adminRouter.Handle("/metrics", appMetrics.Handler())
+		servers = append(servers, newHTTPServer(metricsAddr, adminRouter, timeouts))
+	}
+
+	jobScheduler := scheduler.New(scheduler.NewAdvisoryLocker(db), appMetrics, appLogger)
+	if cfg.Scheduler.Enabled {
+		sessionCleanup := services.NewSessionCleanup(refreshSessionRepo, cfg.Scheduler.SessionRetention())
+		interval := cfg.Scheduler.SessionCleanupInterval()
+		if err := jobScheduler.Register(scheduler.Job{
+			Name:     "refresh_session_cleanup",
+			Interval: interval,
+			Jitter:   interval / 10,
+			Run:      sessionCleanup.Run,
+		}); err != nil {
+			fatal("failed to register scheduler job", err)
+		}
+		securityEventCleanup := services.NewSecurityEventCleanup(securityEventRepo, cfg.Scheduler.SecurityEventRetention())
+		if err := jobScheduler.Register(scheduler.Job{
+			Name:     "security_event_cleanup",
+			Interval: interval,
+			Jitter:   interval / 10,
+			Run:      securityEventCleanup.Run,
+		}); err != nil {
+			fatal("failed to register scheduler job", err)
+		}
+		if err := jobScheduler.Register(scheduler.Job{
+			Name:     "password_reset_cleanup",
+			Interval: interval,
+			Jitter:   interval / 10,
+			Run: func(ctx context.Context) (int64, error) {
+				return passwordResetRepo.DeleteExpiredBefore(ctx, time.Now())
+			},
+		}); err != nil {
+			fatal("failed to register scheduler job", err)
+		}
+		// Строка deny list не нужна после exp токена; leeway — запас на рассинхрон часов при проверке.
+		revokedTokenLeeway := cfg.Auth.Leeway()
+		if err := jobScheduler.Register(scheduler.Job{
+			Name:     "revoked_access_token_cleanup",
+			Interval: interval,
+			Jitter:   interval / 10,
+			Run: func(ctx context.Context) (int64, error) {
+				return revocationRepo.DeleteExpiredBefore(ctx, time.Now().Add(-revokedTokenLeeway))
+			},
+		}); err != nil {
+			fatal("failed to register scheduler job", err)
+		}
+		if err := jobScheduler.Register(scheduler.Job{
+			Name:     "email_verification_cleanup",
+			Interval: interval,
+			Jitter:   interval / 10,
+			Run: func(ctx context.Context) (int64, error) {
+				return emailVerificationRepo.DeleteExpiredBefore(ctx, time.Now())
+			},
+		}); err != nil {
+			fatal("failed to register scheduler job", err)
+		}
+		if strings.EqualFold(cfg.LoginThrottle.Store, config.LoginThrottlePostgres) {
+			window := cfg.LoginThrottle.Window()
+			if err := jobScheduler.Register(scheduler.Job{
+				Name:     "login_attempt_cleanup",
+				Interval: interval,
+				Jitter:   interval / 10,
+				Run: func(ctx context.Context) (int64, error) {
+					return loginAttemptRepo.DeleteStaleBefore(ctx, time.Now().Add(-window))
+				},
+			}); err != nil {
+				fatal("failed to register scheduler job", err)
+			}
+		}
+		if err := jobScheduler.Start(context.Background()); err != nil {
+			fatal("failed to start scheduler", err)
+		}
+	}
+
+	runErr := runServers(ShutdownConfig{
+		DrainDelay: cfg.Server.ShutdownDrainDelay(),
+		Timeout:    cfg.Server.ShutdownTimeout(),
+	}, &draining, servers...)
+
+	// Порядок важен: сначала дождаться фоновых задач и писем и дослать трейсы, пул БД
+	// закрываем последним, когда ни один запрос уже не может к нему обратиться.
+	jobScheduler.Stop()
+	passwordReset.Wait()
 
-	if err := http.ListenAndServe(port, corsHandler); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("failed to flush traces", "error", err)
 	}
+	cancelFlush()
+
+	if err := db.Close(); err != nil {
+		slog.Error("failed to close database pool", "error", err)
+	}
+	slog.Info("database pool closed")
+
+	if runErr != nil {
+		os.Exit(1)
+	}
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
 
-func getEnv(key string, fallback string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// newKeySource выбирает источник ключей подписи JWT по конфигурации.
+func newKeySource(cfg config.AuthConfig) (services.KeySource, error) {
+	switch {
+	case cfg.JWTKeysDir != "":
+		return services.NewDirKeySource(cfg.JWTKeysDir), nil
+	case cfg.JWTKeys != "":
+		keys, err := services.ParseKeyList(cfg.JWTKeys)
+		if err != nil {
+			return nil, err
+		}
+		return services.NewStaticKeySource(keys, cfg.JWTActiveKID), nil
+	default:
+		return services.NewStaticKeySource([]services.SigningKey{
+			services.NewHMACKey(services.LegacyKeyID, []byte(cfg.JWTSecret)),
+		}, services.LegacyKeyID), nil
 	}
-	return fallback
 }
 
-func getEnvInt(key string, fallback int) int {
-	v := os.Getenv(key)
-	if v == "" {
-		return fallback
+// newKeyPolicy возвращает требования к ключам подписи: в production HMAC-секреты
+// из любого источника (и после SIGHUP) проверяются так же строго, как JWT_SECRET.
+// Размер RSA-ключа проверяется всегда, у Ed25519 он фиксирован.
+func newKeyPolicy(cfg config.AppConfig) services.KeyPolicy {
+	if !cfg.IsProduction() {
+		return nil
 	}
-	i, err := strconv.Atoi(v)
-	if err != nil {
-		return fallback
+	return func(key services.SigningKey) error {
+		if key.Algorithm != services.AlgHS256 {
+			return nil
+		}
+		return config.CheckProdSigningSecret(fmt.Sprintf("jwt key %q", key.ID), key.Secret)
 	}
-	return i
 }
 
-func getEnvBool(key string, fallback bool) bool {
-	value := strings.TrimSpace(strings.ToLower(os.Getenv(key)))
-	if value == "" {
-		return fallback
+// newNotifier выбирает канал уведомлений о событиях безопасности.
+// email пишет на подтвержденный адрес, а пользователей без адреса уведомляет через лог.
+func newNotifier(cfg config.SecurityConfig, users repository.UserRepository, mailer services.Mailer) services.Notifier {
+	switch strings.ToLower(strings.TrimSpace(cfg.Notifier)) {
+	case config.NotifierNone:
+		return services.NewNopNotifier()
+	case config.NotifierEmail:
+		return services.NewEmailNotifier(users, mailer, services.NewLogNotifier())
+	default:
+		return services.NewLogNotifier()
 	}
+}
 
-	switch value {
-	case "1", "true", "yes", "on":
-		return true
-	case "0", "false", "no", "off":
-		return false
+// newMailer выбирает доставку писем: smtp — настоящая отправка, console/file только
+// печатают письма (ссылки для разработки берутся из вывода).
+func newMailer(cfg config.MailConfig) (services.Mailer, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Mailer)) {
+	case config.MailerSMTP:
+		return services.NewSMTPMailer(services.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.From,
+		}), nil
+	case config.MailerFile:
+		// Файл открыт все время работы процесса и закрывается вместе с ним.
+		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open mail file: %w", err)
+		}
+		return services.NewWriterMailer(file, cfg.From), nil
 	default:
-		return fallback
+		return services.NewWriterMailer(os.Stdout, cfg.From), nil
 	}
 }
 
-func parseSameSite(value string) http.SameSite {
-	switch strings.ToLower(strings.TrimSpace(value)) {
-	case "none":
-		return http.SameSiteNoneMode
-	case "strict":
-		return http.SameSiteStrictMode
-	case "lax":
-		return http.SameSiteLaxMode
+// newLoginThrottle выбирает хранилище счетчиков неудачных входов: память инстанса
+// или Postgres (общие для кластера счетчики).
+func newLoginThrottle(cfg config.LoginThrottleConfig, repo repository.LoginAttemptRepository) services.LoginThrottle {
+	policy := services.LoginThrottlePolicy{
+		AccountThreshold: cfg.AccountThreshold,
+		IPThreshold:      cfg.IPThreshold,
+		BaseDelay:        cfg.BaseDelay(),
+		MaxLockout:       cfg.MaxLockout(),
+		Window:           cfg.Window(),
+	}
+
+	switch strings.ToLower(strings.TrimSpace(cfg.Store)) {
+	case config.LoginThrottleNone:
+		return services.NewNopLoginThrottle()
+	case config.LoginThrottlePostgres:
+		return services.NewLoginThrottle(repo, policy)
 	default:
-		return http.SameSiteLaxMode
+		return services.NewLoginThrottle(services.NewMemoryLoginAttemptStore(max(cfg.Window(), cfg.MaxLockout())), policy)
+	}
+}
+
+// newPasswordPolicy собирает парольную политику; список утечек подключается, если задан
+// PASSWORD_BREACHED_LIST_FILE, и открывается заново по SIGHUP.
+func newPasswordPolicy(cfg config.PasswordConfig) (services.PasswordPolicy, error) {
+	policyCfg := services.PasswordPolicyConfig{MinLength: cfg.MinLength, MaxBytes: cfg.MaxBytes}
+	if cfg.BreachedListFile == "" {
+		return services.NewPasswordPolicy(policyCfg, nil), nil
+	}
+
+	breached, err := services.NewBreachedPasswordFile(cfg.BreachedListFile)
+	if err != nil {
+		return nil, err
 	}
+	reloadOnSIGHUP("breached password list", breached.Reload)
+	return services.NewPasswordPolicy(policyCfg, breached), nil
+}
+
+// logRoutes выводит зарегистрированные маршруты в лог при старте.
+func logRoutes(router *mux.Router) {
+	_ = router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		slog.Info("route registered", "methods", strings.Join(methods, ","), "path", path)
+		return nil
+	})
 }
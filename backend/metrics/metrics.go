@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gotodo"
+
+// События авторизации для счетчика gotodo_auth_events_total.
+const (
+	AuthLoginSuccess         = "login_success"
+	AuthLoginFailure         = "login_failure"
+	AuthRefreshReuseDetected = "refresh_reuse_detected"
+	AuthFamilyRevoked        = "family_revoked"
+	AuthRefreshGraceReplay   = "refresh_grace_replay"
+	AuthLoginThrottled       = "login_throttled"
+)
+
+// Исходы запуска фоновой задачи для gotodo_job_runs_total.
+const (
+	JobSuccess = "success"
+	JobError   = "error"
+	// JobSkipped — задачу в этот раз выполняет другой инстанс (advisory lock занят).
+	JobSkipped = "skipped"
+)
+
+// Metrics хранит собственный Prometheus registry и все метрики приложения.
+// Методы безопасно вызывать на nil — тогда метрики просто не пишутся.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests        *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+	authEvents          *prometheus.CounterVec
+	todosCreated        prometheus.Counter
+	jobRuns             *prometheus.CounterVec
+	jobDuration         *prometheus.HistogramVec
+	jobItems            *prometheus.CounterVec
+	jobLastSuccess      *prometheus.GaugeVec
+}
+
+// New создает набор метрик и регистрирует в нем gauges пула соединений БД,
+// а также стандартные метрики Go runtime и процесса.
+func New(db *sql.DB) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route template and status code.",
+		}, []string{"method", "route", "status"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method and route template.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		authEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "auth_events_total",
+			Help:      "Authentication outcomes: logins, refresh token reuse, family revocations.",
+		}, []string{"event"}),
+		todosCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "todos_created_total",
+			Help:      "Todos created by users.",
+		}),
+		jobRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "job_runs_total",
+			Help:      "Background job runs by job name and outcome (success, error, skipped).",
+		}, []string{"job", "outcome"}),
+		jobDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "job_duration_seconds",
+			Help:      "Duration of executed background job runs.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"job"}),
+		jobItems: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "job_items_processed_total",
+			Help:      "Records processed by background jobs (e.g. deleted refresh sessions).",
+		}, []string{"job"}),
+		jobLastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "job_last_success_timestamp_seconds",
+			Help:      "Unix time of the last successful run of a background job.",
+		}, []string{"job"}),
+	}
+
+	m.registry.MustRegister(
+		m.httpRequests,
+		m.httpRequestDuration,
+		m.authEvents,
+		m.todosCreated,
+		m.jobRuns,
+		m.jobDuration,
+		m.jobItems,
+		m.jobLastSuccess,
+		collectors.NewDBStatsCollector(db, "postgres"),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// Handler отдает метрики в формате Prometheus exposition.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveHTTPRequest учитывает завершенный HTTP-запрос.
+// route — шаблон маршрута mux (например, /api/todos/{id}), а не сырой путь.
+func (m *Metrics) ObserveHTTPRequest(method string, route string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.httpRequestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// AuthEvent увеличивает счетчик события авторизации (см. константы Auth*).
+func (m *Metrics) AuthEvent(event string) {
+	if m == nil {
+		return
+	}
+
+	m.authEvents.WithLabelValues(event).Inc()
+}
+
+// TodoCreated учитывает созданную задачу.
+func (m *Metrics) TodoCreated() {
+	if m == nil {
+		return
+	}
+
+	m.todosCreated.Inc()
+}
+
+// ObserveJobRun учитывает запуск фоновой задачи: исход (см. константы Job*),
+// длительность и число обработанных записей. Для пропущенного запуска пишется только счетчик.
+func (m *Metrics) ObserveJobRun(job string, outcome string, duration time.Duration, items int64) {
+	if m == nil {
+		return
+	}
+
+	m.jobRuns.WithLabelValues(job, outcome).Inc()
+	if outcome == JobSkipped {
+		return
+	}
+
+	m.jobDuration.WithLabelValues(job).Observe(duration.Seconds())
+	if items > 0 {
+		m.jobItems.WithLabelValues(job).Add(float64(items))
+	}
+	if outcome == JobSuccess {
+		m.jobLastSuccess.WithLabelValues(job).SetToCurrentTime()
+	}
+}
@@ -3,57 +3,122 @@ package middleware
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"goTodo/backend/logger"
 	"goTodo/backend/models"
 	"goTodo/backend/services"
+	"goTodo/backend/tracing"
 )
 
 type contextKey string
 
-const userIDContextKey contextKey = "userId"
+const (
+	userIDContextKey       contextKey = "userId"
+	accessClaimsContextKey contextKey = "accessClaims"
+)
+
+var (
+	ErrMissingAuthorization = errors.New("authorization header is missing")
+	ErrMalformedBearer      = errors.New("authorization header is not a bearer token")
+	ErrEmptyBearerToken     = errors.New("bearer token is empty")
+)
 
 // AuthMiddleware проверяет Bearer access-токен и пускает только авторизованные запросы.
 // Middleware ожидает заголовок Authorization в формате "Bearer <token>".
-// После валидации записывает userId из JWT в context запроса.
-func AuthMiddleware(authService services.AuthService) func(http.Handler) http.Handler {
+// Кроме подписи и claims проверяет, не отозван ли токен (logout, logout everywhere):
+// если проверить отзыв не удалось, запрос отклоняется с 503, а не пропускается.
+// После валидации записывает userId и claims из JWT в context запроса.
+func AuthMiddleware(authService services.AuthService, revocation services.TokenRevocation) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			authHeader := strings.TrimSpace(r.Header.Get("Authorization"))
-			if authHeader == "" {
-				respondWithUnauthorized(w, "Authorization header is required")
-				return
-			}
-
-			const bearerPrefix = "Bearer "
-			if !strings.HasPrefix(authHeader, bearerPrefix) {
-				respondWithUnauthorized(w, "Authorization header must be in format: Bearer <token>")
-				return
-			}
-
-			token := strings.TrimSpace(strings.TrimPrefix(authHeader, bearerPrefix))
-			if token == "" {
-				respondWithUnauthorized(w, "Access token is required")
+			token, err := BearerToken(r)
+			if err != nil {
+				respondWithUnauthorized(w, bearerErrorMessage(err))
 				return
 			}
 
+			ctx, span := tracing.Tracer().Start(r.Context(), "AuthMiddleware.ValidateAccessToken")
 			claims, err := authService.ValidateAccessToken(token)
 			if err != nil {
+				span.SetStatus(codes.Error, "invalid access token")
+				span.End()
 				respondWithUnauthorized(w, "Invalid or expired access token")
 				return
 			}
 			if claims.UserID <= 0 {
+				span.End()
 				respondWithUnauthorized(w, "Invalid access token payload")
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), userIDContextKey, claims.UserID)
+			if err := revocation.Check(ctx, claims); err != nil {
+				span.SetStatus(codes.Error, "access token revoked or check failed")
+				span.End()
+				if errors.Is(err, services.ErrTokenRevoked) {
+					respondWithUnauthorized(w, "Access token has been revoked")
+					return
+				}
+				logger.FromContext(r.Context()).Error("failed to check access token revocation", "error", err)
+				respondWithStatus(w, http.StatusServiceUnavailable, "Failed to verify access token")
+				return
+			}
+			span.End()
+
+			setRequestUserID(r.Context(), claims.UserID)
+			trace.SpanFromContext(r.Context()).SetAttributes(attribute.Int64("user.id", claims.UserID))
+
+			ctx = context.WithValue(r.Context(), userIDContextKey, claims.UserID)
+			ctx = context.WithValue(ctx, accessClaimsContextKey, claims)
+			ctx = logger.WithContext(ctx, logger.FromContext(ctx).With("user_id", claims.UserID))
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// BearerToken извлекает access-токен из заголовка Authorization.
+func BearerToken(r *http.Request) (string, error) {
+	authHeader := strings.TrimSpace(r.Header.Get("Authorization"))
+	if authHeader == "" {
+		return "", ErrMissingAuthorization
+	}
+
+	const bearerPrefix = "Bearer "
+	if !strings.HasPrefix(authHeader, bearerPrefix) {
+		return "", ErrMalformedBearer
+	}
+
+	token := strings.TrimSpace(strings.TrimPrefix(authHeader, bearerPrefix))
+	if token == "" {
+		return "", ErrEmptyBearerToken
+	}
+
+	return token, nil
+}
+
+func bearerErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, ErrMissingAuthorization):
+		return "Authorization header is required"
+	case errors.Is(err, ErrMalformedBearer):
+		return "Authorization header must be in format: Bearer <token>"
+	default:
+		return "Access token is required"
+	}
+}
+
+// AccessClaimsFromContext возвращает claims access-токена, которые положил AuthMiddleware.
+func AccessClaimsFromContext(ctx context.Context) (*services.AccessTokenClaims, bool) {
+	claims, ok := ctx.Value(accessClaimsContextKey).(*services.AccessTokenClaims)
+	return claims, ok && claims != nil
+}
+
 // UserIDFromContext извлекает userId, который ранее положил AuthMiddleware.
 // Возвращает userId и признак успешного извлечения.
 // Если middleware не применен, ok будет false.
@@ -68,8 +133,12 @@ func UserIDFromContext(ctx context.Context) (int64, bool) {
 }
 
 func respondWithUnauthorized(w http.ResponseWriter, message string) {
+	respondWithStatus(w, http.StatusUnauthorized, message)
+}
+
+func respondWithStatus(w http.ResponseWriter, code int, message string) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusUnauthorized)
+	w.WriteHeader(code)
 
 	_ = json.NewEncoder(w).Encode(models.ErrorResponse{Error: message})
 }
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const (
+	forwardedForHeader            = "X-Forwarded-For"
+	clientIPContextKey contextKey = "clientIp"
+)
+
+// ClientIP определяет IP клиента и кладет его в context (см. ClientIPFromContext).
+// По умолчанию это адрес соединения. Если соединение пришло от доверенного прокси
+// (trusted), X-Forwarded-For читается справа налево и берется первый адрес не из trusted:
+// левые записи клиент может подставить сам, правые дописали наши прокси.
+func ClientIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := resolveClientIP(r, trusted)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPContextKey, ip)))
+		})
+	}
+}
+
+// ClientIPFromContext возвращает IP клиента, определенный ClientIP.
+func ClientIPFromContext(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(clientIPContextKey).(string)
+	return ip, ok && ip != ""
+}
+
+func resolveClientIP(r *http.Request, trusted []netip.Prefix) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		remote = host
+	}
+
+	addr, err := netip.ParseAddr(remote)
+	if err != nil || !isTrustedProxy(addr, trusted) {
+		return remote
+	}
+
+	// Несколько заголовков X-Forwarded-For равносильны одному со списком через запятую.
+	hops := strings.Split(strings.Join(r.Header.Values(forwardedForHeader), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// Мусор мог дописать только тот, кто стоит перед последним доверенным адресом.
+			break
+		}
+		addr = hop.Unmap()
+		if !isTrustedProxy(addr, trusted) {
+			break
+		}
+	}
+
+	return addr.String()
+}
+
+func isTrustedProxy(addr netip.Addr, trusted []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+
+	tests := []struct {
+		name         string
+		trusted      []netip.Prefix
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{
+			name:         "no trusted proxies ignores the header",
+			remoteAddr:   "10.0.0.1:4000",
+			forwardedFor: []string{"203.0.113.9"},
+			want:         "10.0.0.1",
+		},
+		{
+			name:         "untrusted peer cannot spoof the header",
+			trusted:      trusted,
+			remoteAddr:   "198.51.100.4:4000",
+			forwardedFor: []string{"203.0.113.9"},
+			want:         "198.51.100.4",
+		},
+		{
+			name:         "trusted proxy forwards the client",
+			trusted:      trusted,
+			remoteAddr:   "10.0.0.1:4000",
+			forwardedFor: []string{"203.0.113.9"},
+			want:         "203.0.113.9",
+		},
+		{
+			name:         "client-supplied entries left of the real hop are ignored",
+			trusted:      trusted,
+			remoteAddr:   "10.0.0.1:4000",
+			forwardedFor: []string{"1.2.3.4, 203.0.113.9, 10.0.0.2"},
+			want:         "203.0.113.9",
+		},
+		{
+			name:         "several headers are one list",
+			trusted:      trusted,
+			remoteAddr:   "10.0.0.1:4000",
+			forwardedFor: []string{"1.2.3.4", "203.0.113.9", "10.0.0.2"},
+			want:         "203.0.113.9",
+		},
+		{
+			name:         "garbage stops at the last trusted hop",
+			trusted:      trusted,
+			remoteAddr:   "10.0.0.1:4000",
+			forwardedFor: []string{"not-an-ip, 10.0.0.2"},
+			want:         "10.0.0.2",
+		},
+		{
+			name:       "trusted proxy without the header",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:4000",
+			want:       "10.0.0.1",
+		},
+		{
+			name:         "only trusted hops yields the leftmost",
+			trusted:      trusted,
+			remoteAddr:   "10.0.0.1:4000",
+			forwardedFor: []string{"10.1.1.1, 10.0.0.2"},
+			want:         "10.1.1.1",
+		},
+		{
+			name:         "ipv6 proxy and ipv4-mapped client",
+			trusted:      trusted,
+			remoteAddr:   "[2001:db8::1]:4000",
+			forwardedFor: []string{"::ffff:203.0.113.9"},
+			want:         "203.0.113.9",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				req.Header.Add(forwardedForHeader, value)
+			}
+
+			var got string
+			ClientIP(tt.trusted)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				got, _ = ClientIPFromContext(r.Context())
+			})).ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Fatalf("client ip = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"goTodo/backend/logger"
+)
+
+const (
+	RequestIDHeader         = "X-Request-ID"
+	maxIncomingRequestIDLen = 128
+)
+
+const requestIDContextKey contextKey = "requestId"
+const requestInfoContextKey contextKey = "requestInfo"
+
+// requestInfo — изменяемые данные запроса, которые внутренние middleware
+// (например, AuthMiddleware) сообщают внешнему access-логу.
+type requestInfo struct {
+	userID int64
+}
+
+// RequestID присваивает запросу X-Request-ID или переиспользует входящий,
+// возвращает его в ответе и кладет в context логгер с атрибутом request_id.
+func RequestID(base *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if !isValidRequestID(requestID) {
+				requestID = uuid.NewString()
+			}
+
+			w.Header().Set(RequestIDHeader, requestID)
+
+			ctx := context.WithValue(r.Context(), requestIDContextKey, requestID)
+			ctx = logger.WithContext(ctx, base.With("request_id", requestID))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// AccessLog пишет по одной записи на запрос: метод, путь, статус, длительность и userId.
+// Должен стоять внутри RequestID, чтобы запись содержала request_id.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &requestInfo{}
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		ctx := context.WithValue(r.Context(), requestInfoContextKey, info)
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		attrs := []any{
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.status,
+			"latency", time.Since(start),
+		}
+		if info.userID > 0 {
+			attrs = append(attrs, "user_id", info.userID)
+		}
+
+		log := logger.FromContext(ctx)
+		switch {
+		case recorder.status >= http.StatusInternalServerError:
+			log.Error("http request", attrs...)
+		case recorder.status >= http.StatusBadRequest:
+			log.Warn("http request", attrs...)
+		default:
+			log.Info("http request", attrs...)
+		}
+	})
+}
+
+// RequestIDFromContext возвращает X-Request-ID текущего запроса.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDContextKey).(string)
+	return requestID, ok && requestID != ""
+}
+
+// setRequestUserID сообщает access-логу userId, определенный во внутреннем middleware.
+func setRequestUserID(ctx context.Context, userID int64) {
+	if info, ok := ctx.Value(requestInfoContextKey).(*requestInfo); ok {
+		info.userID = userID
+	}
+}
+
+func isValidRequestID(value string) bool {
+	if value == "" || len(value) > maxIncomingRequestIDLen {
+		return false
+	}
+
+	for _, c := range value {
+		isAlnum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlnum && c != '-' && c != '_' && c != '.' && c != ':' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// statusRecorder запоминает код ответа для access-лога.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if !r.wroteHeader {
+		r.wroteHeader = true
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap позволяет http.ResponseController добраться до исходного ResponseWriter.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"goTodo/backend/metrics"
+)
+
+const (
+	unmatchedRoute  = "unmatched"
+	otherHTTPMethod = "OTHER"
+)
+
+const metricsRouteContextKey contextKey = "metricsRoute"
+
+// metricsRoute — шаблон маршрута, который MetricsRoute (внутри роутера) сообщает
+// внешнему Metrics; пустой, если mux маршрут не нашел (404/405).
+type metricsRoute struct {
+	template string
+}
+
+// Metrics учитывает количество и длительность запросов в Prometheus. Оборачивает весь
+// роутер, а не подключается через router.Use: mux вызывает Use-middleware только для
+// найденных маршрутов, и 404/405 (сканеры, ошибки клиентов) иначе не попали бы в метрики.
+// Шаблон маршрута сообщает MetricsRoute; запросы без маршрута получают метку "unmatched",
+// поэтому сырые пути в метки не попадают.
+func Metrics(m *metrics.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			route := &metricsRoute{}
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), metricsRouteContextKey, route)))
+
+			template := route.template
+			if template == "" {
+				template = unmatchedRoute
+			}
+			m.ObserveHTTPRequest(metricsMethod(r.Method), template, recorder.status, time.Since(start))
+		})
+	}
+}
+
+// MetricsRoute сообщает Metrics шаблон найденного маршрута. Подключается через router.Use,
+// чтобы mux.CurrentRoute уже был известен.
+func MetricsRoute() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if route, ok := r.Context().Value(metricsRouteContextKey).(*metricsRoute); ok {
+				route.template = routeTemplate(r)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// metricsMethod ограничивает метку метода стандартными методами: net/http принимает
+// любой токен, и произвольные методы от клиента раздували бы число серий.
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return otherHTTPMethod
+	}
+}
+
+func routeTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return unmatchedRoute
+	}
+
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return unmatchedRoute
+	}
+
+	return template
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"goTodo/backend/logger"
+	"goTodo/backend/tracing"
+)
+
+// Tracing открывает серверный span на каждый запрос и продолжает входящий trace
+// из заголовков traceparent/tracestate. Подключается через router.Use, чтобы
+// имя спана строилось по шаблону маршрута mux.
+func Tracing() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := routeTemplate(r)
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+			attrs := []attribute.KeyValue{
+				semconv.HTTPMethod(r.Method),
+				semconv.HTTPRoute(route),
+			}
+			if requestID, ok := RequestIDFromContext(ctx); ok {
+				attrs = append(attrs, attribute.String("http.request_id", requestID))
+			}
+
+			ctx, span := tracing.Tracer().Start(ctx, r.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(attrs...),
+			)
+			defer span.End()
+
+			if spanContext := span.SpanContext(); spanContext.IsValid() {
+				ctx = logger.WithContext(ctx, logger.FromContext(ctx).With("trace_id", spanContext.TraceID().String()))
+			}
+
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+
+			span.SetAttributes(semconv.HTTPStatusCode(recorder.status))
+			if recorder.status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(recorder.status))
+			}
+		})
+	}
+}
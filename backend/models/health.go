@@ -0,0 +1,12 @@
+package models
+
+type HealthCheckResponse struct {
+	Status     string  `json:"status"`
+	DurationMs float64 `json:"durationMs"`
+	Error      string  `json:"error,omitempty"`
+}
+
+type HealthResponse struct {
+	Status string                         `json:"status"`
+	Checks map[string]HealthCheckResponse `json:"checks,omitempty"`
+}
@@ -0,0 +1,18 @@
+package models
+
+// JWK — публичный ключ проверки подписи в формате RFC 7517.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKSResponse — тело /.well-known/jwks.json.
+type JWKSResponse struct {
+	Keys []JWK `json:"keys"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// LoginAttempts — попытки входа по одному ключу (аккаунт или IP): неудачные и еще не
+// проверенные (попытка засчитывается до проверки пароля, успешная потом возвращается).
+// LockedUntil нулевое, если ключ не заблокирован.
+type LoginAttempts struct {
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+// LoginLockout — когда попытка по ключу блокирует следующие: начиная с Threshold-й попытки
+// подряд ключ блокируется на BaseDelay × 2^(попытки − Threshold), но не дольше MaxLockout.
+type LoginLockout struct {
+	Threshold  int
+	BaseDelay  time.Duration
+	MaxLockout time.Duration
+}
@@ -7,9 +7,41 @@ type RefreshSession struct {
 	UserID              int64      `db:"user_id"`
 	TokenHash           string     `db:"token_hash"`
 	FamilyID            string     `db:"family_id"`
+	FamilyIssuedAt      time.Time  `db:"family_issued_at"`
+	RememberMe          bool       `db:"remember_me"`
 	IssuedAt            time.Time  `db:"issued_at"`
 	ExpiresAt           time.Time  `db:"expires_at"`
 	ConsumedAt          *time.Time `db:"consumed_at"`
 	RevokedAt           *time.Time `db:"revoked_at"`
 	ReplacedBySessionID *int64     `db:"replaced_by_session_id"`
+	// GracePair — зашифрованная пара, выданная взамен этой сессии (см. services.RefreshGrace).
+	GracePair []byte `db:"grace_pair"`
+}
+
+// ClientMetadata — данные клиента, с которого создана или обновлена сессия.
+type ClientMetadata struct {
+	UserAgent string
+	IPAddress string
+}
+
+// RefreshSessionFamily — активная цепочка refresh-сессий (одно устройство/вход).
+type RefreshSessionFamily struct {
+	FamilyID        string
+	CreatedAt       time.Time
+	LastRefreshedAt time.Time
+	UserAgent       string
+	IPAddress       string
+}
+
+type SessionResponse struct {
+	ID              string    `json:"id"`
+	CreatedAt       time.Time `json:"createdAt"`
+	LastRefreshedAt time.Time `json:"lastRefreshedAt"`
+	UserAgent       string    `json:"userAgent"`
+	IPAddress       string    `json:"ipAddress"`
+	Current         bool      `json:"current"`
+}
+
+type RevokeSessionsResponse struct {
+	Revoked int64 `json:"revoked"`
 }
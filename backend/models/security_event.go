@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type SecurityEvent struct {
+	ID        int64
+	UserID    int64
+	Type      string
+	IPAddress string
+	UserAgent string
+	Details   map[string]string
+	CreatedAt time.Time
+}
+
+type SecurityEventResponse struct {
+	ID        int64             `json:"id"`
+	Type      string            `json:"type"`
+	IPAddress string            `json:"ipAddress"`
+	UserAgent string            `json:"userAgent"`
+	Details   map[string]string `json:"details,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
@@ -10,6 +10,13 @@ type CreateTodoRequest struct {
 	Value string `json:"value"`
 }
 
+type TodoHistoryResponse struct {
+	Operation string `json:"operation"`
+	Todo      Todo   `json:"todo"`
+}
+
 type ErrorResponse struct {
 	Error string `json:"error"`
+	// Code — машиночитаемая причина для клиента (например, session_expired); есть не у всех ошибок.
+	Code string `json:"code,omitempty"`
 }
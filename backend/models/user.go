@@ -4,7 +4,11 @@ type User struct {
 	ID           int64  `json:"id" db:"id"`
 	Username     string `json:"username" db:"username"`
 	PasswordHash string `json:"-" db:"password_hash"`
-	CreatedAt    string `json:"createdAt" db:"created_at"`
+	// Email — подтвержденный адрес почты (в нижнем регистре); nil, если адреса нет.
+	Email     *string `json:"email,omitempty" db:"email"`
+	CreatedAt string  `json:"createdAt" db:"created_at"`
+	// TokenVersion — версия access-токенов пользователя; отзыв всех токенов ее увеличивает.
+	TokenVersion int64 `json:"-" db:"token_version"`
 }
 
 type RegisterRequest struct {
@@ -13,13 +17,23 @@ type RegisterRequest struct {
 }
 
 type LoginRequest struct {
+	// Username — логин или подтвержденный адрес почты.
 	Username string `json:"username"`
 	Password string `json:"password"`
+	// RememberMe — постоянная cookie на полный срок refresh; false — сессионная cookie
+	// и короткий срок на сервере. Если поле не передано, вход постоянный.
+	RememberMe *bool `json:"rememberMe,omitempty"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
 }
 
 type UserResponse struct {
 	ID        int64  `json:"id"`
 	Username  string `json:"username"`
+	Email     string `json:"email,omitempty"`
 	CreatedAt string `json:"createdAt"`
 }
 
@@ -27,3 +41,42 @@ type AuthResponse struct {
 	AccessToken string       `json:"accessToken"`
 	User        UserResponse `json:"user"`
 }
+
+type PasswordResetRequest struct {
+	// Username — логин или подтвержденный адрес почты.
+	Username string `json:"username"`
+}
+
+type ChangeEmailRequest struct {
+	Email           string `json:"email"`
+	CurrentPassword string `json:"currentPassword"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"newPassword"`
+}
+
+// MessageResponse — ответ без данных, только пояснение для пользователя.
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// PasswordViolation — нарушенное правило парольной политики. Limit — порог правила
+// (минимальная или максимальная длина), чтобы форма могла показать свое сообщение.
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	Limit   int    `json:"limit,omitempty"`
+}
+
+// PasswordPolicyErrorResponse — ответ 400, если пароль не прошел политику: все нарушения сразу.
+type PasswordPolicyErrorResponse struct {
+	Error      string              `json:"error"`
+	Code       string              `json:"code"`
+	Violations []PasswordViolation `json:"violations"`
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"goTodo/backend/logger"
+)
+
+// AccessTokenRevocationRepository хранит отзывы access-токенов:
+// версию токенов пользователя (users.token_version) и deny list по jti.
+type AccessTokenRevocationRepository interface {
+	RevokeToken(ctx context.Context, jti string, userID int64, expiresAt time.Time) error
+	RevokeAllForUser(ctx context.Context, userID int64) (int64, error)
+	GetRevocationState(ctx context.Context, userID int64, jti string) (int64, bool, error)
+	DeleteExpiredBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+type accessTokenRevocationRepository struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewAccessTokenRevocationRepository(db *sql.DB, queryTimeout time.Duration) AccessTokenRevocationRepository {
+	return &accessTokenRevocationRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// RevokeToken добавляет jti в deny list. Повторный отзыв того же токена не ошибка.
+func (r *accessTokenRevocationRepository) RevokeToken(ctx context.Context, jti string, userID int64, expiresAt time.Time) error {
+	ctx, span := startSpan(ctx, "AccessTokenRevocationRepository.RevokeToken", "INSERT", attribute.Int64("user.id", userID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO revoked_access_tokens (jti, user_id, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (jti) DO NOTHING
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, jti, userID, expiresAt); err != nil {
+		return queryError(ctx, span, fmt.Errorf("failed to revoke access token: %w", err))
+	}
+
+	logger.FromContext(ctx).Debug("access token revoked", "user_id", userID)
+	return nil
+}
+
+// RevokeAllForUser увеличивает версию токенов пользователя и возвращает новую: все токены
+// с меньшей версией становятся недействительными.
+func (r *accessTokenRevocationRepository) RevokeAllForUser(ctx context.Context, userID int64) (int64, error) {
+	ctx, span := startSpan(ctx, "AccessTokenRevocationRepository.RevokeAllForUser", "UPDATE", attribute.Int64("user.id", userID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE users
+		SET token_version = token_version + 1
+		WHERE id = $1
+		RETURNING token_version
+	`
+
+	var version int64
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, queryError(ctx, span, fmt.Errorf("user with id %d not found: %w", userID, sql.ErrNoRows))
+		}
+		return 0, queryError(ctx, span, fmt.Errorf("failed to revoke access tokens for user: %w", err))
+	}
+
+	logger.FromContext(ctx).Info("all access tokens revoked for user", "user_id", userID, "token_version", version)
+	return version, nil
+}
+
+// GetRevocationState одним запросом возвращает версию токенов пользователя и признак того,
+// что jti в deny list. Для несуществующего пользователя — sql.ErrNoRows.
+func (r *accessTokenRevocationRepository) GetRevocationState(ctx context.Context, userID int64, jti string) (int64, bool, error) {
+	ctx, span := startSpan(ctx, "AccessTokenRevocationRepository.GetRevocationState", "SELECT", attribute.Int64("user.id", userID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT u.token_version,
+		       EXISTS (SELECT 1 FROM revoked_access_tokens t WHERE t.jti = $2)
+		FROM users u
+		WHERE u.id = $1
+	`
+
+	var version int64
+	var revoked bool
+	if err := r.db.QueryRowContext(ctx, query, userID, jti).Scan(&version, &revoked); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, queryError(ctx, span, fmt.Errorf("user with id %d not found: %w", userID, sql.ErrNoRows))
+		}
+		return 0, false, queryError(ctx, span, fmt.Errorf("failed to read access token revocation state: %w", err))
+	}
+
+	return version, revoked, nil
+}
+
+// DeleteExpiredBefore удаляет из deny list токены, истекшие раньше cutoff: такой токен и так
+// не пройдет проверку exp. Возвращает число удаленных.
+func (r *accessTokenRevocationRepository) DeleteExpiredBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctx, span := startSpan(ctx, "AccessTokenRevocationRepository.DeleteExpiredBefore", "DELETE")
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM revoked_access_tokens WHERE expires_at < $1`, cutoff)
+	if err != nil {
+		return 0, queryError(ctx, span, fmt.Errorf("failed to delete expired revoked access tokens: %w", err))
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, queryError(ctx, span, fmt.Errorf("failed to count deleted revoked access tokens: %w", err))
+	}
+
+	span.SetAttributes(attribute.Int64("db.rows_affected", deleted))
+	return deleted, nil
+}
@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+
+	"goTodo/backend/logger"
+)
+
+// ErrEmailTaken — адрес уже подтвержден другим пользователем или совпадает с чужим логином.
+var ErrEmailTaken = errors.New("email is already in use")
+
+const pgUniqueViolationCode = "23505"
+
+// TokenSends — письма со ссылками (токены подтверждения адреса или сброса пароля),
+// отправленные за период; по ним ограничивается частота писем.
+type TokenSends struct {
+	Count  int
+	First  time.Time
+	Latest time.Time
+}
+
+// EmailChange — адрес, подтвержденный по токену. PreviousEmail — адрес пользователя до этого
+// (пустой, если адреса не было).
+type EmailChange struct {
+	UserID        int64
+	Email         string
+	PreviousEmail string
+}
+
+type EmailVerificationRepository interface {
+	Create(ctx context.Context, userID int64, email string, tokenHash string, expiresAt time.Time) error
+	// Consume гасит токен и переносит адрес из него в users.email.
+	Consume(ctx context.Context, tokenHash string) (EmailChange, error)
+	// FindPendingEmail возвращает адрес из последнего действующего токена пользователя.
+	FindPendingEmail(ctx context.Context, userID int64) (string, error)
+	SendsSince(ctx context.Context, userID int64, since time.Time) (TokenSends, error)
+	DeleteExpiredBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+type emailVerificationRepository struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewEmailVerificationRepository(db *sql.DB, queryTimeout time.Duration) EmailVerificationRepository {
+	return &emailVerificationRepository{db: db, queryTimeout: queryTimeout}
+}
+
+func (r *emailVerificationRepository) Create(ctx context.Context, userID int64, email string, tokenHash string, expiresAt time.Time) error {
+	ctx, span := startSpan(ctx, "EmailVerificationRepository.Create", "INSERT", attribute.Int64("user.id", userID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO email_verification_tokens (user_id, email, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, userID, email, tokenHash, expiresAt); err != nil {
+		return queryError(ctx, span, fmt.Errorf("failed to create email verification token: %w", err))
+	}
+
+	return nil
+}
+
+// Consume одним запросом гасит действующий токен (и остальные токены пользователя — ссылки
+// из прошлых писем больше не нужны) и записывает адрес в users. Если адрес за это время
+// подтвердил другой пользователь, ничего не меняется и возвращается ErrEmailTaken. Адрес,
+// совпадающий с чужим логином, не записывается (иначе вход по нему был бы неоднозначным):
+// токен гасится, возвращается ErrEmailTaken. После смены адреса гасятся и токены сброса
+// пароля: ссылки, ушедшие на прежний адрес, больше не должны работать.
+// Неизвестный, истекший или использованный токен — sql.ErrNoRows.
+func (r *emailVerificationRepository) Consume(ctx context.Context, tokenHash string) (EmailChange, error) {
+	ctx, span := startSpan(ctx, "EmailVerificationRepository.Consume", "UPDATE")
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	// Итоговый SELECT видит users до обновления в verified — это и есть прежний адрес.
+	query := `
+		WITH consumed AS (
+			UPDATE email_verification_tokens
+			SET used_at = NOW()
+			WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+			RETURNING user_id, email
+		), others AS (
+			UPDATE email_verification_tokens
+			SET used_at = NOW()
+			WHERE user_id IN (SELECT user_id FROM consumed) AND used_at IS NULL AND token_hash <> $1
+		), verified AS (
+			UPDATE users
+			SET email = consumed.email, email_verified_at = NOW()
+			FROM consumed
+			WHERE users.id = consumed.user_id
+			  AND NOT EXISTS (
+			      SELECT 1 FROM users other
+			      WHERE other.username = consumed.email AND other.id <> consumed.user_id
+			  )
+			RETURNING users.id
+		), resets AS (
+			UPDATE password_reset_tokens
+			SET used_at = NOW()
+			WHERE user_id IN (SELECT id FROM verified) AND used_at IS NULL
+		)
+		SELECT consumed.user_id, consumed.email, COALESCE(users.email, ''), EXISTS (SELECT 1 FROM verified)
+		FROM consumed
+		JOIN users ON users.id = consumed.user_id
+	`
+
+	var (
+		change   EmailChange
+		verified bool
+	)
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(&change.UserID, &change.Email, &change.PreviousEmail, &verified)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return EmailChange{}, queryError(ctx, span, sql.ErrNoRows)
+		}
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolationCode {
+			span.SetAttributes(attribute.Bool("user.email_taken", true))
+			return EmailChange{}, ErrEmailTaken
+		}
+		return EmailChange{}, queryError(ctx, span, fmt.Errorf("failed to consume email verification token: %w", err))
+	}
+
+	span.SetAttributes(attribute.Int64("user.id", change.UserID))
+	if !verified {
+		span.SetAttributes(attribute.Bool("user.email_taken", true))
+		return EmailChange{}, ErrEmailTaken
+	}
+
+	logger.FromContext(ctx).Info("email verified", "user_id", change.UserID)
+	return change, nil
+}
+
+func (r *emailVerificationRepository) FindPendingEmail(ctx context.Context, userID int64) (string, error) {
+	ctx, span := startSpan(ctx, "EmailVerificationRepository.FindPendingEmail", "SELECT", attribute.Int64("user.id", userID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT email
+		FROM email_verification_tokens
+		WHERE user_id = $1 AND used_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`
+
+	var email string
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&email); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", queryError(ctx, span, fmt.Errorf("no pending email for user %d: %w", userID, sql.ErrNoRows))
+		}
+		return "", queryError(ctx, span, fmt.Errorf("failed to get pending email: %w", err))
+	}
+
+	return email, nil
+}
+
+// SendsSince считает токены, выпущенные пользователю начиная с since (по ним ограничивается
+// частота писем). Если писем не было, First и Latest нулевые.
+func (r *emailVerificationRepository) SendsSince(ctx context.Context, userID int64, since time.Time) (TokenSends, error) {
+	ctx, span := startSpan(ctx, "EmailVerificationRepository.SendsSince", "SELECT", attribute.Int64("user.id", userID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT COUNT(*), MIN(created_at), MAX(created_at)
+		FROM email_verification_tokens
+		WHERE user_id = $1 AND created_at >= $2
+	`
+
+	sends, err := scanTokenSends(r.db.QueryRowContext(ctx, query, userID, since))
+	if err != nil {
+		return TokenSends{}, queryError(ctx, span, fmt.Errorf("failed to count email verification sends: %w", err))
+	}
+
+	return sends, nil
+}
+
+// DeleteExpiredBefore удаляет токены, истекшие раньше cutoff, и возвращает их число.
+func (r *emailVerificationRepository) DeleteExpiredBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctx, span := startSpan(ctx, "EmailVerificationRepository.DeleteExpiredBefore", "DELETE")
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM email_verification_tokens WHERE expires_at < $1`, cutoff)
+	if err != nil {
+		return 0, queryError(ctx, span, fmt.Errorf("failed to delete expired email verification tokens: %w", err))
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, queryError(ctx, span, fmt.Errorf("failed to count deleted email verification tokens: %w", err))
+	}
+
+	span.SetAttributes(attribute.Int64("db.rows_affected", deleted))
+	return deleted, nil
+}
+
+// scanTokenSends читает строку COUNT(*), MIN(created_at), MAX(created_at).
+func scanTokenSends(row *sql.Row) (TokenSends, error) {
+	var (
+		sends         TokenSends
+		first, latest sql.NullTime
+	)
+	if err := row.Scan(&sends.Count, &first, &latest); err != nil {
+		return TokenSends{}, err
+	}
+	sends.First = first.Time
+	sends.Latest = latest.Time
+
+	return sends, nil
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"goTodo/backend/models"
+)
+
+type LoginAttemptRepository interface {
+	// Reserve засчитывает попытку по незаблокированному ключу и, если попыток набралось
+	// lockout.Threshold, блокирует его. Если прошлая попытка была раньше resetBefore, счет
+	// начинается заново. Для заблокированного ключа возвращает reserved == false и блокировку.
+	Reserve(ctx context.Context, key string, now time.Time, resetBefore time.Time, lockout models.LoginLockout) (attempts models.LoginAttempts, reserved bool, err error)
+	// Release вычитает попытку и снимает блокировку, если она все еще равна lockedUntil.
+	Release(ctx context.Context, key string, lockedUntil time.Time) error
+	Reset(ctx context.Context, key string) error
+	// DeleteStaleBefore удаляет счетчики без неудач и блокировок после cutoff.
+	DeleteStaleBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+type loginAttemptRepository struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewLoginAttemptRepository(db *sql.DB, queryTimeout time.Duration) LoginAttemptRepository {
+	return &loginAttemptRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// reservedFailures — счетчик после резерва: заново с 1, если прошлая попытка старше $3.
+const reservedFailures = `CASE WHEN login_attempts.last_failure_at < $3 THEN 1 ELSE login_attempts.failures + 1 END`
+
+// lockoutUntil строит выражение конца блокировки для счетчика failures:
+// $2 + $5 × 2^(failures − $4), но не больше $6 (длительности в миллисекундах).
+func lockoutUntil(failures string) string {
+	return `CASE WHEN ` + failures + ` >= $4 THEN
+		$2 + LEAST($5::float8 * POWER(2, LEAST(` + failures + ` - $4, 40)), $6::float8) * INTERVAL '1 millisecond'
+	END`
+}
+
+// Reserve засчитывает попытку одним upsert: параллельные запросы не проходят мимо порога,
+// потому что каждый видит резервы, сделанные до него. WHERE пропускает заблокированный ключ,
+// тогда upsert ничего не возвращает и блокировка читается отдельным запросом.
+func (r *loginAttemptRepository) Reserve(ctx context.Context, key string, now time.Time, resetBefore time.Time, lockout models.LoginLockout) (models.LoginAttempts, bool, error) {
+	ctx, span := startSpan(ctx, "LoginAttemptRepository.Reserve", "INSERT")
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO login_attempts (key, failures, last_failure_at, locked_until)
+		VALUES ($1, 1, $2, ` + lockoutUntil("1") + `)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = ` + reservedFailures + `,
+			locked_until = ` + lockoutUntil(reservedFailures) + `,
+			last_failure_at = $2
+		WHERE login_attempts.locked_until IS NULL OR login_attempts.locked_until <= $2
+		RETURNING failures, last_failure_at, locked_until
+	`
+
+	attempts, err := scanLoginAttempts(r.db.QueryRowContext(ctx, query,
+		key, now, resetBefore, lockout.Threshold, lockout.BaseDelay.Milliseconds(), lockout.MaxLockout.Milliseconds()))
+	if errors.Is(err, sql.ErrNoRows) {
+		attempts, err = r.get(ctx, key)
+		if err != nil {
+			return models.LoginAttempts{}, false, queryError(ctx, span, fmt.Errorf("failed to get login lockout: %w", err))
+		}
+		span.SetAttributes(attribute.Bool("login.locked", true))
+		return attempts, false, nil
+	}
+	if err != nil {
+		return models.LoginAttempts{}, false, queryError(ctx, span, fmt.Errorf("failed to reserve login attempt: %w", err))
+	}
+
+	span.SetAttributes(attribute.Int("login.failures", attempts.Failures))
+	return attempts, true, nil
+}
+
+// get читает счетчик по ключу; для неизвестного ключа — нулевое значение.
+func (r *loginAttemptRepository) get(ctx context.Context, key string) (models.LoginAttempts, error) {
+	query := `
+		SELECT failures, last_failure_at, locked_until
+		FROM login_attempts
+		WHERE key = $1
+	`
+
+	attempts, err := scanLoginAttempts(r.db.QueryRowContext(ctx, query, key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.LoginAttempts{}, nil
+	}
+	return attempts, err
+}
+
+func (r *loginAttemptRepository) Release(ctx context.Context, key string, lockedUntil time.Time) error {
+	ctx, span := startSpan(ctx, "LoginAttemptRepository.Release", "UPDATE")
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE login_attempts
+		SET failures = GREATEST(failures - 1, 0),
+			locked_until = CASE WHEN locked_until = $2 THEN NULL ELSE locked_until END
+		WHERE key = $1
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, key, sql.NullTime{Time: lockedUntil, Valid: !lockedUntil.IsZero()}); err != nil {
+		return queryError(ctx, span, fmt.Errorf("failed to release login attempt: %w", err))
+	}
+
+	return nil
+}
+
+func (r *loginAttemptRepository) Reset(ctx context.Context, key string) error {
+	ctx, span := startSpan(ctx, "LoginAttemptRepository.Reset", "DELETE")
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM login_attempts WHERE key = $1`, key); err != nil {
+		return queryError(ctx, span, fmt.Errorf("failed to reset login attempts: %w", err))
+	}
+
+	return nil
+}
+
+func (r *loginAttemptRepository) DeleteStaleBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctx, span := startSpan(ctx, "LoginAttemptRepository.DeleteStaleBefore", "DELETE")
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		DELETE FROM login_attempts
+		WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $1)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, cutoff)
+	if err != nil {
+		return 0, queryError(ctx, span, fmt.Errorf("failed to delete stale login attempts: %w", err))
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, queryError(ctx, span, fmt.Errorf("failed to count deleted login attempts: %w", err))
+	}
+
+	span.SetAttributes(attribute.Int64("db.rows_affected", deleted))
+	return deleted, nil
+}
+
+func scanLoginAttempts(row *sql.Row) (models.LoginAttempts, error) {
+	var (
+		attempts    models.LoginAttempts
+		lockedUntil sql.NullTime
+	)
+	if err := row.Scan(&attempts.Failures, &attempts.LastFailureAt, &lockedUntil); err != nil {
+		return models.LoginAttempts{}, err
+	}
+	attempts.LockedUntil = lockedUntil.Time
+
+	return attempts, nil
+}
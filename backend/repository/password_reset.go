@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"goTodo/backend/logger"
+)
+
+type PasswordResetRepository interface {
+	Create(ctx context.Context, userID int64, tokenHash string, expiresAt time.Time, requestedIP string) error
+	// FindUserID возвращает владельца действующего токена, не гася его.
+	FindUserID(ctx context.Context, tokenHash string) (int64, error)
+	Consume(ctx context.Context, tokenHash string) (int64, error)
+	// SendsSince и SendsFromIPSince считают токены, выпущенные пользователю и по запросам
+	// с адреса ip начиная с since. Если токенов не было, First и Latest нулевые.
+	SendsSince(ctx context.Context, userID int64, since time.Time) (TokenSends, error)
+	SendsFromIPSince(ctx context.Context, ip string, since time.Time) (TokenSends, error)
+	DeleteExpiredBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+type passwordResetRepository struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewPasswordResetRepository(db *sql.DB, queryTimeout time.Duration) PasswordResetRepository {
+	return &passwordResetRepository{db: db, queryTimeout: queryTimeout}
+}
+
+func (r *passwordResetRepository) Create(ctx context.Context, userID int64, tokenHash string, expiresAt time.Time, requestedIP string) error {
+	ctx, span := startSpan(ctx, "PasswordResetRepository.Create", "INSERT", attribute.Int64("user.id", userID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at, requested_ip)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, userID, tokenHash, expiresAt, requestedIP); err != nil {
+		return queryError(ctx, span, fmt.Errorf("failed to create password reset token: %w", err))
+	}
+
+	return nil
+}
+
+func (r *passwordResetRepository) FindUserID(ctx context.Context, tokenHash string) (int64, error) {
+	ctx, span := startSpan(ctx, "PasswordResetRepository.FindUserID", "SELECT")
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT user_id
+		FROM password_reset_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+	`
+
+	var userID int64
+	if err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(&userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, queryError(ctx, span, sql.ErrNoRows)
+		}
+		return 0, queryError(ctx, span, fmt.Errorf("failed to find password reset token: %w", err))
+	}
+
+	return userID, nil
+}
+
+// Consume атомарно помечает действующий токен использованным и возвращает id пользователя.
+// Заодно гасит остальные неиспользованные токены пользователя: ссылки из прошлых писем
+// после сброса работать не должны. Неизвестный, истекший или использованный токен — sql.ErrNoRows.
+func (r *passwordResetRepository) Consume(ctx context.Context, tokenHash string) (int64, error) {
+	ctx, span := startSpan(ctx, "PasswordResetRepository.Consume", "UPDATE")
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		WITH consumed AS (
+			UPDATE password_reset_tokens
+			SET used_at = NOW()
+			WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+			RETURNING user_id
+		), others AS (
+			UPDATE password_reset_tokens
+			SET used_at = NOW()
+			WHERE user_id IN (SELECT user_id FROM consumed) AND used_at IS NULL AND token_hash <> $1
+		)
+		SELECT user_id FROM consumed
+	`
+
+	var userID int64
+	if err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(&userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, queryError(ctx, span, sql.ErrNoRows)
+		}
+		return 0, queryError(ctx, span, fmt.Errorf("failed to consume password reset token: %w", err))
+	}
+
+	span.SetAttributes(attribute.Int64("user.id", userID))
+	logger.FromContext(ctx).Info("password reset token consumed", "user_id", userID)
+	return userID, nil
+}
+
+func (r *passwordResetRepository) SendsSince(ctx context.Context, userID int64, since time.Time) (TokenSends, error) {
+	ctx, span := startSpan(ctx, "PasswordResetRepository.SendsSince", "SELECT", attribute.Int64("user.id", userID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT COUNT(*), MIN(created_at), MAX(created_at)
+		FROM password_reset_tokens
+		WHERE user_id = $1 AND created_at >= $2
+	`
+
+	sends, err := scanTokenSends(r.db.QueryRowContext(ctx, query, userID, since))
+	if err != nil {
+		return TokenSends{}, queryError(ctx, span, fmt.Errorf("failed to count password reset sends: %w", err))
+	}
+
+	return sends, nil
+}
+
+func (r *passwordResetRepository) SendsFromIPSince(ctx context.Context, ip string, since time.Time) (TokenSends, error) {
+	ctx, span := startSpan(ctx, "PasswordResetRepository.SendsFromIPSince", "SELECT")
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT COUNT(*), MIN(created_at), MAX(created_at)
+		FROM password_reset_tokens
+		WHERE requested_ip = $1 AND created_at >= $2
+	`
+
+	sends, err := scanTokenSends(r.db.QueryRowContext(ctx, query, ip, since))
+	if err != nil {
+		return TokenSends{}, queryError(ctx, span, fmt.Errorf("failed to count password reset sends from ip: %w", err))
+	}
+
+	return sends, nil
+}
+
+// DeleteExpiredBefore удаляет токены, истекшие раньше cutoff, и возвращает их число.
+func (r *passwordResetRepository) DeleteExpiredBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctx, span := startSpan(ctx, "PasswordResetRepository.DeleteExpiredBefore", "DELETE")
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE expires_at < $1`, cutoff)
+	if err != nil {
+		return 0, queryError(ctx, span, fmt.Errorf("failed to delete expired password reset tokens: %w", err))
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, queryError(ctx, span, fmt.Errorf("failed to count deleted password reset tokens: %w", err))
+	}
+
+	span.SetAttributes(attribute.Int64("db.rows_affected", deleted))
+	return deleted, nil
+}
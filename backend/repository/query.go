@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// withQueryTimeout ограничивает время одного обращения к БД.
+// Дедлайн накладывается поверх контекста запроса, поэтому отмена запроса
+// клиентом тоже прерывает SQL. timeout <= 0 — без собственного дедлайна.
+func withQueryTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// queryError помечает span ошибкой и нормализует прерванные запросы.
+// Драйвер pq сообщает об отмене как "canceling statement due to user request",
+// поэтому если контекст уже отменен, к ошибке добавляется ctx.Err() —
+// вызывающие могут проверять errors.Is(err, context.DeadlineExceeded/Canceled).
+// sql.ErrNoRows не считается сбоем: это штатный результат «не найдено».
+func queryError(ctx context.Context, span trace.Span, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		span.SetAttributes(attribute.Bool("db.not_found", true))
+		return err
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+		err = fmt.Errorf("%w: %w", ctxErr, err)
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	return err
+}
@@ -1,136 +1,436 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
+	"goTodo/backend/logger"
 	"goTodo/backend/models"
 )
 
+// ErrRefreshRotationConflict — сессию уже использовал, отозвал или она истекла к моменту ротации:
+// параллельный запрос с тем же токеном успел первым (или это повторное использование).
+var ErrRefreshRotationConflict = errors.New("refresh session is no longer active")
+
 type RefreshSessionRepository interface {
-	CreateSession(userID int64, familyID string, tokenHash string, expiresAt time.Time) (int64, error)
-	FindByTokenHash(tokenHash string) (*models.RefreshSession, error)
-	RotateSession(oldSessionID int64, userID int64, familyID string, newTokenHash string, newExpiresAt time.Time) error
-	RevokeFamily(familyID string, reason string) error
-	RevokeByTokenHash(tokenHash string, reason string) error
+	CreateSession(ctx context.Context, userID int64, familyID string, tokenHash string, expiresAt time.Time, rememberMe bool, client models.ClientMetadata) (int64, error)
+	FindByTokenHash(ctx context.Context, tokenHash string) (*models.RefreshSession, error)
+	FindByID(ctx context.Context, sessionID int64) (*models.RefreshSession, error)
+	RotateSession(ctx context.Context, oldSessionID int64, userID int64, familyID string, newTokenHash string, newExpiresAt time.Time, gracePair []byte, client models.ClientMetadata) error
+	RevokeFamily(ctx context.Context, familyID string, reason string) error
+	RevokeByTokenHash(ctx context.Context, tokenHash string, reason string) error
+	RevokeAllForUser(ctx context.Context, userID int64, reason string) (int64, error)
+	ListActiveFamilies(ctx context.Context, userID int64) ([]models.RefreshSessionFamily, error)
+	RevokeFamilyForUser(ctx context.Context, familyID string, userID int64, reason string) error
+	RevokeOtherFamilies(ctx context.Context, userID int64, keepFamilyID string, reason string) (int64, error)
+	DeleteInactiveBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error)
 }
 
 type refreshSessionRepository struct {
-	db *sql.DB
+	db           *sql.DB
+	queryTimeout time.Duration
 }
 
-func NewRefreshSessionRepository(db *sql.DB) RefreshSessionRepository {
-	return &refreshSessionRepository{db: db}
+func NewRefreshSessionRepository(db *sql.DB, queryTimeout time.Duration) RefreshSessionRepository {
+	return &refreshSessionRepository{db: db, queryTimeout: queryTimeout}
 }
 
-func (r *refreshSessionRepository) CreateSession(userID int64, familyID string, tokenHash string, expiresAt time.Time) (int64, error) {
+func (r *refreshSessionRepository) CreateSession(ctx context.Context, userID int64, familyID string, tokenHash string, expiresAt time.Time, rememberMe bool, client models.ClientMetadata) (int64, error) {
+	ctx, span := startSpan(ctx, "RefreshSessionRepository.CreateSession", "INSERT", attribute.Int64("user.id", userID), attribute.String("session.family_id", familyID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	var sessionID int64
 	query := `
-		INSERT INTO auth_refresh_sessions (user_id, token_hash, family_id, issued_at, expires_at)
-		VALUES ($1, $2, $3::uuid, NOW(), $4)
+		INSERT INTO auth_refresh_sessions (user_id, token_hash, family_id, issued_at, expires_at, remember_me, user_agent, ip_address)
+		VALUES ($1, $2, $3::uuid, NOW(), $4, $5, $6, $7)
 		RETURNING id
 	`
 
-	err := r.db.QueryRow(query, userID, tokenHash, familyID, expiresAt).Scan(&sessionID)
+	err := r.db.QueryRowContext(ctx, query, userID, tokenHash, familyID, expiresAt, rememberMe, client.UserAgent, client.IPAddress).Scan(&sessionID)
 	if err != nil {
-		return 0, fmt.Errorf("failed to create refresh session: %w", err)
+		return 0, queryError(ctx, span, fmt.Errorf("failed to create refresh session: %w", err))
 	}
 
 	return sessionID, nil
 }
 
-func (r *refreshSessionRepository) FindByTokenHash(tokenHash string) (*models.RefreshSession, error) {
-	session := &models.RefreshSession{}
+func (r *refreshSessionRepository) FindByTokenHash(ctx context.Context, tokenHash string) (*models.RefreshSession, error) {
+	ctx, span := startSpan(ctx, "RefreshSessionRepository.FindByTokenHash", "SELECT")
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
-		SELECT id, user_id, token_hash, family_id::text, issued_at, expires_at, consumed_at, revoked_at, replaced_by_session_id
+		SELECT ` + refreshSessionColumns + `
 		FROM auth_refresh_sessions
 		WHERE token_hash = $1
 	`
 
-	err := r.db.QueryRow(query, tokenHash).Scan(
-		&session.ID,
-		&session.UserID,
-		&session.TokenHash,
-		&session.FamilyID,
-		&session.IssuedAt,
-		&session.ExpiresAt,
-		&session.ConsumedAt,
-		&session.RevokedAt,
-		&session.ReplacedBySessionID,
-	)
+	session, err := scanRefreshSession(r.db.QueryRowContext(ctx, query, tokenHash))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, sql.ErrNoRows
+			return nil, queryError(ctx, span, sql.ErrNoRows)
 		}
-		return nil, fmt.Errorf("failed to find refresh session by token hash: %w", err)
+		return nil, queryError(ctx, span, fmt.Errorf("failed to find refresh session by token hash: %w", err))
 	}
 
 	return session, nil
 }
 
-func (r *refreshSessionRepository) RotateSession(oldSessionID int64, userID int64, familyID string, newTokenHash string, newExpiresAt time.Time) error {
-	tx, err := r.db.Begin()
+// FindByID возвращает сессию по id (например, преемника из replaced_by_session_id).
+func (r *refreshSessionRepository) FindByID(ctx context.Context, sessionID int64) (*models.RefreshSession, error) {
+	ctx, span := startSpan(ctx, "RefreshSessionRepository.FindByID", "SELECT", attribute.Int64("session.id", sessionID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT ` + refreshSessionColumns + `
+		FROM auth_refresh_sessions
+		WHERE id = $1
+	`
+
+	session, err := scanRefreshSession(r.db.QueryRowContext(ctx, query, sessionID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, queryError(ctx, span, sql.ErrNoRows)
+		}
+		return nil, queryError(ctx, span, fmt.Errorf("failed to find refresh session by id: %w", err))
+	}
+
+	return session, nil
+}
+
+// RotateSession атомарно помечает старую сессию использованной и создает преемника.
+// Старая сессия захватывается условным UPDATE (consumed_at IS NULL): строка блокируется
+// до конца транзакции, поэтому из двух параллельных ротаций одного токена проходит одна,
+// а вторая получает ErrRefreshRotationConflict и ничего не меняет.
+// gracePair сохраняется на старой сессии для повтора в grace-окне; у ее предшественницы
+// пара стирается: повтор ей уже не положен, раз преемник использован.
+func (r *refreshSessionRepository) RotateSession(ctx context.Context, oldSessionID int64, userID int64, familyID string, newTokenHash string, newExpiresAt time.Time, gracePair []byte, client models.ClientMetadata) error {
+	ctx, span := startSpan(ctx, "RefreshSessionRepository.RotateSession", "UPDATE", attribute.Int64("user.id", userID), attribute.Int64("session.id", oldSessionID), attribute.String("session.family_id", familyID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin refresh rotation transaction: %w", err)
+		return queryError(ctx, span, fmt.Errorf("failed to begin refresh rotation transaction: %w", err))
 	}
 	defer func() {
 		if err != nil {
-			_ = tx.Rollback()
+			if rollbackErr := tx.Rollback(); rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+				logger.FromContext(ctx).Warn("failed to rollback refresh rotation", "error", rollbackErr)
+			}
 		}
 	}()
 
+	// family_issued_at и remember_me переносятся в преемника: ротация не продлевает
+	// абсолютный срок входа и не меняет его режим.
+	claimQuery := `
+		UPDATE auth_refresh_sessions
+		SET consumed_at = NOW(), updated_at = NOW(), grace_pair = $2
+		WHERE id = $1
+		  AND consumed_at IS NULL
+		  AND revoked_at IS NULL
+		  AND expires_at > NOW()
+		RETURNING family_issued_at, remember_me
+	`
+	var (
+		familyIssuedAt time.Time
+		rememberMe     bool
+	)
+	err = tx.QueryRowContext(ctx, claimQuery, oldSessionID, gracePair).Scan(&familyIssuedAt, &rememberMe)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrRefreshRotationConflict
+		span.SetAttributes(attribute.Bool("session.rotation_conflict", true))
+		logger.FromContext(ctx).Info("refresh rotation lost the race", "user_id", userID, "session_id", oldSessionID)
+		return err
+	}
+	if err != nil {
+		return queryError(ctx, span, fmt.Errorf("failed to consume refresh session: %w", err))
+	}
+
 	var newSessionID int64
 	insertQuery := `
-		INSERT INTO auth_refresh_sessions (user_id, token_hash, family_id, issued_at, expires_at)
-		VALUES ($1, $2, $3::uuid, NOW(), $4)
+		INSERT INTO auth_refresh_sessions (user_id, token_hash, family_id, family_issued_at, issued_at, expires_at, remember_me, user_agent, ip_address)
+		VALUES ($1, $2, $3::uuid, $4, NOW(), $5, $6, $7, $8)
 		RETURNING id
 	`
-	if err = tx.QueryRow(insertQuery, userID, newTokenHash, familyID, newExpiresAt).Scan(&newSessionID); err != nil {
-		return fmt.Errorf("failed to insert rotated refresh session: %w", err)
+	if err = tx.QueryRowContext(ctx, insertQuery, userID, newTokenHash, familyID, familyIssuedAt, newExpiresAt, rememberMe, client.UserAgent, client.IPAddress).Scan(&newSessionID); err != nil {
+		return queryError(ctx, span, fmt.Errorf("failed to insert rotated refresh session: %w", err))
 	}
 
-	updateQuery := `
+	linkQuery := `
 		UPDATE auth_refresh_sessions
-		SET consumed_at = NOW(), replaced_by_session_id = $2, updated_at = NOW()
+		SET replaced_by_session_id = $2
 		WHERE id = $1
 	`
-	if _, err = tx.Exec(updateQuery, oldSessionID, newSessionID); err != nil {
-		return fmt.Errorf("failed to mark old refresh session as consumed: %w", err)
+	if _, err = tx.ExecContext(ctx, linkQuery, oldSessionID, newSessionID); err != nil {
+		return queryError(ctx, span, fmt.Errorf("failed to link rotated refresh session: %w", err))
+	}
+
+	clearQuery := `
+		UPDATE auth_refresh_sessions
+		SET grace_pair = NULL
+		WHERE family_id = $2::uuid AND replaced_by_session_id = $1 AND grace_pair IS NOT NULL
+	`
+	if _, err = tx.ExecContext(ctx, clearQuery, oldSessionID, familyID); err != nil {
+		return queryError(ctx, span, fmt.Errorf("failed to clear previous grace pair: %w", err))
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit refresh rotation transaction: %w", err)
+		return queryError(ctx, span, fmt.Errorf("failed to commit refresh rotation transaction: %w", err))
 	}
 
+	span.SetAttributes(attribute.Int64("session.new_id", newSessionID))
+	logger.FromContext(ctx).Debug("refresh session rotated",
+		"user_id", userID,
+		"old_session_id", oldSessionID,
+		"new_session_id", newSessionID,
+	)
 	return nil
 }
 
-func (r *refreshSessionRepository) RevokeFamily(familyID string, reason string) error {
+func (r *refreshSessionRepository) RevokeFamily(ctx context.Context, familyID string, reason string) error {
+	ctx, span := startSpan(ctx, "RefreshSessionRepository.RevokeFamily", "UPDATE", attribute.String("session.family_id", familyID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
 		UPDATE auth_refresh_sessions
 		SET revoked_at = NOW(), revoke_reason = $2, updated_at = NOW()
 		WHERE family_id = $1::uuid AND revoked_at IS NULL
 	`
 
-	if _, err := r.db.Exec(query, familyID, reason); err != nil {
-		return fmt.Errorf("failed to revoke refresh family: %w", err)
+	result, err := r.db.ExecContext(ctx, query, familyID, reason)
+	if err != nil {
+		return queryError(ctx, span, fmt.Errorf("failed to revoke refresh family: %w", err))
+	}
+
+	if revoked, err := result.RowsAffected(); err == nil {
+		span.SetAttributes(attribute.Int64("db.rows_affected", revoked))
+		logger.FromContext(ctx).Info("refresh family revoked", "family_id", familyID, "reason", reason, "sessions", revoked)
 	}
 
 	return nil
 }
 
-func (r *refreshSessionRepository) RevokeByTokenHash(tokenHash string, reason string) error {
+func (r *refreshSessionRepository) RevokeByTokenHash(ctx context.Context, tokenHash string, reason string) error {
+	ctx, span := startSpan(ctx, "RefreshSessionRepository.RevokeByTokenHash", "UPDATE")
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
 		UPDATE auth_refresh_sessions
 		SET revoked_at = NOW(), revoke_reason = $2, updated_at = NOW()
 		WHERE token_hash = $1 AND revoked_at IS NULL
 	`
 
-	if _, err := r.db.Exec(query, tokenHash, reason); err != nil {
-		return fmt.Errorf("failed to revoke refresh session by token hash: %w", err)
+	if _, err := r.db.ExecContext(ctx, query, tokenHash, reason); err != nil {
+		return queryError(ctx, span, fmt.Errorf("failed to revoke refresh session by token hash: %w", err))
 	}
 
 	return nil
 }
+
+// RevokeAllForUser отзывает все активные refresh-сессии пользователя и возвращает их число.
+func (r *refreshSessionRepository) RevokeAllForUser(ctx context.Context, userID int64, reason string) (int64, error) {
+	ctx, span := startSpan(ctx, "RefreshSessionRepository.RevokeAllForUser", "UPDATE", attribute.Int64("user.id", userID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE auth_refresh_sessions
+		SET revoked_at = NOW(), revoke_reason = $2, updated_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID, reason)
+	if err != nil {
+		return 0, queryError(ctx, span, fmt.Errorf("failed to revoke refresh sessions for user: %w", err))
+	}
+
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		return 0, queryError(ctx, span, fmt.Errorf("failed to count revoked refresh sessions: %w", err))
+	}
+
+	span.SetAttributes(attribute.Int64("db.rows_affected", revoked))
+	logger.FromContext(ctx).Info("all refresh sessions revoked for user", "user_id", userID, "reason", reason, "sessions", revoked)
+	return revoked, nil
+}
+
+// ListActiveFamilies возвращает семьи пользователя, у которых есть действующая сессия
+// (не отозвана, не использована, не истекла). Время создания — family_issued_at (первые
+// сессии семьи могли уже удалить при очистке), последнее обновление и метаданные клиента —
+// последняя сессия.
+func (r *refreshSessionRepository) ListActiveFamilies(ctx context.Context, userID int64) ([]models.RefreshSessionFamily, error) {
+	ctx, span := startSpan(ctx, "RefreshSessionRepository.ListActiveFamilies", "SELECT", attribute.Int64("user.id", userID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT family_id::text,
+		       family_issued_at,
+		       MAX(issued_at),
+		       COALESCE((ARRAY_AGG(user_agent ORDER BY issued_at DESC, id DESC))[1], ''),
+		       COALESCE((ARRAY_AGG(ip_address ORDER BY issued_at DESC, id DESC))[1], '')
+		FROM auth_refresh_sessions
+		WHERE user_id = $1
+		GROUP BY family_id, family_issued_at
+		HAVING BOOL_OR(revoked_at IS NULL AND consumed_at IS NULL AND expires_at > NOW())
+		ORDER BY MAX(issued_at) DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, queryError(ctx, span, fmt.Errorf("failed to list refresh session families: %w", err))
+	}
+	defer rows.Close()
+
+	families := []models.RefreshSessionFamily{}
+	for rows.Next() {
+		var family models.RefreshSessionFamily
+		if err := rows.Scan(&family.FamilyID, &family.CreatedAt, &family.LastRefreshedAt, &family.UserAgent, &family.IPAddress); err != nil {
+			return nil, queryError(ctx, span, fmt.Errorf("failed to scan refresh session family: %w", err))
+		}
+		families = append(families, family)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, queryError(ctx, span, fmt.Errorf("failed to iterate refresh session families: %w", err))
+	}
+
+	span.SetAttributes(attribute.Int("db.rows_returned", len(families)))
+	return families, nil
+}
+
+// RevokeFamilyForUser отзывает семью, только если она принадлежит пользователю.
+// Если активных сессий такой семьи у пользователя нет, возвращает sql.ErrNoRows.
+func (r *refreshSessionRepository) RevokeFamilyForUser(ctx context.Context, familyID string, userID int64, reason string) error {
+	ctx, span := startSpan(ctx, "RefreshSessionRepository.RevokeFamilyForUser", "UPDATE", attribute.Int64("user.id", userID), attribute.String("session.family_id", familyID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE auth_refresh_sessions
+		SET revoked_at = NOW(), revoke_reason = $3, updated_at = NOW()
+		WHERE family_id = $1::uuid AND user_id = $2 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, familyID, userID, reason)
+	if err != nil {
+		return queryError(ctx, span, fmt.Errorf("failed to revoke refresh family: %w", err))
+	}
+
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		return queryError(ctx, span, fmt.Errorf("failed to count revoked refresh sessions: %w", err))
+	}
+	if revoked == 0 {
+		return queryError(ctx, span, fmt.Errorf("refresh family %s not found: %w", familyID, sql.ErrNoRows))
+	}
+
+	span.SetAttributes(attribute.Int64("db.rows_affected", revoked))
+	logger.FromContext(ctx).Info("refresh family revoked", "family_id", familyID, "reason", reason, "sessions", revoked)
+	return nil
+}
+
+// RevokeOtherFamilies отзывает все семьи пользователя, кроме keepFamilyID, и возвращает
+// число отозванных семей. Пустой keepFamilyID (текущая сессия неизвестна) — отзываются все.
+func (r *refreshSessionRepository) RevokeOtherFamilies(ctx context.Context, userID int64, keepFamilyID string, reason string) (int64, error) {
+	ctx, span := startSpan(ctx, "RefreshSessionRepository.RevokeOtherFamilies", "UPDATE", attribute.Int64("user.id", userID), attribute.String("session.family_id", keepFamilyID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		WITH revoked AS (
+			UPDATE auth_refresh_sessions
+			SET revoked_at = NOW(), revoke_reason = $3, updated_at = NOW()
+			WHERE user_id = $1
+			  AND revoked_at IS NULL
+			  AND family_id::text <> $2
+			RETURNING family_id
+		)
+		SELECT COUNT(DISTINCT family_id) FROM revoked
+	`
+
+	var families int64
+	if err := r.db.QueryRowContext(ctx, query, userID, keepFamilyID, reason).Scan(&families); err != nil {
+		return 0, queryError(ctx, span, fmt.Errorf("failed to revoke other refresh families: %w", err))
+	}
+
+	span.SetAttributes(attribute.Int64("session.families_revoked", families))
+	logger.FromContext(ctx).Info("other refresh families revoked", "user_id", userID, "kept_family_id", keepFamilyID, "families", families)
+	return families, nil
+}
+
+// DeleteInactiveBefore удаляет до limit сессий, истекших или отозванных раньше cutoff,
+// и возвращает число удаленных. Пачки ограничены, чтобы не держать долгие блокировки.
+func (r *refreshSessionRepository) DeleteInactiveBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	ctx, span := startSpan(ctx, "RefreshSessionRepository.DeleteInactiveBefore", "DELETE", attribute.Int("db.batch_size", limit))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		DELETE FROM auth_refresh_sessions
+		WHERE id IN (
+			SELECT id
+			FROM auth_refresh_sessions
+			WHERE expires_at < $1 OR revoked_at < $1
+			LIMIT $2
+		)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, cutoff, limit)
+	if err != nil {
+		return 0, queryError(ctx, span, fmt.Errorf("failed to delete inactive refresh sessions: %w", err))
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, queryError(ctx, span, fmt.Errorf("failed to count deleted refresh sessions: %w", err))
+	}
+
+	span.SetAttributes(attribute.Int64("db.rows_affected", deleted))
+	return deleted, nil
+}
+
+const refreshSessionColumns = `id, user_id, token_hash, family_id::text, family_issued_at, remember_me, issued_at, expires_at, consumed_at, revoked_at, replaced_by_session_id, grace_pair`
+
+func scanRefreshSession(row *sql.Row) (*models.RefreshSession, error) {
+	session := &models.RefreshSession{}
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.TokenHash,
+		&session.FamilyID,
+		&session.FamilyIssuedAt,
+		&session.RememberMe,
+		&session.IssuedAt,
+		&session.ExpiresAt,
+		&session.ConsumedAt,
+		&session.RevokedAt,
+		&session.ReplacedBySessionID,
+		&session.GracePair,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"goTodo/backend/logger"
+	"goTodo/backend/models"
+)
+
+type SecurityEventRepository interface {
+	Create(ctx context.Context, event *models.SecurityEvent) error
+	ListByUserID(ctx context.Context, userID int64, limit int) ([]models.SecurityEvent, error)
+	CountSince(ctx context.Context, userID int64, eventType string, since time.Time) (int, error)
+	DeleteBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+}
+
+type securityEventRepository struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewSecurityEventRepository(db *sql.DB, queryTimeout time.Duration) SecurityEventRepository {
+	return &securityEventRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Create сохраняет событие и заполняет его ID и CreatedAt.
+func (r *securityEventRepository) Create(ctx context.Context, event *models.SecurityEvent) error {
+	ctx, span := startSpan(ctx, "SecurityEventRepository.Create", "INSERT", attribute.Int64("user.id", event.UserID), attribute.String("security_event.type", event.Type))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	details, err := json.Marshal(event.Details)
+	if err != nil {
+		return queryError(ctx, span, fmt.Errorf("failed to marshal security event details: %w", err))
+	}
+	if event.Details == nil {
+		details = []byte("{}")
+	}
+
+	query := `
+		INSERT INTO security_events (user_id, event_type, ip_address, user_agent, details)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	err = r.db.QueryRowContext(ctx, query, event.UserID, event.Type, event.IPAddress, event.UserAgent, details).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return queryError(ctx, span, fmt.Errorf("failed to create security event: %w", err))
+	}
+
+	logger.FromContext(ctx).Debug("security event stored", "user_id", event.UserID, "type", event.Type, "event_id", event.ID)
+	return nil
+}
+
+// ListByUserID возвращает последние события пользователя, новые первыми.
+func (r *securityEventRepository) ListByUserID(ctx context.Context, userID int64, limit int) ([]models.SecurityEvent, error) {
+	ctx, span := startSpan(ctx, "SecurityEventRepository.ListByUserID", "SELECT", attribute.Int64("user.id", userID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, user_id, event_type, ip_address, user_agent, details, created_at
+		FROM security_events
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, queryError(ctx, span, fmt.Errorf("failed to list security events: %w", err))
+	}
+	defer rows.Close()
+
+	events := []models.SecurityEvent{}
+	for rows.Next() {
+		var event models.SecurityEvent
+		var details []byte
+		if err := rows.Scan(&event.ID, &event.UserID, &event.Type, &event.IPAddress, &event.UserAgent, &details, &event.CreatedAt); err != nil {
+			return nil, queryError(ctx, span, fmt.Errorf("failed to scan security event: %w", err))
+		}
+		if err := json.Unmarshal(details, &event.Details); err != nil {
+			return nil, queryError(ctx, span, fmt.Errorf("failed to decode security event details: %w", err))
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, queryError(ctx, span, fmt.Errorf("failed to iterate security events: %w", err))
+	}
+
+	span.SetAttributes(attribute.Int("db.rows_returned", len(events)))
+	return events, nil
+}
+
+// CountSince считает события указанного типа у пользователя начиная с since.
+func (r *securityEventRepository) CountSince(ctx context.Context, userID int64, eventType string, since time.Time) (int, error) {
+	ctx, span := startSpan(ctx, "SecurityEventRepository.CountSince", "SELECT", attribute.Int64("user.id", userID), attribute.String("security_event.type", eventType))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT COUNT(*)
+		FROM security_events
+		WHERE user_id = $1 AND event_type = $2 AND created_at >= $3
+	`
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, userID, eventType, since).Scan(&count); err != nil {
+		return 0, queryError(ctx, span, fmt.Errorf("failed to count security events: %w", err))
+	}
+
+	return count, nil
+}
+
+// DeleteBefore удаляет до limit событий, записанных раньше cutoff, и возвращает число удаленных.
+func (r *securityEventRepository) DeleteBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	ctx, span := startSpan(ctx, "SecurityEventRepository.DeleteBefore", "DELETE", attribute.Int("db.batch_size", limit))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		DELETE FROM security_events
+		WHERE id IN (
+			SELECT id
+			FROM security_events
+			WHERE created_at < $1
+			LIMIT $2
+		)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, cutoff, limit)
+	if err != nil {
+		return 0, queryError(ctx, span, fmt.Errorf("failed to delete old security events: %w", err))
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, queryError(ctx, span, fmt.Errorf("failed to count deleted security events: %w", err))
+	}
+
+	span.SetAttributes(attribute.Int64("db.rows_affected", deleted))
+	return deleted, nil
+}
@@ -1,36 +1,48 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
+	"goTodo/backend/logger"
 	"goTodo/backend/models"
 )
 
 // TodoRepository определяет интерфейс для работы с задачами
 // Использование интерфейса позволяет легко тестировать и менять реализацию
 type TodoRepository interface {
-	Create(todo *models.Todo, userID int64) error
-	GetAllByUserID(userID int64) ([]*models.Todo, error)
-	GetByIDForUser(id int64, userID int64) (*models.Todo, error)
-	DeleteForUser(id int64, userID int64) error
+	Create(ctx context.Context, todo *models.Todo, userID int64) error
+	GetAllByUserID(ctx context.Context, userID int64) ([]*models.Todo, error)
+	GetByIDForUser(ctx context.Context, id int64, userID int64) (*models.Todo, error)
+	DeleteForUser(ctx context.Context, id int64, userID int64) (*models.Todo, error)
+	Restore(ctx context.Context, todo *models.Todo, userID int64) error
+	DeleteIfUnchanged(ctx context.Context, todo *models.Todo, userID int64) error
 }
 
 // todoRepository реализует TodoRepository
 type todoRepository struct {
-	db *sql.DB
+	db           *sql.DB
+	queryTimeout time.Duration
 }
 
 // NewTodoRepository создает новый экземпляр репозитория
-func NewTodoRepository(db *sql.DB) TodoRepository {
-	return &todoRepository{db: db}
+func NewTodoRepository(db *sql.DB, queryTimeout time.Duration) TodoRepository {
+	return &todoRepository{db: db, queryTimeout: queryTimeout}
 }
 
 // Create создает новую задачу в БД для конкретного пользователя.
 // ID генерируется самой БД через DEFAULT/IDENTITY у колонки todos.id.
-func (r *todoRepository) Create(todo *models.Todo, userID int64) error {
+func (r *todoRepository) Create(ctx context.Context, todo *models.Todo, userID int64) error {
+	ctx, span := startSpan(ctx, "TodoRepository.Create", "INSERT", attribute.Int64("user.id", userID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	// Дату создания задаём на бэкенде (входящее значение игнорируем)
 	todo.Date = time.Now().UTC().Format(time.RFC3339)
 
@@ -40,26 +52,32 @@ func (r *todoRepository) Create(todo *models.Todo, userID int64) error {
 		RETURNING id, value, date
 	`
 
-	err := r.db.QueryRow(query, todo.Value, todo.Date, userID).Scan(
+	err := r.db.QueryRowContext(ctx, query, todo.Value, todo.Date, userID).Scan(
 		&todo.ID,
 		&todo.Value,
 		&todo.Date,
 	)
 
 	if err != nil {
-		return fmt.Errorf("failed to create todo: %w", err)
+		return queryError(ctx, span, fmt.Errorf("failed to create todo: %w", err))
 	}
 
+	logger.FromContext(ctx).Debug("todo created", "todo_id", todo.ID, "user_id", userID)
 	return nil
 }
 
 // GetAllByUserID получает все задачи текущего пользователя.
-func (r *todoRepository) GetAllByUserID(userID int64) ([]*models.Todo, error) {
+func (r *todoRepository) GetAllByUserID(ctx context.Context, userID int64) ([]*models.Todo, error) {
+	ctx, span := startSpan(ctx, "TodoRepository.GetAllByUserID", "SELECT", attribute.Int64("user.id", userID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `SELECT id, value, date FROM todos WHERE user_id = $1 ORDER BY id DESC`
 
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get todos: %w", err)
+		return nil, queryError(ctx, span, fmt.Errorf("failed to get todos: %w", err))
 	}
 	defer rows.Close()
 
@@ -67,24 +85,30 @@ func (r *todoRepository) GetAllByUserID(userID int64) ([]*models.Todo, error) {
 	for rows.Next() {
 		todo := &models.Todo{}
 		if err := rows.Scan(&todo.ID, &todo.Value, &todo.Date); err != nil {
-			return nil, fmt.Errorf("failed to scan todo: %w", err)
+			return nil, queryError(ctx, span, fmt.Errorf("failed to scan todo: %w", err))
 		}
 		todos = append(todos, todo)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating todos: %w", err)
+		return nil, queryError(ctx, span, fmt.Errorf("error iterating todos: %w", err))
 	}
 
+	span.SetAttributes(attribute.Int("db.rows_returned", len(todos)))
 	return todos, nil
 }
 
 // GetByIDForUser получает задачу по ID, только если она принадлежит пользователю.
-func (r *todoRepository) GetByIDForUser(id int64, userID int64) (*models.Todo, error) {
+func (r *todoRepository) GetByIDForUser(ctx context.Context, id int64, userID int64) (*models.Todo, error) {
+	ctx, span := startSpan(ctx, "TodoRepository.GetByIDForUser", "SELECT", attribute.Int64("user.id", userID), attribute.Int64("todo.id", id))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	todo := &models.Todo{}
 	query := `SELECT id, value, date FROM todos WHERE id = $1 AND user_id = $2`
 
-	err := r.db.QueryRow(query, id, userID).Scan(
+	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(
 		&todo.ID,
 		&todo.Value,
 		&todo.Date,
@@ -92,31 +116,97 @@ func (r *todoRepository) GetByIDForUser(id int64, userID int64) (*models.Todo, e
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("todo with id %d not found: %w", id, sql.ErrNoRows)
+			return nil, queryError(ctx, span, fmt.Errorf("todo with id %d not found: %w", id, sql.ErrNoRows))
 		}
-		return nil, fmt.Errorf("failed to get todo: %w", err)
+		return nil, queryError(ctx, span, fmt.Errorf("failed to get todo: %w", err))
 	}
 
 	return todo, nil
 }
 
 // DeleteForUser удаляет задачу по ID только в рамках текущего пользователя.
-func (r *todoRepository) DeleteForUser(id int64, userID int64) error {
-	query := `DELETE FROM todos WHERE id = $1 AND user_id = $2`
+// Возвращает удалённую задачу, чтобы операцию можно было отменить.
+func (r *todoRepository) DeleteForUser(ctx context.Context, id int64, userID int64) (*models.Todo, error) {
+	ctx, span := startSpan(ctx, "TodoRepository.DeleteForUser", "DELETE", attribute.Int64("user.id", userID), attribute.Int64("todo.id", id))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	todo := &models.Todo{}
+	query := `DELETE FROM todos WHERE id = $1 AND user_id = $2 RETURNING id, value, date`
+
+	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(
+		&todo.ID,
+		&todo.Value,
+		&todo.Date,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, queryError(ctx, span, fmt.Errorf("todo with id %d not found: %w", id, sql.ErrNoRows))
+		}
+		return nil, queryError(ctx, span, fmt.Errorf("failed to delete todo: %w", err))
+	}
+
+	logger.FromContext(ctx).Debug("todo deleted", "todo_id", todo.ID, "user_id", userID)
+	return todo, nil
+}
+
+// Restore возвращает ранее удалённую задачу с её исходными id, value и date.
+// Если строка с таким id уже существует, возвращает sql.ErrNoRows.
+func (r *todoRepository) Restore(ctx context.Context, todo *models.Todo, userID int64) error {
+	ctx, span := startSpan(ctx, "TodoRepository.Restore", "INSERT", attribute.Int64("user.id", userID), attribute.Int64("todo.id", todo.ID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO todos (id, value, date, user_id)
+		OVERRIDING SYSTEM VALUE
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO NOTHING
+		RETURNING id
+	`
+
+	var id int64
+	err := r.db.QueryRowContext(ctx, query, todo.ID, todo.Value, todo.Date, userID).Scan(&id)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return queryError(ctx, span, fmt.Errorf("todo with id %d already exists: %w", todo.ID, sql.ErrNoRows))
+		}
+		return queryError(ctx, span, fmt.Errorf("failed to restore todo: %w", err))
+	}
+
+	logger.FromContext(ctx).Debug("todo restored", "todo_id", id, "user_id", userID)
+	return nil
+}
+
+// DeleteIfUnchanged удаляет задачу, только если она всё ещё совпадает с переданной версией.
+// Если задачи нет или её value/date изменились, возвращает sql.ErrNoRows.
+func (r *todoRepository) DeleteIfUnchanged(ctx context.Context, todo *models.Todo, userID int64) error {
+	ctx, span := startSpan(ctx, "TodoRepository.DeleteIfUnchanged", "DELETE", attribute.Int64("user.id", userID), attribute.Int64("todo.id", todo.ID))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `DELETE FROM todos WHERE id = $1 AND user_id = $2 AND value = $3 AND date = $4`
 
-	result, err := r.db.Exec(query, id, userID)
+	result, err := r.db.ExecContext(ctx, query, todo.ID, userID, todo.Value, todo.Date)
 	if err != nil {
-		return fmt.Errorf("failed to delete todo: %w", err)
+		return queryError(ctx, span, fmt.Errorf("failed to delete todo: %w", err))
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return queryError(ctx, span, fmt.Errorf("failed to get rows affected: %w", err))
 	}
+	span.SetAttributes(attribute.Int64("db.rows_affected", rowsAffected))
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("todo with id %d not found: %w", id, sql.ErrNoRows)
+		return queryError(ctx, span, fmt.Errorf("todo with id %d not found or changed: %w", todo.ID, sql.ErrNoRows))
 	}
 
+	logger.FromContext(ctx).Debug("todo deleted", "todo_id", todo.ID, "user_id", userID)
 	return nil
 }
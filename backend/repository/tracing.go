@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"goTodo/backend/tracing"
+)
+
+// startSpan открывает client-span репозиторного метода (например, "TodoRepository.Create")
+// с общими атрибутами БД. Вызывающий обязан закрыть span через defer span.End().
+func startSpan(ctx context.Context, name string, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append([]attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBOperation(operation),
+	}, attrs...)
+
+	return tracing.Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
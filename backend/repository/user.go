@@ -1,91 +1,207 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
+	"goTodo/backend/logger"
 	"goTodo/backend/models"
 )
 
 type UserRepository interface {
-	CreateUser(username string, passwordHash string) (*models.User, error)
-	FindByUsername(username string) (*models.User, error)
-	FindByID(id int64) (*models.User, error)
+	CreateUser(ctx context.Context, username string, passwordHash string) (*models.User, error)
+	FindByUsername(ctx context.Context, username string) (*models.User, error)
+	FindByID(ctx context.Context, id int64) (*models.User, error)
+	// FindByLogin ищет пользователя по логину или подтвержденному адресу почты.
+	FindByLogin(ctx context.Context, login string) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	UpdatePassword(ctx context.Context, id int64, passwordHash string) error
 }
 
 type userRepository struct {
-	db *sql.DB
+	db           *sql.DB
+	queryTimeout time.Duration
 }
 
-func NewUserRepository(db *sql.DB) UserRepository {
-	return &userRepository{db: db}
+func NewUserRepository(db *sql.DB, queryTimeout time.Duration) UserRepository {
+	return &userRepository{db: db, queryTimeout: queryTimeout}
 }
 
-func (r *userRepository) CreateUser(username string, passwordHash string) (*models.User, error) {
-	user := &models.User{}
+func (r *userRepository) CreateUser(ctx context.Context, username string, passwordHash string) (*models.User, error) {
+	ctx, span := startSpan(ctx, "UserRepository.CreateUser", "INSERT")
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
 		INSERT INTO users (username, password_hash)
 		VALUES ($1, $2)
-		RETURNING id, username, password_hash, created_at::text
+		RETURNING ` + userColumns + `
 	`
 
-	err := r.db.QueryRow(query, username, passwordHash).Scan(
-		&user.ID,
-		&user.Username,
-		&user.PasswordHash,
-		&user.CreatedAt,
-	)
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, username, passwordHash))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		return nil, queryError(ctx, span, fmt.Errorf("failed to create user: %w", err))
 	}
 
+	span.SetAttributes(attribute.Int64("user.id", user.ID))
+	logger.FromContext(ctx).Debug("user created", "user_id", user.ID)
 	return user, nil
 }
 
-func (r *userRepository) FindByUsername(username string) (*models.User, error) {
-	user := &models.User{}
+func (r *userRepository) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	ctx, span := startSpan(ctx, "UserRepository.FindByUsername", "SELECT")
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
-		SELECT id, username, password_hash, created_at::text
+		SELECT ` + userColumns + `
 		FROM users
 		WHERE username = $1
 	`
 
-	err := r.db.QueryRow(query, username).Scan(
-		&user.ID,
-		&user.Username,
-		&user.PasswordHash,
-		&user.CreatedAt,
-	)
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, username))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("user with username %q not found: %w", username, sql.ErrNoRows)
+			return nil, queryError(ctx, span, fmt.Errorf("user with this username not found: %w", sql.ErrNoRows))
 		}
-		return nil, fmt.Errorf("failed to get user by username: %w", err)
+		return nil, queryError(ctx, span, fmt.Errorf("failed to get user by username: %w", err))
 	}
 
 	return user, nil
 }
 
-func (r *userRepository) FindByID(id int64) (*models.User, error) {
-	user := &models.User{}
+func (r *userRepository) FindByID(ctx context.Context, id int64) (*models.User, error) {
+	ctx, span := startSpan(ctx, "UserRepository.FindByID", "SELECT", attribute.Int64("user.id", id))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
-		SELECT id, username, password_hash, created_at::text
+		SELECT ` + userColumns + `
 		FROM users
 		WHERE id = $1
 	`
 
-	err := r.db.QueryRow(query, id).Scan(
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, queryError(ctx, span, fmt.Errorf("user with id %d not found: %w", id, sql.ErrNoRows))
+		}
+		return nil, queryError(ctx, span, fmt.Errorf("failed to get user by id: %w", err))
+	}
+
+	return user, nil
+}
+
+// FindByLogin ищет пользователя по логину или адресу почты. Если строка совпадает и с логином
+// одного пользователя, и с адресом другого, побеждает логин: вход по логину работал раньше почты.
+func (r *userRepository) FindByLogin(ctx context.Context, login string) (*models.User, error) {
+	ctx, span := startSpan(ctx, "UserRepository.FindByLogin", "SELECT")
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE username = $1 OR email = LOWER($1)
+		ORDER BY username = $1 DESC
+		LIMIT 1
+	`
+
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, login))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, queryError(ctx, span, fmt.Errorf("user with this login not found: %w", sql.ErrNoRows))
+		}
+		return nil, queryError(ctx, span, fmt.Errorf("failed to get user by login: %w", err))
+	}
+
+	return user, nil
+}
+
+// FindByEmail ищет пользователя по подтвержденному адресу (email ожидается в нижнем регистре).
+func (r *userRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, span := startSpan(ctx, "UserRepository.FindByEmail", "SELECT")
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE email = $1
+	`
+
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, email))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, queryError(ctx, span, fmt.Errorf("user with this email not found: %w", sql.ErrNoRows))
+		}
+		return nil, queryError(ctx, span, fmt.Errorf("failed to get user by email: %w", err))
+	}
+
+	return user, nil
+}
+
+// UpdatePassword заменяет хеш пароля пользователя и тем же запросом гасит его неиспользованные
+// токены сброса: ссылка из письма, отправленного до смены, не должна сбросить новый пароль.
+// Если пользователя нет, возвращает sql.ErrNoRows.
+func (r *userRepository) UpdatePassword(ctx context.Context, id int64, passwordHash string) error {
+	ctx, span := startSpan(ctx, "UserRepository.UpdatePassword", "UPDATE", attribute.Int64("user.id", id))
+	defer span.End()
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		WITH resets AS (
+			UPDATE password_reset_tokens
+			SET used_at = NOW()
+			WHERE user_id = $1 AND used_at IS NULL
+		)
+		UPDATE users
+		SET password_hash = $2
+		WHERE id = $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query, id, passwordHash)
+	if err != nil {
+		return queryError(ctx, span, fmt.Errorf("failed to update password: %w", err))
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return queryError(ctx, span, fmt.Errorf("failed to count updated users: %w", err))
+	}
+	if updated == 0 {
+		return queryError(ctx, span, fmt.Errorf("user with id %d not found: %w", id, sql.ErrNoRows))
+	}
+
+	logger.FromContext(ctx).Info("user password updated", "user_id", id)
+	return nil
+}
+
+const userColumns = `id, username, password_hash, email, created_at::text, token_version`
+
+func scanUser(row *sql.Row) (*models.User, error) {
+	user := &models.User{}
+	err := row.Scan(
 		&user.ID,
 		&user.Username,
 		&user.PasswordHash,
+		&user.Email,
 		&user.CreatedAt,
+		&user.TokenVersion,
 	)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("user with id %d not found: %w", id, sql.ErrNoRows)
-		}
-		return nil, fmt.Errorf("failed to get user by id: %w", err)
+		return nil, err
 	}
 
 	return user, nil
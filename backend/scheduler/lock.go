@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+)
+
+// Locker выбирает, какой инстанс выполняет очередной запуск задачи.
+// TryLock не ждет: если блокировку держит другой инстанс, возвращает acquired=false.
+// unlock нужно вызвать по завершении запуска, если acquired=true.
+type Locker interface {
+	TryLock(ctx context.Context, name string) (unlock func(), acquired bool, err error)
+}
+
+// advisoryLockNamespace отделяет ключи задач от остальных advisory lock приложения
+// (например, от блокировки миграций).
+const advisoryLockNamespace = "gotodo.scheduler."
+
+// advisoryLocker — leader election через pg_try_advisory_lock. Блокировка сессионная,
+// поэтому держится на выделенном соединении пула весь запуск; если инстанс упадет,
+// Postgres снимет ее вместе с соединением.
+type advisoryLocker struct {
+	db *sql.DB
+}
+
+// NewAdvisoryLocker создает Locker на advisory lock Postgres.
+func NewAdvisoryLocker(db *sql.DB) Locker {
+	return &advisoryLocker{db: db}
+}
+
+func (l *advisoryLocker) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	key := advisoryLockKey(name)
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get connection for job lock: %w", err)
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&acquired); err != nil {
+		_ = conn.Close()
+		return nil, false, fmt.Errorf("failed to acquire job lock %q: %w", name, err)
+	}
+	if !acquired {
+		_ = conn.Close()
+		return nil, false, nil
+	}
+
+	unlock := func() {
+		// Контекст запуска к этому моменту может быть отменен — снимаем блокировку в любом случае.
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, key); err != nil {
+			slog.Warn("failed to release job lock, dropping connection", "job", name, "error", err)
+			// Соединение с неснятой блокировкой нельзя возвращать в пул: ErrBadConn
+			// заставляет database/sql закрыть его, и Postgres освободит блокировку сам.
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		_ = conn.Close()
+	}
+
+	return unlock, true, nil
+}
+
+// advisoryLockKey переводит имя задачи в bigint-ключ advisory lock.
+func advisoryLockKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(advisoryLockNamespace + name))
+	return int64(h.Sum64())
+}
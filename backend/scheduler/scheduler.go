@@ -0,0 +1,197 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+
+	"goTodo/backend/logger"
+	"goTodo/backend/metrics"
+)
+
+var (
+	ErrInvalidJob     = errors.New("invalid scheduler job")
+	ErrAlreadyStarted = errors.New("scheduler is already started")
+)
+
+// Func выполняет один запуск задачи и возвращает число обработанных записей.
+type Func func(ctx context.Context) (int64, error)
+
+// Job — периодическая фоновая задача.
+type Job struct {
+	// Name — уникальное имя задачи: метка метрик, поле логов и ключ advisory lock.
+	Name     string
+	Interval time.Duration
+	// Jitter — максимальное случайное отклонение интервала в обе стороны,
+	// чтобы инстансы, запущенные одновременно, не ломились за блокировкой хором.
+	Jitter time.Duration
+	// Timeout — дедлайн одного запуска; 0 означает Interval.
+	Timeout time.Duration
+	Run     Func
+}
+
+// Scheduler запускает зарегистрированные задачи по расписанию внутри процесса сервера.
+// Каждый запуск выполняет только инстанс, взявший блокировку задачи (см. Locker),
+// остальные пропускают его до следующего интервала.
+type Scheduler struct {
+	locker  Locker
+	metrics *metrics.Metrics
+	log     *slog.Logger
+
+	mu     sync.Mutex
+	jobs   []Job
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New создает планировщик. Параметры: locker — выбор инстанса для запуска;
+// metrics — метрики запусков (может быть nil); log — логгер задач.
+func New(locker Locker, metrics *metrics.Metrics, log *slog.Logger) *Scheduler {
+	return &Scheduler{
+		locker:  locker,
+		metrics: metrics,
+		log:     log,
+	}
+}
+
+// Register добавляет задачу. Задачи регистрируются до Start.
+func (s *Scheduler) Register(job Job) error {
+	switch {
+	case job.Name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidJob)
+	case job.Run == nil:
+		return fmt.Errorf("%w %q: run func is required", ErrInvalidJob, job.Name)
+	case job.Interval <= 0:
+		return fmt.Errorf("%w %q: interval must be positive", ErrInvalidJob, job.Name)
+	case job.Jitter < 0 || job.Jitter >= job.Interval:
+		return fmt.Errorf("%w %q: jitter must be in range [0, interval)", ErrInvalidJob, job.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		return ErrAlreadyStarted
+	}
+	for _, existing := range s.jobs {
+		if existing.Name == job.Name {
+			return fmt.Errorf("%w: duplicate job name %q", ErrInvalidJob, job.Name)
+		}
+	}
+	s.jobs = append(s.jobs, job)
+
+	return nil
+}
+
+// Start запускает цикл каждой задачи в отдельной горутине. Первый запуск —
+// через случайную задержку в пределах Jitter, чтобы рестарт не совпадал с запуском.
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		return ErrAlreadyStarted
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	for _, job := range s.jobs {
+		job := job
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.loop(ctx, job)
+		}()
+		s.log.Info("scheduler job registered", "job", job.Name, "interval", job.Interval, "jitter", job.Jitter)
+	}
+
+	return nil
+}
+
+// Stop отменяет контекст задач и ждет завершения текущих запусков.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	s.wg.Wait()
+	s.log.Info("scheduler stopped")
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	timer := time.NewTimer(randomDuration(job.Jitter))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		s.runOnce(ctx, job)
+		timer.Reset(nextInterval(job.Interval, job.Jitter))
+	}
+}
+
+// runOnce выполняет один запуск под блокировкой задачи и пишет лог и метрики.
+func (s *Scheduler) runOnce(ctx context.Context, job Job) {
+	timeout := job.Timeout
+	if timeout <= 0 {
+		timeout = job.Interval
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	log := s.log.With("job", job.Name)
+	ctx = logger.WithContext(ctx, log)
+
+	unlock, acquired, err := s.locker.TryLock(ctx, job.Name)
+	if err != nil {
+		log.Error("scheduler job lock failed", "error", err)
+		s.metrics.ObserveJobRun(job.Name, metrics.JobError, 0, 0)
+		return
+	}
+	if !acquired {
+		log.Debug("scheduler job skipped: running on another instance")
+		s.metrics.ObserveJobRun(job.Name, metrics.JobSkipped, 0, 0)
+		return
+	}
+	defer unlock()
+
+	started := time.Now()
+	items, err := job.Run(ctx)
+	duration := time.Since(started)
+
+	if err != nil {
+		log.Error("scheduler job failed", "duration", duration, "items", items, "error", err)
+		s.metrics.ObserveJobRun(job.Name, metrics.JobError, duration, items)
+		return
+	}
+
+	log.Info("scheduler job finished", "duration", duration, "items", items)
+	s.metrics.ObserveJobRun(job.Name, metrics.JobSuccess, duration, items)
+}
+
+// nextInterval возвращает interval ± случайное отклонение до jitter.
+func nextInterval(interval, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return interval
+	}
+	return interval - jitter + randomDuration(2*jitter)
+}
+
+// randomDuration возвращает случайную длительность в [0, max].
+func randomDuration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max) + 1))
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ServerTimeouts — таймауты http.Server. Защищают от медленных клиентов
+// (slowloris), которые иначе могут бесконечно держать соединения.
+type ServerTimeouts struct {
+	ReadHeader time.Duration
+	Read       time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+}
+
+// ShutdownConfig описывает остановку сервера.
+// DrainDelay — сколько readiness отдает 503 до закрытия listener, чтобы балансировщик
+// успел вывести инстанс из ротации; Timeout — дедлайн на завершение активных запросов.
+type ShutdownConfig struct {
+	DrainDelay time.Duration
+	Timeout    time.Duration
+}
+
+// newHTTPServer создает http.Server с заданными таймаутами.
+func newHTTPServer(addr string, handler http.Handler, timeouts ServerTimeouts) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: timeouts.ReadHeader,
+		ReadTimeout:       timeouts.Read,
+		WriteTimeout:      timeouts.Write,
+		IdleTimeout:       timeouts.Idle,
+	}
+}
+
+// runServers запускает серверы и блокируется до SIGINT/SIGTERM или падения одного из них.
+// После сигнала выставляет draining (readiness начинает отдавать 503), ждет DrainDelay
+// и вызывает Shutdown у всех серверов с общим дедлайном cfg.Timeout.
+// Повторный сигнал во время остановки завершает процесс сразу.
+// Возвращает ошибку, если какой-то сервер упал или не успел остановиться.
+func runServers(cfg ShutdownConfig, draining *atomic.Bool, servers ...*http.Server) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, len(servers))
+	for _, server := range servers {
+		server := server
+		go func() {
+			slog.Info("http server listening", "addr", server.Addr)
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- err
+			}
+		}()
+	}
+
+	var runErr error
+	select {
+	case runErr = <-serverErr:
+		slog.Error("http server failed", "error", runErr)
+	case <-ctx.Done():
+		slog.Info("shutdown signal received, draining", "drain_delay", cfg.DrainDelay, "timeout", cfg.Timeout)
+	}
+	// Возвращаем стандартную обработку сигналов: второй Ctrl+C убьет процесс.
+	stop()
+
+	draining.Store(true)
+	if runErr == nil && cfg.DrainDelay > 0 {
+		time.Sleep(cfg.DrainDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	for _, server := range servers {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("http server did not drain in time", "addr", server.Addr, "error", err)
+			runErr = errors.Join(runErr, err)
+		}
+	}
+
+	slog.Info("http servers stopped")
+	return runErr
+}
+
+// reloadOnSIGHUP вызывает reload при каждом SIGHUP (kill -HUP <pid>), не перезапуская процесс.
+// Ошибка перезагрузки логируется, а сервис продолжает работать со старым состоянием.
+func reloadOnSIGHUP(name string, reload func() error) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		for range hup {
+			slog.Info("SIGHUP received, reloading", "target", name)
+			if err := reload(); err != nil {
+				slog.Error("reload failed, keeping previous state", "target", name, "error", err)
+			}
+		}
+	}()
+}
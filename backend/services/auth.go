@@ -12,17 +12,24 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+
+	"goTodo/backend/models"
 )
 
 var (
 	ErrInvalidToken           = errors.New("invalid access token")
 	ErrInvalidTokenSigning    = errors.New("invalid token signing method")
 	ErrInvalidCredentials     = errors.New("invalid credentials")
-	ErrEmptyJWTSecret         = errors.New("jwt secret is required")
+	ErrNilKeyProvider         = errors.New("jwt key provider is required")
 	ErrInvalidAccessTokenTTL  = errors.New("access token ttl must be greater than zero")
 	ErrInvalidRefreshTokenTTL = errors.New("refresh token ttl must be greater than zero")
 	ErrFailedToGenerateToken  = errors.New("failed to generate token")
+	ErrEmptyIssuer            = errors.New("jwt issuer is required")
+	ErrEmptyAudience          = errors.New("jwt audience is required")
+	ErrNegativeLeeway         = errors.New("jwt leeway must not be negative")
+	ErrMissingTokenID         = errors.New("token has no jti")
 )
 
 // AuthService описывает операции прикладной авторизации.
@@ -31,37 +38,67 @@ var (
 type AuthService interface {
 	HashPassword(password string) (string, error)
 	VerifyPassword(password string, passwordHash string) error
-	GenerateAccessToken(userID int64, username string) (string, error)
+	// VerifyDummyPassword тратит на проверку столько же времени, сколько VerifyPassword,
+	// но против фиктивного хеша: вход с неизвестным логином не отличим по времени ответа.
+	VerifyDummyPassword(password string)
+	// GenerateAccessToken выпускает access-токен входа sessionID (семьи refresh-сессий).
+	GenerateAccessToken(user *models.User, sessionID string) (string, error)
 	ValidateAccessToken(token string) (*AccessTokenClaims, error)
+	PublicJWKS() models.JWKSResponse
 	GenerateRefreshToken() (string, string, error)
 	HashRefreshToken(token string) string
 }
 
 // AccessTokenClaims хранит payload access-токена.
-// Включает пользовательские поля (UserID, Username) и стандартные
-// зарегистрированные JWT claims (sub, iat, exp).
+// Включает пользовательские поля (UserID, Username, TokenVersion, SessionID) и стандартные
+// зарегистрированные JWT claims (iss, sub, aud, jti, iat, nbf, exp).
+// TokenVersion (ver) — версия токенов пользователя на момент выпуска, по ней TokenRevocation
+// отзывает все токены пользователя; в токенах без ver она равна 0.
+// SessionID (sid) — семья refresh-сессий входа, которому выдан токен: по нему эндпоинты вне
+// пути refresh cookie узнают текущее устройство. В токенах, выпущенных до sid, пуст.
 type AccessTokenClaims struct {
-	UserID   int64  `json:"userId"`
-	Username string `json:"username"`
+	UserID       int64  `json:"userId"`
+	Username     string `json:"username"`
+	TokenVersion int64  `json:"ver"`
+	SessionID    string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// TokenClaimsConfig — параметры стандартных claims access-токена.
+// Issuer и Audience отличают окружения: токен, выпущенный для staging,
+// не примется в prod, даже если у них совпадает ключ. Leeway — допустимый
+// рассинхрон часов при проверке exp/nbf/iat.
+type TokenClaimsConfig struct {
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+}
+
 // authService — конкретная реализация AuthService.
-// Содержит секрет подписи, TTL access-токена и источник времени,
+// Содержит провайдер ключей подписи, TTL access-токена и источник времени,
 // который можно подменять в тестах для предсказуемых сценариев.
 type authService struct {
-	jwtSecret       []byte
+	keys            SigningKeyProvider
 	accessTokenTTL  time.Duration
 	refreshTokenTTL time.Duration
+	claims          TokenClaimsConfig
+	parser          *jwt.Parser
+	dummyHash       []byte
 	now             func() time.Time
 }
 
 // NewAuthService создает новый экземпляр AuthService с проверкой входных параметров.
-// Параметры: jwtSecret — секрет для HS256 подписи; accessTokenTTL — срок жизни access.
-// Возвращает: готовый сервис или ошибку, если секрет пустой/TTL некорректный.
-func NewAuthService(jwtSecret string, accessTokenTTL time.Duration, refreshTokenTTL time.Duration) (AuthService, error) {
-	if strings.TrimSpace(jwtSecret) == "" {
-		return nil, ErrEmptyJWTSecret
+// Параметры: keys — ключи HS256 (подпись активным, проверка по kid); accessTokenTTL — срок жизни access.
+// claims — iss/aud/leeway для выпуска и проверки.
+// Возвращает: готовый сервис или ошибку, если провайдер ключей не задан/TTL или claims некорректны.
+func NewAuthService(
+	keys SigningKeyProvider,
+	accessTokenTTL time.Duration,
+	refreshTokenTTL time.Duration,
+	claims TokenClaimsConfig,
+) (AuthService, error) {
+	if keys == nil {
+		return nil, ErrNilKeyProvider
 	}
 	if accessTokenTTL <= 0 {
 		return nil, ErrInvalidAccessTokenTTL
@@ -69,13 +106,44 @@ func NewAuthService(jwtSecret string, accessTokenTTL time.Duration, refreshToken
 	if refreshTokenTTL <= 0 {
 		return nil, ErrInvalidRefreshTokenTTL
 	}
+	if strings.TrimSpace(claims.Issuer) == "" {
+		return nil, ErrEmptyIssuer
+	}
+	if strings.TrimSpace(claims.Audience) == "" {
+		return nil, ErrEmptyAudience
+	}
+	if claims.Leeway < 0 {
+		return nil, ErrNegativeLeeway
+	}
+
+	// Хеш случайной строки с той же стоимостью, что и у настоящих паролей.
+	dummyPassword, _, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte(dummyPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare dummy password hash: %w", err)
+	}
 
-	return &authService{
-		jwtSecret:       []byte(jwtSecret),
+	s := &authService{
+		keys:            keys,
 		accessTokenTTL:  accessTokenTTL,
 		refreshTokenTTL: refreshTokenTTL,
+		claims:          claims,
+		dummyHash:       dummyHash,
 		now:             time.Now,
-	}, nil
+	}
+	s.parser = jwt.NewParser(
+		jwt.WithIssuer(claims.Issuer),
+		jwt.WithAudience(claims.Audience),
+		jwt.WithLeeway(claims.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithTimeFunc(func() time.Time { return s.now() }),
+	)
+
+	return s, nil
 }
 
 // HashPassword хеширует открытый пароль через bcrypt.
@@ -103,23 +171,37 @@ func (s *authService) VerifyPassword(password string, passwordHash string) error
 	return nil
 }
 
-// GenerateAccessToken выпускает подписанный access JWT с пользовательскими claims.
-// Параметры: userID — идентификатор пользователя; username — логин пользователя.
+// VerifyDummyPassword сверяет пароль с хешем случайной строки; результат не важен, важно время.
+func (s *authService) VerifyDummyPassword(password string) {
+	_ = bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+}
+
+// GenerateAccessToken выпускает access JWT, подписанный активным ключом (kid в заголовке).
+// Параметры: user — пользователь, его TokenVersion попадает в claim ver; sessionID — семья
+// refresh-сессий входа (claim sid).
 // Возвращает: строку JWT или ошибку, если токен не удалось подписать.
-func (s *authService) GenerateAccessToken(userID int64, username string) (string, error) {
+func (s *authService) GenerateAccessToken(user *models.User, sessionID string) (string, error) {
 	now := s.now().UTC()
 	claims := AccessTokenClaims{
-		UserID:   userID,
-		Username: username,
+		UserID:       user.ID,
+		Username:     user.Username,
+		TokenVersion: user.TokenVersion,
+		SessionID:    sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			Subject:   strconv.FormatInt(userID, 10),
+			ID:        uuid.NewString(),
+			Issuer:    s.claims.Issuer,
+			Subject:   strconv.FormatInt(user.ID, 10),
+			Audience:  jwt.ClaimStrings{s.claims.Audience},
 			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTokenTTL)),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(s.jwtSecret)
+	key := s.keys.ActiveKey()
+	token := jwt.NewWithClaims(key.signingMethod(), claims)
+	token.Header["kid"] = key.ID
+	tokenString, err := token.SignedString(key.signKey())
 	if err != nil {
 		return "", fmt.Errorf("failed to sign access token: %w", err)
 	}
@@ -127,16 +209,26 @@ func (s *authService) GenerateAccessToken(userID int64, username string) (string
 	return tokenString, nil
 }
 
-// ValidateAccessToken валидирует access JWT и извлекает claims.
+// ValidateAccessToken валидирует access JWT ключом из заголовка kid и извлекает claims.
+// Алгоритм токена должен совпадать с алгоритмом ключа; iss и aud — с настройками сервиса;
+// exp обязателен, exp/nbf/iat проверяются с допуском leeway; jti обязателен.
+// Токены, подписанные выведенным из ротации ключом, принимаются, пока ключ есть в наборе.
 // Параметры: token — строка JWT из заголовка Authorization (без префикса Bearer).
 // Возвращает: AccessTokenClaims при успехе или ErrInvalidToken/другую ошибку.
 func (s *authService) ValidateAccessToken(token string) (*AccessTokenClaims, error) {
 	claims := &AccessTokenClaims{}
-	parsedToken, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+	parsedToken, err := s.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := s.keys.VerificationKey(kid)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownKeyID, kid)
+		}
+		// Алгоритм берем из ключа, а не из токена: иначе возможна подмена
+		// RS256 → HS256 с публичным ключом в роли HMAC-секрета.
+		if t.Method.Alg() != key.Algorithm {
 			return nil, ErrInvalidTokenSigning
 		}
-		return s.jwtSecret, nil
+		return key.verifyKey(), nil
 	})
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
@@ -144,22 +236,53 @@ func (s *authService) ValidateAccessToken(token string) (*AccessTokenClaims, err
 	if !parsedToken.Valid {
 		return nil, ErrInvalidToken
 	}
+	if claims.ID == "" {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, ErrMissingTokenID)
+	}
 
 	return claims, nil
 }
 
+// PublicJWKS возвращает публичные ключи проверки (RS256/EdDSA), включая еще принимаемые
+// выведенные, для /.well-known/jwks.json. HMAC-ключи не публикуются.
+func (s *authService) PublicJWKS() models.JWKSResponse {
+	response := models.JWKSResponse{Keys: []models.JWK{}}
+	for _, key := range s.keys.VerificationKeys() {
+		if key.Algorithm == AlgHS256 {
+			continue
+		}
+		jwk, err := key.PublicJWK()
+		if err != nil {
+			continue
+		}
+		response.Keys = append(response.Keys, jwk)
+	}
+	return response
+}
+
 // GenerateRefreshToken создает новый opaque refresh token и его SHA-256 hash для хранения в БД.
 func (s *authService) GenerateRefreshToken() (string, string, error) {
+	return generateOpaqueToken()
+}
+
+func (s *authService) HashRefreshToken(token string) string {
+	return hashOpaqueToken(token)
+}
+
+// generateOpaqueToken создает случайный токен (256 бит, base64url) и его hash.
+// В БД хранится только hash, поэтому утечка таблицы не дает рабочих токенов.
+func generateOpaqueToken() (string, string, error) {
 	randomBytes := make([]byte, 32)
 	if _, err := rand.Read(randomBytes); err != nil {
 		return "", "", fmt.Errorf("%w: %v", ErrFailedToGenerateToken, err)
 	}
 
 	rawToken := base64.RawURLEncoding.EncodeToString(randomBytes)
-	return rawToken, s.HashRefreshToken(rawToken), nil
+	return rawToken, hashOpaqueToken(rawToken), nil
 }
 
-func (s *authService) HashRefreshToken(token string) string {
+// hashOpaqueToken возвращает hex SHA-256 токена. Соль не нужна: токен случайный и длинный.
+func hashOpaqueToken(token string) string {
 	hash := sha256.Sum256([]byte(token))
 	return hex.EncodeToString(hash[:])
 }
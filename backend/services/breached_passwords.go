@@ -0,0 +1,201 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// breachedScanSize — когда диапазон бинарного поиска сужается до стольких байт,
+// оставшиеся строки читаются подряд.
+const breachedScanSize = 4096
+
+// BreachedPasswords проверяет, встречался ли пароль в известных утечках.
+type BreachedPasswords interface {
+	Breached(ctx context.Context, password string) (bool, error)
+}
+
+// BreachedPasswordFile — офлайн-список утекших паролей в формате Pwned Passwords:
+// строки "SHA1[:COUNT]" (40 hex-символов в одном регистре), отсортированные по хешу, как
+// выгрузка haveibeenpwned "ordered by hash"; концы строк LF или CRLF, пустые строки пропускаются.
+// Файл в память не читается: каждая проверка — бинарный поиск по нему через ReadAt, поэтому
+// подходит и полная выгрузка в десятки гигабайт.
+// Сами пароли нигде не хранятся и не передаются.
+type BreachedPasswordFile struct {
+	path string
+
+	mu   sync.RWMutex
+	file *os.File
+	size int64
+}
+
+// NewBreachedPasswordFile открывает список path. Reload открывает файл заново (например,
+// после замены на новую выгрузку); ошибка оставляет прежний.
+func NewBreachedPasswordFile(path string) (*BreachedPasswordFile, error) {
+	list := &BreachedPasswordFile{path: path}
+	if err := list.Reload(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Reload открывает файл и проверяет формат первой непустой строки. Порядок строк не проверяется:
+// это потребовало бы прочитать весь файл.
+func (l *BreachedPasswordFile) Reload() error {
+	file, err := os.Open(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to open breached password list: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat breached password list: %w", err)
+	}
+	if _, _, err := readBreachedHash(file, 0, info.Size()); err != nil {
+		file.Close()
+		return fmt.Errorf("breached password list %s: %w", l.path, err)
+	}
+
+	l.mu.Lock()
+	previous := l.file
+	l.file, l.size = file, info.Size()
+	l.mu.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+	return nil
+}
+
+func (l *BreachedPasswordFile) Breached(_ context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	target := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	// RLock держится весь поиск: Reload закроет прежний файл только после него.
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	found, err := searchBreachedFile(l.file, l.size, target)
+	if err != nil {
+		return false, fmt.Errorf("failed to search breached password list: %w", err)
+	}
+	return found, nil
+}
+
+// searchBreachedFile ищет хеш target в отсортированном файле размером size.
+// Инварианты: все строки, начинающиеся раньше lo, меньше target; первая непустая строка,
+// начинающаяся с hi или позже, не меньше target.
+func searchBreachedFile(file io.ReaderAt, size int64, target string) (bool, error) {
+	lo, hi := int64(0), size
+	for hi-lo > breachedScanSize {
+		mid := lo + (hi-lo)/2
+		start, err := nextLineStart(file, mid, size)
+		if err != nil {
+			return false, err
+		}
+		hash, start, err := readBreachedHash(file, start, size)
+		if err != nil {
+			return false, err
+		}
+		if start >= hi {
+			hi = mid
+			continue
+		}
+
+		if hash < target {
+			lo = start + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	start, err := nextLineStart(file, lo, size)
+	if err != nil {
+		return false, err
+	}
+	scanner := bufio.NewScanner(io.NewSectionReader(file, start, size-start))
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		hash, err := parseBreachedHash(scanner.Text())
+		if err != nil {
+			return false, err
+		}
+		if hash >= target {
+			return hash == target, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// nextLineStart возвращает начало первой строки, начинающейся с offset или позже (size, если таких нет).
+func nextLineStart(file io.ReaderAt, offset int64, size int64) (int64, error) {
+	if offset == 0 {
+		return 0, nil
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(file, offset-1, size-offset+1))
+	skipped, err := reader.ReadBytes('\n')
+	if errors.Is(err, io.EOF) {
+		return size, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return offset - 1 + int64(len(skipped)), nil
+}
+
+// readBreachedHash возвращает хеш первой непустой строки, начинающейся с offset или позже, и начало
+// этой строки. offset — начало строки. Если непустых строк дальше нет — пустой хеш и size.
+func readBreachedHash(file io.ReaderAt, offset int64, size int64) (string, int64, error) {
+	for offset < size {
+		line, err := readBreachedLine(file, offset, size)
+		if err != nil {
+			return "", 0, err
+		}
+		if strings.TrimSpace(line) != "" {
+			hash, err := parseBreachedHash(line)
+			return hash, offset, err
+		}
+		if offset, err = nextLineStart(file, offset+1, size); err != nil {
+			return "", 0, err
+		}
+	}
+	return "", size, nil
+}
+
+// readBreachedLine читает строку, начинающуюся с offset, без перевода строки.
+func readBreachedLine(file io.ReaderAt, offset int64, size int64) (string, error) {
+	scanner := bufio.NewScanner(io.NewSectionReader(file, offset, size-offset))
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.ErrUnexpectedEOF
+	}
+	return scanner.Text(), nil
+}
+
+// parseBreachedHash возвращает хеш из строки "SHA1[:COUNT]" в верхнем регистре.
+func parseBreachedHash(line string) (string, error) {
+	hash, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+	hash = strings.ToUpper(hash)
+	if len(hash) != sha1.Size*2 || !isHex(hash) {
+		return "", fmt.Errorf("expected SHA-1 hex, got %q", hash)
+	}
+	return hash, nil
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// breachedListLayout — как записан список: конец строки newline; header и trailer дописываются
+// в начало и конец; blankEvery > 0 вставляет пустую строку после каждой blankEvery-й записи;
+// noFinalNewline убирает перевод строки после последней записи.
+type breachedListLayout struct {
+	newline        string
+	header         string
+	blankEvery     int
+	trailer        string
+	noFinalNewline bool
+}
+
+// writeBreachedList записывает отсортированный список хешей паролей passwords.
+func writeBreachedList(t *testing.T, passwords []string, layout breachedListLayout) string {
+	t.Helper()
+
+	hashes := make([]string, 0, len(passwords))
+	for _, password := range passwords {
+		sum := sha1.Sum([]byte(password))
+		hashes = append(hashes, strings.ToUpper(hex.EncodeToString(sum[:])))
+	}
+	sort.Strings(hashes)
+
+	var b strings.Builder
+	b.WriteString(layout.header)
+	for i, hash := range hashes {
+		fmt.Fprintf(&b, "%s:%d%s", hash, i+1, layout.newline)
+		if layout.blankEvery > 0 && (i+1)%layout.blankEvery == 0 {
+			b.WriteString(layout.newline)
+		}
+	}
+	content := b.String()
+	if layout.noFinalNewline {
+		content = strings.TrimRight(content, "\r\n")
+	}
+	content += layout.trailer
+
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+// sortedByHash возвращает пароли в порядке их хешей: первый и последний — крайние записи файла.
+func sortedByHash(passwords []string) []string {
+	sorted := append([]string(nil), passwords...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sha1.Sum([]byte(sorted[i])), sha1.Sum([]byte(sorted[j]))
+		return hex.EncodeToString(a[:]) < hex.EncodeToString(b[:])
+	})
+	return sorted
+}
+
+func TestBreachedPasswordFileSearch(t *testing.T) {
+	tests := []struct {
+		name   string
+		count  int
+		layout breachedListLayout
+	}{
+		{name: "small file is scanned", count: 20, layout: breachedListLayout{newline: "\n"}},
+		{name: "large file is bisected", count: 5000, layout: breachedListLayout{newline: "\n"}},
+		{name: "crlf", count: 5000, layout: breachedListLayout{newline: "\r\n"}},
+		{name: "crlf small", count: 20, layout: breachedListLayout{newline: "\r\n"}},
+		{name: "blank lines", count: 5000, layout: breachedListLayout{newline: "\n", blankEvery: 7}},
+		{name: "blank line after every entry", count: 5000, layout: breachedListLayout{newline: "\r\n", blankEvery: 1}},
+		{name: "leading and trailing blank lines", count: 5000, layout: breachedListLayout{newline: "\n", header: "\n\n", trailer: "\n\n"}},
+		{name: "no final newline", count: 5000, layout: breachedListLayout{newline: "\r\n", noFinalNewline: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passwords := make([]string, tt.count)
+			for i := range passwords {
+				passwords[i] = fmt.Sprintf("leaked-%d", i)
+			}
+			list, err := NewBreachedPasswordFile(writeBreachedList(t, passwords, tt.layout))
+			if err != nil {
+				t.Fatalf("NewBreachedPasswordFile: %v", err)
+			}
+
+			sorted := sortedByHash(passwords)
+			queries := map[string]bool{
+				sorted[0]:               true,
+				sorted[len(sorted)-1]:   true,
+				sorted[len(sorted)/2]:   true,
+				sorted[len(sorted)/2+1]: true,
+				"not-leaked":            false,
+				"":                      false,
+			}
+			for i := 0; i < 50; i++ {
+				queries[fmt.Sprintf("leaked-%d", tt.count+i)] = false
+			}
+
+			for password, want := range queries {
+				got, err := list.Breached(context.Background(), password)
+				if err != nil {
+					t.Fatalf("Breached(%q): %v", password, err)
+				}
+				if got != want {
+					t.Fatalf("Breached(%q) = %v, want %v", password, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBreachedPasswordFileRejectsMalformedList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	if err := os.WriteFile(path, []byte("\npassword123\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := NewBreachedPasswordFile(path); err == nil {
+		t.Fatal("NewBreachedPasswordFile accepted a list of plain passwords")
+	}
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"goTodo/backend/logger"
+	"goTodo/backend/models"
+	"goTodo/backend/repository"
+)
+
+var (
+	ErrInvalidEmail             = errors.New("invalid email address")
+	ErrEmailTaken               = errors.New("email is already in use")
+	ErrEmailAlreadyVerified     = errors.New("email is already verified for this account")
+	ErrNoPendingEmail           = errors.New("no email is waiting for verification")
+	ErrInvalidVerificationToken = errors.New("invalid or expired email verification token")
+)
+
+// maxEmailLength — предел длины адреса по RFC 5321.
+const maxEmailLength = 254
+
+// emailSendWindow — окно, в котором действуют лимиты писем в час (MaxPerHour и MaxPerIPPerHour).
+const emailSendWindow = time.Hour
+
+// RateLimitError — письмо не отправлено из-за ограничения частоты.
+// RetryAfter — через сколько можно повторить запрос.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("too many emails, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+// EmailVerificationConfig — параметры подтверждения адреса.
+// URL — страница фронта, куда ведет ссылка из письма (токен добавляется параметром token);
+// Cooldown — минимальная пауза между письмами пользователю, MaxPerHour — не больше писем за час.
+type EmailVerificationConfig struct {
+	TokenTTL   time.Duration
+	URL        string
+	Cooldown   time.Duration
+	MaxPerHour int
+}
+
+// EmailVerification привязывает к аккаунту адрес почты. Адрес записывается пользователю
+// только после перехода по ссылке из письма, до этого он хранится в токене подтверждения.
+type EmailVerification interface {
+	// Request отправляет ссылку подтверждения на новый адрес пользователя. Занятый адрес
+	// не выдается ошибкой (ErrEmailTaken возвращает только Confirm).
+	Request(ctx context.Context, userID int64, email string) error
+	// Resend повторно отправляет ссылку на адрес, ожидающий подтверждения.
+	Resend(ctx context.Context, userID int64) error
+	// Confirm подтверждает адрес по токену из письма.
+	Confirm(ctx context.Context, token string, client models.ClientMetadata) error
+}
+
+type emailVerification struct {
+	verifications repository.EmailVerificationRepository
+	users         repository.UserRepository
+	events        SecurityEvents
+	mailer        Mailer
+	cfg           EmailVerificationConfig
+	now           func() time.Time
+}
+
+// NewEmailVerification создает сервис подтверждения адреса почты.
+// Параметры: verifications — токены подтверждения; users — пользователи; events — журнал;
+// mailer — доставка писем; cfg — срок токена, адрес страницы подтверждения и лимиты писем.
+func NewEmailVerification(
+	verifications repository.EmailVerificationRepository,
+	users repository.UserRepository,
+	events SecurityEvents,
+	mailer Mailer,
+	cfg EmailVerificationConfig,
+) EmailVerification {
+	return &emailVerification{
+		verifications: verifications,
+		users:         users,
+		events:        events,
+		mailer:        mailer,
+		cfg:           cfg,
+		now:           time.Now,
+	}
+}
+
+// NormalizeEmail проверяет, что строка — одиночный адрес без имени ("a@b.c", а не "A <a@b.c>"),
+// и приводит его к нижнему регистру: в таком виде адрес хранится и ищется.
+func NormalizeEmail(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || len(raw) > maxEmailLength {
+		return "", ErrInvalidEmail
+	}
+
+	addr, err := mail.ParseAddress(raw)
+	if err != nil || addr.Name != "" || addr.Address != raw {
+		return "", ErrInvalidEmail
+	}
+
+	return strings.ToLower(addr.Address), nil
+}
+
+func (s *emailVerification) Request(ctx context.Context, userID int64, email string) error {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Email != nil && *user.Email == email {
+		return ErrEmailAlreadyVerified
+	}
+
+	return s.send(ctx, user, email)
+}
+
+func (s *emailVerification) Resend(ctx context.Context, userID int64) error {
+	email, err := s.verifications.FindPendingEmail(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNoPendingEmail
+	}
+	if err != nil {
+		return err
+	}
+
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.send(ctx, user, email)
+}
+
+func (s *emailVerification) Confirm(ctx context.Context, token string, client models.ClientMetadata) error {
+	change, err := s.verifications.Consume(ctx, hashOpaqueToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidVerificationToken
+	}
+	if errors.Is(err, repository.ErrEmailTaken) {
+		return ErrEmailTaken
+	}
+	if err != nil {
+		return err
+	}
+
+	// Уведомление о смене уходит на прежний адрес: если адрес сменил не владелец, узнать
+	// об этом должен владелец, а не тот, кто подтвердил новый.
+	details := map[string]string{"email": change.Email}
+	if change.PreviousEmail != "" {
+		details["previous_email"] = change.PreviousEmail
+	}
+	s.events.Record(ctx, models.SecurityEvent{
+		UserID:    change.UserID,
+		Type:      SecurityEventEmailChanged,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		Details:   details,
+	})
+	return nil
+}
+
+// send проверяет лимиты, выпускает токен подтверждения и отправляет письмо со ссылкой.
+// Если адрес уже занят (подтвержден другим пользователем или совпадает с чужим логином),
+// вместо ссылки на него уходит предупреждение, а ответ и лимиты те же, что для свободного:
+// по ответу нельзя узнать, зарегистрирован ли адрес.
+func (s *emailVerification) send(ctx context.Context, user *models.User, email string) error {
+	if err := s.checkRate(ctx, user.ID); err != nil {
+		return err
+	}
+
+	taken, err := s.emailTaken(ctx, user.ID, email)
+	if err != nil {
+		return err
+	}
+
+	token, tokenHash, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	link, err := tokenLink(s.cfg.URL, token)
+	if err != nil {
+		return err
+	}
+	// Токен для занятого адреса тоже сохраняется: по нему считаются лимиты, а подтвердить
+	// его Consume не даст.
+	if err := s.verifications.Create(ctx, user.ID, email, tokenHash, s.now().UTC().Add(s.cfg.TokenTTL)); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, mailTimeout)
+	defer cancel()
+
+	mail := Mail{
+		To:      email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Open the link below to use this address for your account %q.\n"+
+			"It is valid for %s:\n%s\n\n"+
+			"If you did not request this, ignore this message.\n",
+			user.Username, s.cfg.TokenTTL, link),
+	}
+	if taken {
+		mail = Mail{
+			To:      email,
+			Subject: "Your email address",
+			Body: "Someone asked to use this address for another account.\n" +
+				"The address is already in use, so nothing has changed. If you did not request this, ignore this message.\n",
+		}
+	}
+	if err := s.mailer.Send(ctx, mail); err != nil {
+		return fmt.Errorf("failed to send verification mail: %w", err)
+	}
+
+	logger.FromContext(ctx).Info("email verification mail sent", "user_id", user.ID, "email_taken", taken)
+	return nil
+}
+
+// emailTaken сообщает, подтвержден ли адрес другим пользователем или совпадает ли он с чужим логином.
+func (s *emailVerification) emailTaken(ctx context.Context, userID int64, email string) (bool, error) {
+	owner, err := s.users.FindByEmail(ctx, email)
+	switch {
+	case err == nil && owner.ID != userID:
+		return true, nil
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		return false, err
+	}
+
+	owner, err = s.users.FindByUsername(ctx, email)
+	switch {
+	case err == nil:
+		return owner.ID != userID, nil
+	case errors.Is(err, sql.ErrNoRows):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// checkRate возвращает RateLimitError, если пользователю уже недавно отправлялось письмо
+// или за последний час исчерпан MaxPerHour.
+func (s *emailVerification) checkRate(ctx context.Context, userID int64) error {
+	now := s.now()
+	sends, err := s.verifications.SendsSince(ctx, userID, now.Add(-emailSendWindow))
+	if err != nil {
+		return err
+	}
+
+	return checkSendRate(sends, now, s.cfg.Cooldown, s.cfg.MaxPerHour)
+}
+
+// checkSendRate возвращает RateLimitError, если с последнего письма прошло меньше cooldown
+// или за emailSendWindow отправлено maxPerWindow писем. sends — письма за последний emailSendWindow.
+func checkSendRate(sends repository.TokenSends, now time.Time, cooldown time.Duration, maxPerWindow int) error {
+	if sends.Count == 0 {
+		return nil
+	}
+
+	if elapsed := now.Sub(sends.Latest); elapsed < cooldown {
+		return &RateLimitError{RetryAfter: cooldown - elapsed}
+	}
+	if sends.Count >= maxPerWindow {
+		return &RateLimitError{RetryAfter: sends.First.Add(emailSendWindow).Sub(now)}
+	}
+
+	return nil
+}
@@ -0,0 +1,394 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"goTodo/backend/models"
+	"goTodo/backend/repository"
+)
+
+// fakeVerificationRepo — токены подтверждения в памяти; Consume переносит адрес в users.
+type fakeVerificationRepo struct {
+	repository.EmailVerificationRepository
+	fakeMailTokens
+
+	users *fakeAccountRepo
+}
+
+func (r *fakeVerificationRepo) Create(_ context.Context, userID int64, email string, tokenHash string, expiresAt time.Time) error {
+	r.create(fakeMailToken{userID: userID, email: email, hash: tokenHash, expiresAt: expiresAt})
+	return nil
+}
+
+func (r *fakeVerificationRepo) Consume(ctx context.Context, tokenHash string) (repository.EmailChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.active(tokenHash)
+	if !ok {
+		return repository.EmailChange{}, sql.ErrNoRows
+	}
+	if owner, err := r.users.FindByEmail(ctx, token.email); err == nil && owner.ID != token.userID {
+		return repository.EmailChange{}, repository.ErrEmailTaken
+	}
+	for _, other := range r.tokens {
+		if other.userID == token.userID {
+			other.used = true
+		}
+	}
+	if owner, err := r.users.FindByUsername(ctx, token.email); err == nil && owner.ID != token.userID {
+		return repository.EmailChange{}, repository.ErrEmailTaken
+	}
+
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+	user := r.users.users[token.userID]
+	change := repository.EmailChange{UserID: user.ID, Email: token.email}
+	if user.Email != nil {
+		change.PreviousEmail = *user.Email
+	}
+	user.Email = stringPtr(token.email)
+	return change, nil
+}
+
+func (r *fakeVerificationRepo) FindPendingEmail(_ context.Context, userID int64) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.tokens) - 1; i >= 0; i-- {
+		token := r.tokens[i]
+		if token.userID == userID && !token.used && r.now().Before(token.expiresAt) {
+			return token.email, nil
+		}
+	}
+	return "", sql.ErrNoRows
+}
+
+func (r *fakeVerificationRepo) SendsSince(_ context.Context, userID int64, since time.Time) (repository.TokenSends, error) {
+	return r.sends(since, func(token *fakeMailToken) bool { return token.userID == userID }), nil
+}
+
+const testVerificationTTL = 24 * time.Hour
+
+type verificationTestEnv struct {
+	service *emailVerification
+	clock   time.Time
+	users   *fakeAccountRepo
+	mailer  *fakeMailer
+	events  *fakeEvents
+}
+
+// newVerificationTestEnv создает сервис с пользователями alice и bob (с адресами), dave (без адреса)
+// и пользователем, чей логин похож на адрес.
+func newVerificationTestEnv(cfg EmailVerificationConfig) *verificationTestEnv {
+	env := &verificationTestEnv{
+		clock: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		users: newFakeAccountRepo(
+			models.User{ID: 1, Username: "alice", Email: stringPtr("alice@example.com")},
+			models.User{ID: 2, Username: "bob", Email: stringPtr("bob@example.com")},
+			models.User{ID: 4, Username: "dave"},
+			models.User{ID: 5, Username: "erin@example.com"},
+		),
+		mailer: &fakeMailer{},
+		events: &fakeEvents{},
+	}
+	now := func() time.Time { return env.clock }
+
+	cfg.TokenTTL = testVerificationTTL
+	cfg.URL = "https://todo.example.com/verify-email"
+	env.service = NewEmailVerification(
+		&fakeVerificationRepo{fakeMailTokens: fakeMailTokens{now: now}, users: env.users},
+		env.users,
+		env.events,
+		env.mailer,
+		cfg,
+	).(*emailVerification)
+	env.service.now = now
+	return env
+}
+
+// lastMail возвращает последнее письмо и токен из него (пустой, если ссылки нет).
+func (e *verificationTestEnv) lastMail(t *testing.T) (Mail, string) {
+	t.Helper()
+	mails := e.mailer.sent()
+	if len(mails) == 0 {
+		t.Fatal("no mail sent")
+	}
+	mail := mails[len(mails)-1]
+	return mail, mailToken(t, mail)
+}
+
+func TestEmailVerificationRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID int64
+		email  string
+		// pending — адрес, на который ссылка отправлена до проверки; resend — проверяется Resend, а не Request.
+		pending  string
+		resend   bool
+		wantErr  error
+		wantTo   string
+		wantLink bool
+	}{
+		{name: "free address", userID: 4, email: " Dave@Example.com ", wantTo: "dave@example.com", wantLink: true},
+		{name: "name and address", userID: 4, email: "Dave <dave@example.com>", wantErr: ErrInvalidEmail},
+		{name: "not an address", userID: 4, email: "dave", wantErr: ErrInvalidEmail},
+		{name: "already verified", userID: 1, email: "ALICE@example.com", wantErr: ErrEmailAlreadyVerified},
+		// Ответ для занятого адреса тот же, но вместо ссылки на него уходит предупреждение.
+		{name: "verified by another user", userID: 4, email: "bob@example.com", wantTo: "bob@example.com"},
+		{name: "another user's login", userID: 4, email: "erin@example.com", wantTo: "erin@example.com"},
+		{name: "resend without a pending address", userID: 4, resend: true, wantErr: ErrNoPendingEmail},
+		{name: "resend to the pending address", userID: 1, pending: "alice@new.example.com", resend: true, wantTo: "alice@new.example.com", wantLink: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newVerificationTestEnv(EmailVerificationConfig{MaxPerHour: 5})
+			if tt.pending != "" {
+				if err := env.service.Request(context.Background(), tt.userID, tt.pending); err != nil {
+					t.Fatalf("Request(%q): %v", tt.pending, err)
+				}
+				env.clock = env.clock.Add(time.Minute)
+			}
+			sentBefore := len(env.mailer.sent())
+
+			var err error
+			if tt.resend {
+				err = env.service.Resend(context.Background(), tt.userID)
+			} else {
+				err = env.service.Request(context.Background(), tt.userID, tt.email)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				if len(env.mailer.sent()) != sentBefore {
+					t.Fatal("mail sent despite the error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			mail, token := env.lastMail(t)
+			if mail.To != tt.wantTo {
+				t.Fatalf("mail to %q, want %q", mail.To, tt.wantTo)
+			}
+			if (token != "") != tt.wantLink {
+				t.Fatalf("mail has a link = %v, want %v", token != "", tt.wantLink)
+			}
+		})
+	}
+}
+
+func TestEmailVerificationConfirm(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID int64
+		email  string
+		// before — что происходит между письмом и переходом по ссылке.
+		before       func(t *testing.T, env *verificationTestEnv, token string)
+		token        string
+		wantErr      error
+		wantEmail    string
+		wantPrevious string
+	}{
+		{
+			name:      "first address",
+			userID:    4,
+			email:     "dave@example.com",
+			wantEmail: "dave@example.com",
+		},
+		{
+			name:         "address change keeps the previous one in the event",
+			userID:       1,
+			email:        "alice@new.example.com",
+			wantEmail:    "alice@new.example.com",
+			wantPrevious: "alice@example.com",
+		},
+		{
+			name:   "valid just before expiry",
+			userID: 4,
+			email:  "dave@example.com",
+			before: func(_ *testing.T, env *verificationTestEnv, _ string) {
+				env.clock = env.clock.Add(testVerificationTTL - time.Second)
+			},
+			wantEmail: "dave@example.com",
+		},
+		{
+			name:   "token is single-use",
+			userID: 4,
+			email:  "dave@example.com",
+			before: func(t *testing.T, env *verificationTestEnv, token string) {
+				if err := env.service.Confirm(context.Background(), token, models.ClientMetadata{}); err != nil {
+					t.Fatalf("first Confirm: %v", err)
+				}
+			},
+			wantErr:   ErrInvalidVerificationToken,
+			wantEmail: "dave@example.com",
+		},
+		{
+			name:   "expired token",
+			userID: 1,
+			email:  "alice@new.example.com",
+			before: func(_ *testing.T, env *verificationTestEnv, _ string) {
+				env.clock = env.clock.Add(testVerificationTTL)
+			},
+			wantErr:   ErrInvalidVerificationToken,
+			wantEmail: "alice@example.com",
+		},
+		{
+			name:      "unknown token",
+			userID:    1,
+			email:     "alice@new.example.com",
+			token:     "not-a-verification-token",
+			wantErr:   ErrInvalidVerificationToken,
+			wantEmail: "alice@example.com",
+		},
+		{
+			name:   "address verified by another user after the mail",
+			userID: 4,
+			email:  "shared@example.com",
+			before: func(_ *testing.T, env *verificationTestEnv, _ string) {
+				env.users.mu.Lock()
+				env.users.users[2].Email = stringPtr("shared@example.com")
+				env.users.mu.Unlock()
+			},
+			wantErr: ErrEmailTaken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newVerificationTestEnv(EmailVerificationConfig{MaxPerHour: 5})
+			if err := env.service.Request(context.Background(), tt.userID, tt.email); err != nil {
+				t.Fatalf("Request: %v", err)
+			}
+			_, token := env.lastMail(t)
+			if tt.before != nil {
+				tt.before(t, env, token)
+			}
+			if tt.token != "" {
+				token = tt.token
+			}
+			eventsBefore := len(env.events.recorded(SecurityEventEmailChanged))
+
+			err := env.service.Confirm(context.Background(), token, models.ClientMetadata{IPAddress: "203.0.113.10"})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Confirm = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Confirm: %v", err)
+			}
+
+			user := env.users.user(tt.userID)
+			email := ""
+			if user.Email != nil {
+				email = *user.Email
+			}
+			if email != tt.wantEmail {
+				t.Fatalf("user email = %q, want %q", email, tt.wantEmail)
+			}
+
+			events := env.events.recorded(SecurityEventEmailChanged)
+			if tt.wantErr != nil {
+				if len(events) != eventsBefore {
+					t.Fatal("email_changed recorded for a rejected confirmation")
+				}
+				return
+			}
+			if len(events) != 1 {
+				t.Fatalf("email_changed events = %d, want 1", len(events))
+			}
+			details := events[0].Details
+			if events[0].UserID != tt.userID || details["email"] != tt.wantEmail || details["previous_email"] != tt.wantPrevious {
+				t.Fatalf("email_changed = %+v, want user %d, email %q, previous %q", events[0], tt.userID, tt.wantEmail, tt.wantPrevious)
+			}
+		})
+	}
+}
+
+func TestEmailVerificationRateLimits(t *testing.T) {
+	type step struct {
+		after          time.Duration
+		email          string
+		resend         bool
+		wantRetryAfter time.Duration
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "cooldown between mails",
+			steps: []step{
+				{email: "dave@example.com"},
+				{after: 20 * time.Second, email: "dave@example.com", wantRetryAfter: 40 * time.Second},
+				{after: 20 * time.Second, resend: true, wantRetryAfter: 20 * time.Second},
+				{after: 20 * time.Second, resend: true},
+			},
+		},
+		{
+			name: "max per hour",
+			steps: []step{
+				{email: "dave@example.com"},
+				{after: 10 * time.Minute, email: "dave@other.example.com"},
+				{after: 10 * time.Minute, resend: true},
+				{after: 10 * time.Minute, email: "dave@example.com", wantRetryAfter: 30 * time.Minute},
+				{after: 31 * time.Minute, email: "dave@example.com"},
+			},
+		},
+		{
+			// Иначе по разнице в лимитах можно было бы узнать, что адрес занят.
+			name: "mail to a taken address counts too",
+			steps: []step{
+				{email: "bob@example.com"},
+				{after: 10 * time.Second, email: "dave@example.com", wantRetryAfter: 50 * time.Second},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newVerificationTestEnv(EmailVerificationConfig{Cooldown: time.Minute, MaxPerHour: 3})
+
+			for i, s := range tt.steps {
+				env.clock = env.clock.Add(s.after)
+				sentBefore := len(env.mailer.sent())
+
+				var err error
+				if s.resend {
+					err = env.service.Resend(context.Background(), 4)
+				} else {
+					err = env.service.Request(context.Background(), 4, s.email)
+				}
+
+				if s.wantRetryAfter == 0 {
+					if err != nil {
+						t.Fatalf("step %d: %v", i, err)
+					}
+					if len(env.mailer.sent()) != sentBefore+1 {
+						t.Fatalf("step %d: mail not sent", i)
+					}
+					continue
+				}
+				var rateLimited *RateLimitError
+				if !errors.As(err, &rateLimited) {
+					t.Fatalf("step %d: error = %v, want *RateLimitError", i, err)
+				}
+				if rateLimited.RetryAfter != s.wantRetryAfter {
+					t.Fatalf("step %d: RetryAfter = %s, want %s", i, rateLimited.RetryAfter, s.wantRetryAfter)
+				}
+				if len(env.mailer.sent()) != sentBefore {
+					t.Fatalf("step %d: mail sent despite the limit", i)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LegacyKeyID — kid ключа из JWT_SECRET. Этим же ключом проверяются токены
+// без заголовка kid, выпущенные до появления ротации.
+const LegacyKeyID = "default"
+
+// activeKeyFile — файл в каталоге ключей, содержащий kid активного ключа.
+const activeKeyFile = "active"
+
+var (
+	ErrNoSigningKeys    = errors.New("no jwt signing keys configured")
+	ErrUnknownKeyID     = errors.New("unknown jwt key id")
+	ErrActiveKeyMissing = errors.New("active jwt key is not in the keyset")
+)
+
+// KeySet — результат загрузки ключей: все ключи проверки и kid активного ключа подписи.
+type KeySet struct {
+	ActiveID string
+	Keys     []SigningKey
+}
+
+// KeySource загружает набор ключей (из env, каталога и т.д.).
+type KeySource interface {
+	Load() (*KeySet, error)
+}
+
+// SigningKeyProvider отдает ключ для подписи новых токенов и ключи для проверки по kid.
+type SigningKeyProvider interface {
+	ActiveKey() SigningKey
+	VerificationKey(kid string) (SigningKey, bool)
+	VerificationKeys() []SigningKey
+}
+
+// KeyPolicy — дополнительные требования к ключу сверх базовой валидации (например,
+// длина HMAC-секрета в production). Ошибка отклоняет весь загружаемый набор.
+type KeyPolicy func(key SigningKey) error
+
+// KeyRing хранит актуальный набор ключей и перечитывает его через Reload.
+// Ключ, пропавший из источника при перезагрузке, остается валидным для проверки
+// еще retireGrace (срок жизни access-токена), чтобы уже выданные токены дожили до exp.
+type KeyRing struct {
+	source      KeySource
+	retireGrace time.Duration
+	policy      KeyPolicy
+	now         func() time.Time
+
+	mu      sync.RWMutex
+	active  SigningKey
+	keys    map[string]SigningKey
+	retired map[string]retiredKey
+}
+
+type retiredKey struct {
+	key   SigningKey
+	until time.Time
+}
+
+// NewKeyRing создает KeyRing и сразу загружает ключи из source.
+// Параметры: source — источник ключей; retireGrace — сколько принимать выведенные ключи;
+// policy — требования к каждому ключу при каждой загрузке, в том числе по SIGHUP (nil — без них).
+func NewKeyRing(source KeySource, retireGrace time.Duration, policy KeyPolicy) (*KeyRing, error) {
+	ring := &KeyRing{
+		source:      source,
+		retireGrace: retireGrace,
+		policy:      policy,
+		now:         time.Now,
+		keys:        make(map[string]SigningKey),
+		retired:     make(map[string]retiredKey),
+	}
+	if err := ring.Reload(); err != nil {
+		return nil, err
+	}
+
+	return ring, nil
+}
+
+// Reload перечитывает ключи из источника. При ошибке продолжает работать старый набор.
+func (r *KeyRing) Reload() error {
+	set, err := r.source.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load jwt keys: %w", err)
+	}
+
+	keys := make(map[string]SigningKey, len(set.Keys))
+	for _, key := range set.Keys {
+		if err := key.validate(); err != nil {
+			return err
+		}
+		if r.policy != nil {
+			if err := r.policy(key); err != nil {
+				return err
+			}
+		}
+		if _, exists := keys[key.ID]; exists {
+			return fmt.Errorf("duplicate jwt key id %q", key.ID)
+		}
+		keys[key.ID] = key
+	}
+	if len(keys) == 0 {
+		return ErrNoSigningKeys
+	}
+
+	active, ok := keys[set.ActiveID]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrActiveKeyMissing, set.ActiveID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	for id, key := range r.keys {
+		if _, stillPresent := keys[id]; !stillPresent {
+			r.retired[id] = retiredKey{key: key, until: now.Add(r.retireGrace)}
+		}
+	}
+	for id, retired := range r.retired {
+		if _, restored := keys[id]; restored || !now.Before(retired.until) {
+			delete(r.retired, id)
+		}
+	}
+
+	r.keys = keys
+	r.active = active
+
+	slog.Info("jwt keys loaded", "active_kid", active.ID, "active_alg", active.Algorithm, "kids", sortedKeyIDs(keys), "retired_kids", len(r.retired))
+	return nil
+}
+
+// ActiveKey возвращает ключ, которым подписываются новые токены.
+func (r *KeyRing) ActiveKey() SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.active
+}
+
+// VerificationKey возвращает ключ проверки по kid. Пустой kid соответствует LegacyKeyID.
+func (r *KeyRing) VerificationKey(kid string) (SigningKey, bool) {
+	if kid == "" {
+		kid = LegacyKeyID
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if key, ok := r.keys[kid]; ok {
+		return key, true
+	}
+	if retired, ok := r.retired[kid]; ok && r.now().Before(retired.until) {
+		return retired.key, true
+	}
+
+	return SigningKey{}, false
+}
+
+// VerificationKeys возвращает все ключи, которыми сейчас принимаются токены
+// (текущие и еще не истекшие выведенные), отсортированные по kid.
+func (r *KeyRing) VerificationKeys() []SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := r.now()
+	keys := make([]SigningKey, 0, len(r.keys)+len(r.retired))
+	for _, key := range r.keys {
+		keys = append(keys, key)
+	}
+	for _, retired := range r.retired {
+		if now.Before(retired.until) {
+			keys = append(keys, retired.key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+	return keys
+}
+
+// staticKeySource — неизменяемый набор ключей (JWT_SECRET или список JWT_KEYS).
+type staticKeySource struct {
+	set KeySet
+}
+
+// NewStaticKeySource создает источник с фиксированным набором ключей.
+// Пустой activeID означает первый ключ списка.
+func NewStaticKeySource(keys []SigningKey, activeID string) KeySource {
+	if activeID == "" && len(keys) > 0 {
+		activeID = keys[0].ID
+	}
+
+	return &staticKeySource{set: KeySet{ActiveID: activeID, Keys: keys}}
+}
+
+func (s *staticKeySource) Load() (*KeySet, error) {
+	set := s.set
+	return &set, nil
+}
+
+// ParseKeyList разбирает список вида "kid1:secret1,kid2:secret2".
+func ParseKeyList(value string) ([]SigningKey, error) {
+	var keys []SigningKey
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kid, secret, ok := strings.Cut(entry, ":")
+		if !ok || strings.TrimSpace(kid) == "" || secret == "" {
+			return nil, fmt.Errorf("invalid jwt key entry %q: expected kid:secret", redactKeyEntry(entry))
+		}
+		keys = append(keys, NewHMACKey(strings.TrimSpace(kid), []byte(secret)))
+	}
+
+	return keys, nil
+}
+
+// redactKeyEntry скрывает секрет в сообщении об ошибке.
+func redactKeyEntry(entry string) string {
+	kid, _, _ := strings.Cut(entry, ":")
+	return kid + ":***"
+}
+
+// dirKeySource читает ключи из каталога: файл <kid>.key содержит HMAC-секрет,
+// <kid>.pem — приватный ключ RSA или Ed25519 в PEM, файл active — kid активного ключа.
+// Каталог перечитывается при каждом Load, поэтому ротация сводится к добавлению
+// файла, правке active и SIGHUP.
+type dirKeySource struct {
+	dir string
+}
+
+// NewDirKeySource создает источник ключей из каталога dir.
+func NewDirKeySource(dir string) KeySource {
+	return &dirKeySource{dir: dir}
+}
+
+func (s *dirKeySource) Load() (*KeySet, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jwt keys in %s: %w", s.dir, err)
+	}
+
+	set := &KeySet{}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".key" && ext != ".pem") {
+			continue
+		}
+
+		path := filepath.Join(s.dir, entry.Name())
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read jwt key %s: %w", path, err)
+		}
+
+		kid := strings.TrimSuffix(entry.Name(), ext)
+		if ext == ".key" {
+			set.Keys = append(set.Keys, NewHMACKey(kid, []byte(strings.TrimSpace(string(content)))))
+			continue
+		}
+
+		key, err := ParsePrivateKeyPEM(kid, content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		set.Keys = append(set.Keys, key)
+	}
+
+	active, err := os.ReadFile(filepath.Join(s.dir, activeKeyFile))
+	switch {
+	case err == nil:
+		set.ActiveID = strings.TrimSpace(string(active))
+	case errors.Is(err, os.ErrNotExist) && len(set.Keys) == 1:
+		set.ActiveID = set.Keys[0].ID
+	case errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("%s: file %q with active kid is required when there are several keys", s.dir, activeKeyFile)
+	default:
+		return nil, fmt.Errorf("failed to read active jwt key id: %w", err)
+	}
+
+	return set, nil
+}
+
+func sortedKeyIDs(keys map[string]SigningKey) []string {
+	ids := make([]string, 0, len(keys))
+	for id := range keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
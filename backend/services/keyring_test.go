@@ -0,0 +1,75 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"goTodo/backend/config"
+)
+
+// prodTestKeyPolicy — требования production к HMAC-ключам, как в main.newKeyPolicy.
+func prodTestKeyPolicy(key SigningKey) error {
+	if key.Algorithm != AlgHS256 {
+		return nil
+	}
+	return config.CheckProdSigningSecret(key.ID, key.Secret)
+}
+
+func writeKeyFile(t *testing.T, dir string, name string, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+}
+
+func TestKeyRingPolicyRejectsWeakDirectoryKeys(t *testing.T) {
+	const strongSecret = "kQ8vZ3xR1mT6yB0nW4pL7sD2fH9gJ5cA"
+
+	tests := []struct {
+		name    string
+		secret  string
+		wantErr bool
+	}{
+		{name: "strong secret", secret: strongSecret},
+		{name: "short secret", secret: "short-secret", wantErr: true},
+		{name: "long but repetitive secret", secret: "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeKeyFile(t, dir, "k1.key", tt.secret)
+
+			_, err := NewKeyRing(NewDirKeySource(dir), time.Minute, prodTestKeyPolicy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewKeyRing error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyRingReloadWithWeakKeyKeepsPreviousSet(t *testing.T) {
+	dir := t.TempDir()
+	writeKeyFile(t, dir, "k1.key", "kQ8vZ3xR1mT6yB0nW4pL7sD2fH9gJ5cA")
+
+	ring, err := NewKeyRing(NewDirKeySource(dir), time.Minute, prodTestKeyPolicy)
+	if err != nil {
+		t.Fatalf("NewKeyRing: %v", err)
+	}
+
+	// Ротация на слабый ключ, как будто по SIGHUP.
+	writeKeyFile(t, dir, "k2.key", "weak")
+	writeKeyFile(t, dir, activeKeyFile, "k2")
+	if err := ring.Reload(); err == nil {
+		t.Fatal("Reload accepted a weak HMAC key")
+	}
+
+	if got := ring.ActiveKey().ID; got != "k1" {
+		t.Fatalf("active key = %q, want the previous k1", got)
+	}
+	if _, ok := ring.VerificationKey("k2"); ok {
+		t.Fatal("weak key k2 is accepted for verification")
+	}
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"goTodo/backend/logger"
+	"goTodo/backend/models"
+)
+
+// LoginAttemptStore хранит счетчики попыток входа. In-memory реализация подходит
+// для одного инстанса; для кластера — repository.LoginAttemptRepository (Postgres).
+type LoginAttemptStore interface {
+	// Reserve одним атомарным шагом засчитывает попытку по ключу, если он не заблокирован
+	// на момент now, и блокирует его по lockout, если попыток набралось достаточно.
+	// Если прошлая попытка была раньше resetBefore, счет начинается заново. Для заблокированного
+	// ключа попытка не засчитывается: reserved == false, в attempts — действующая блокировка.
+	Reserve(ctx context.Context, key string, now time.Time, resetBefore time.Time, lockout models.LoginLockout) (attempts models.LoginAttempts, reserved bool, err error)
+	// Release возвращает попытку, засчитанную Reserve. Блокировка снимается, только если ее
+	// поставила эта попытка (lockedUntil — LockedUntil из Reserve) и никто ее с тех пор не продлил.
+	Release(ctx context.Context, key string, lockedUntil time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+// LoginThrottlePolicy — когда и насколько блокировать вход.
+// После AccountThreshold неудач подряд по аккаунту (IPThreshold — с одного IP; 0 — не считать)
+// каждая следующая неудача блокирует вход на BaseDelay, 2×BaseDelay, 4×BaseDelay…, но не дольше
+// MaxLockout. Счетчик обнуляется, если неудач не было дольше Window, и после успешного входа.
+type LoginThrottlePolicy struct {
+	AccountThreshold int
+	IPThreshold      int
+	BaseDelay        time.Duration
+	MaxLockout       time.Duration
+	Window           time.Duration
+}
+
+// LoginThrottle защищает вход от перебора паролей.
+//
+// Попытка засчитывается как неудачная до проверки пароля (Reserve), а успешная потом
+// возвращается (RecordSuccess). Так параллельные запросы не проходят мимо порога:
+// каждый видит попытки, начатые до него, даже если bcrypt по ним еще не закончился.
+type LoginThrottle interface {
+	// Reserve засчитывает попытку входа по аккаунту и IP. Если вход заблокирован,
+	// попытка не засчитывается, а RetryAfter в ответе — сколько ждать.
+	Reserve(ctx context.Context, account string, ip string) (LoginReservation, error)
+	// RecordSuccess обнуляет счетчик аккаунта и возвращает попытку, засчитанную по IP.
+	// Счетчик IP не сбрасывается: иначе перебор с одного адреса можно было бы перемежать
+	// входами в свой аккаунт.
+	RecordSuccess(ctx context.Context, reservation LoginReservation) error
+}
+
+// LoginReservation — попытка входа, засчитанная LoginThrottle.Reserve.
+// RetryAfter > 0 — вход заблокирован, попытка не засчитана.
+type LoginReservation struct {
+	RetryAfter time.Duration
+
+	keys []reservedKey
+}
+
+// reservedKey — ключ, по которому засчитана попытка; lockedUntil — блокировка после резерва
+// (снимается вместе с попыткой, если ее поставил этот резерв).
+type reservedKey struct {
+	name        string
+	scope       string
+	lockedUntil time.Time
+}
+
+// LoginAccountKey возвращает ключ аккаунта для LoginThrottle: id для существующего
+// пользователя (вход по логину и по адресу почты считается вместе) или введенный логин.
+// Неизвестные логины блокируются так же, как существующие, чтобы по 429 нельзя
+// было проверить, зарегистрирован ли пользователь.
+func LoginAccountKey(userID int64, login string) string {
+	if userID > 0 {
+		return "user:" + strconv.FormatInt(userID, 10)
+	}
+	return "login:" + strings.ToLower(strings.TrimSpace(login))
+}
+
+type loginThrottle struct {
+	store  LoginAttemptStore
+	policy LoginThrottlePolicy
+	now    func() time.Time
+}
+
+// NewLoginThrottle создает LoginThrottle.
+// Параметры: store — хранилище счетчиков; policy — пороги и сроки блокировки.
+func NewLoginThrottle(store LoginAttemptStore, policy LoginThrottlePolicy) LoginThrottle {
+	return &loginThrottle{store: store, policy: policy, now: time.Now}
+}
+
+func (t *loginThrottle) Reserve(ctx context.Context, account string, ip string) (LoginReservation, error) {
+	// Postgres хранит время с точностью до микросекунды: Release сравнивает блокировку
+	// с тем, что вернул Reserve, и в памяти время должно быть тем же.
+	now := t.now().Truncate(time.Microsecond)
+	var reservation LoginReservation
+
+	for _, key := range t.keys(account, ip) {
+		attempts, reserved, err := t.store.Reserve(ctx, key.name, now, now.Add(-t.policy.Window), t.lockoutPolicy(key.threshold))
+		if err != nil {
+			t.release(ctx, reservation)
+			return LoginReservation{}, err
+		}
+		if !reserved {
+			// Ключ заблокирован: уже засчитанное по другим ключам возвращается, иначе
+			// запросы, отбитые блокировкой IP, копили бы неудачи аккаунта и наоборот.
+			t.release(ctx, reservation)
+			// Блокировка могла истечь между резервом и чтением — все равно просим подождать.
+			return LoginReservation{RetryAfter: max(attempts.LockedUntil.Sub(now), time.Second)}, nil
+		}
+
+		reservation.keys = append(reservation.keys, reservedKey{name: key.name, scope: key.scope, lockedUntil: attempts.LockedUntil})
+		if attempts.Failures >= key.threshold {
+			// Сам ключ не логируется: в "login:" попадает то, что ввели в поле логина (иногда пароль).
+			logger.FromContext(ctx).Warn("login temporarily locked",
+				"scope", key.scope,
+				"failures", attempts.Failures,
+				"lockout", attempts.LockedUntil.Sub(now),
+			)
+		}
+	}
+
+	return reservation, nil
+}
+
+func (t *loginThrottle) RecordSuccess(ctx context.Context, reservation LoginReservation) error {
+	for _, key := range reservation.keys {
+		var err error
+		if key.scope == throttleScopeAccount {
+			err = t.store.Reset(ctx, key.name)
+		} else {
+			err = t.store.Release(ctx, key.name, key.lockedUntil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// release возвращает попытки, засчитанные по части ключей. Ошибки только логируются:
+// вход и так отклоняется, а лишняя неудача в счетчике безопаснее, чем пропущенная.
+func (t *loginThrottle) release(ctx context.Context, reservation LoginReservation) {
+	for _, key := range reservation.keys {
+		if err := t.store.Release(ctx, key.name, key.lockedUntil); err != nil {
+			logger.FromContext(ctx).Error("failed to release login attempt", "scope", key.scope, "error", err)
+		}
+	}
+}
+
+const (
+	throttleScopeAccount = "account"
+	throttleScopeIP      = "ip"
+)
+
+type throttleKey struct {
+	name      string
+	scope     string
+	threshold int
+}
+
+// keys возвращает ключи, по которым считаются попытки: аккаунт и IP (если порог задан).
+func (t *loginThrottle) keys(account string, ip string) []throttleKey {
+	keys := make([]throttleKey, 0, 2)
+	if t.policy.AccountThreshold > 0 {
+		keys = append(keys, throttleKey{name: account, scope: throttleScopeAccount, threshold: t.policy.AccountThreshold})
+	}
+	if t.policy.IPThreshold > 0 && ip != "" {
+		keys = append(keys, throttleKey{name: "ip:" + ip, scope: throttleScopeIP, threshold: t.policy.IPThreshold})
+	}
+	return keys
+}
+
+func (t *loginThrottle) lockoutPolicy(threshold int) models.LoginLockout {
+	return models.LoginLockout{
+		Threshold:  threshold,
+		BaseDelay:  t.policy.BaseDelay,
+		MaxLockout: t.policy.MaxLockout,
+	}
+}
+
+// loginLockoutDuration возвращает BaseDelay × 2^(failures − Threshold), но не больше MaxLockout.
+func loginLockoutDuration(lockout models.LoginLockout, failures int) time.Duration {
+	duration := lockout.BaseDelay
+	for i := lockout.Threshold; i < failures && duration < lockout.MaxLockout; i++ {
+		duration *= 2
+	}
+	return min(duration, lockout.MaxLockout)
+}
+
+type nopLoginThrottle struct{}
+
+// NewNopLoginThrottle создает LoginThrottle, который ничего не ограничивает.
+func NewNopLoginThrottle() LoginThrottle {
+	return nopLoginThrottle{}
+}
+
+func (nopLoginThrottle) Reserve(context.Context, string, string) (LoginReservation, error) {
+	return LoginReservation{}, nil
+}
+
+func (nopLoginThrottle) RecordSuccess(context.Context, LoginReservation) error {
+	return nil
+}
+
+// loginAttemptSweepSize — при таком числе ключей in-memory хранилище вычищает устаревшие.
+const loginAttemptSweepSize = 10_000
+
+// memoryLoginAttemptStore — LoginAttemptStore в памяти процесса. На нескольких
+// инстансах счетчики у каждого свои, поэтому для кластера нужен Postgres.
+type memoryLoginAttemptStore struct {
+	retention time.Duration
+
+	mu       sync.Mutex
+	attempts map[string]models.LoginAttempts
+}
+
+// NewMemoryLoginAttemptStore создает in-memory хранилище.
+// Параметры: retention — сколько хранить ключ после последней неудачи и конца блокировки.
+func NewMemoryLoginAttemptStore(retention time.Duration) LoginAttemptStore {
+	return &memoryLoginAttemptStore{
+		retention: retention,
+		attempts:  make(map[string]models.LoginAttempts),
+	}
+}
+
+func (s *memoryLoginAttemptStore) Reserve(_ context.Context, key string, now time.Time, resetBefore time.Time, lockout models.LoginLockout) (models.LoginAttempts, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.attempts) > loginAttemptSweepSize {
+		s.sweep(now)
+	}
+
+	attempts := s.attempts[key]
+	if attempts.LockedUntil.After(now) {
+		return attempts, false, nil
+	}
+	if attempts.LastFailureAt.Before(resetBefore) {
+		attempts = models.LoginAttempts{}
+	}
+	attempts.Failures++
+	attempts.LastFailureAt = now
+	attempts.LockedUntil = time.Time{}
+	if attempts.Failures >= lockout.Threshold {
+		attempts.LockedUntil = now.Add(loginLockoutDuration(lockout, attempts.Failures))
+	}
+	s.attempts[key] = attempts
+
+	return attempts, true, nil
+}
+
+func (s *memoryLoginAttemptStore) Release(_ context.Context, key string, lockedUntil time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts, ok := s.attempts[key]
+	if !ok {
+		return nil
+	}
+	attempts.Failures = max(attempts.Failures-1, 0)
+	if !lockedUntil.IsZero() && attempts.LockedUntil.Equal(lockedUntil) {
+		attempts.LockedUntil = time.Time{}
+	}
+	s.attempts[key] = attempts
+	return nil
+}
+
+func (s *memoryLoginAttemptStore) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
+
+// sweep удаляет ключи без неудач и блокировок за retention. Вызывается под s.mu.
+func (s *memoryLoginAttemptStore) sweep(now time.Time) {
+	cutoff := now.Add(-s.retention)
+	for key, attempts := range s.attempts {
+		if attempts.LastFailureAt.Before(cutoff) && attempts.LockedUntil.Before(cutoff) {
+			delete(s.attempts, key)
+		}
+	}
+}
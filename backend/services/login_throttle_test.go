@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestLoginThrottle создает LoginThrottle на in-memory хранилище с часами clock.
+func newTestLoginThrottle(policy LoginThrottlePolicy, clock *time.Time) *loginThrottle {
+	throttle := NewLoginThrottle(NewMemoryLoginAttemptStore(time.Hour), policy).(*loginThrottle)
+	throttle.now = func() time.Time { return *clock }
+	return throttle
+}
+
+var testThrottlePolicy = LoginThrottlePolicy{
+	AccountThreshold: 5,
+	IPThreshold:      20,
+	BaseDelay:        time.Second,
+	MaxLockout:       time.Minute,
+	Window:           15 * time.Minute,
+}
+
+func TestLoginThrottleConcurrentBurstStopsAtThreshold(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  LoginThrottlePolicy
+		account func(i int) string
+		ip      func(i int) string
+		granted int
+	}{
+		{
+			name:    "one account from many addresses",
+			policy:  testThrottlePolicy,
+			account: func(int) string { return LoginAccountKey(1, "alice") },
+			ip:      func(i int) string { return "198.51.100." + strconv.Itoa(i) },
+			granted: testThrottlePolicy.AccountThreshold,
+		},
+		{
+			name:    "many accounts from one address",
+			policy:  testThrottlePolicy,
+			account: func(i int) string { return LoginAccountKey(int64(i+1), "") },
+			ip:      func(int) string { return "198.51.100.7" },
+			granted: testThrottlePolicy.IPThreshold,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+			throttle := newTestLoginThrottle(tt.policy, &clock)
+
+			const attempts = 50
+			var (
+				start   = make(chan struct{})
+				wg      sync.WaitGroup
+				mu      sync.Mutex
+				granted int
+			)
+			for i := 0; i < attempts; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					<-start
+					reservation, err := throttle.Reserve(context.Background(), tt.account(i), tt.ip(i))
+					if err != nil {
+						t.Errorf("Reserve: %v", err)
+						return
+					}
+					if reservation.RetryAfter == 0 {
+						mu.Lock()
+						granted++
+						mu.Unlock()
+					}
+				}(i)
+			}
+			// Все попытки стартуют одновременно и ни одна не успевает «проверить пароль»
+			// до чужого резерва — ровно то окно, через которое раньше проходила пачка.
+			close(start)
+			wg.Wait()
+
+			if granted != tt.granted {
+				t.Fatalf("granted %d of %d concurrent attempts, want %d", granted, attempts, tt.granted)
+			}
+		})
+	}
+}
+
+func TestLoginThrottleLockoutGrowsAndResets(t *testing.T) {
+	clock := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttle := newTestLoginThrottle(testThrottlePolicy, &clock)
+	ctx := context.Background()
+	account := LoginAccountKey(1, "alice")
+
+	// Неудачная попытка ничего не пишет после Reserve: она уже засчитана.
+	steps := []struct {
+		name      string
+		advance   time.Duration
+		wantRetry time.Duration
+	}{
+		{name: "attempt 1", wantRetry: 0},
+		{name: "attempt 2", wantRetry: 0},
+		{name: "attempt 3", wantRetry: 0},
+		{name: "attempt 4", wantRetry: 0},
+		{name: "attempt 5 reaches the threshold", wantRetry: 0},
+		{name: "locked for base delay", wantRetry: time.Second},
+		{name: "attempt 6 after the lock", advance: time.Second, wantRetry: 0},
+		{name: "locked twice as long", wantRetry: 2 * time.Second},
+		{name: "still locked", advance: time.Second, wantRetry: time.Second},
+		{name: "attempt 7", advance: time.Second, wantRetry: 0},
+		{name: "locked four times as long", wantRetry: 4 * time.Second},
+		{name: "window expired", advance: 16 * time.Minute, wantRetry: 0},
+		{name: "counting starts over", wantRetry: 0},
+	}
+
+	for _, step := range steps {
+		clock = clock.Add(step.advance)
+		reservation, err := throttle.Reserve(ctx, account, "")
+		if err != nil {
+			t.Fatalf("%s: Reserve: %v", step.name, err)
+		}
+		if reservation.RetryAfter != step.wantRetry {
+			t.Fatalf("%s: RetryAfter = %s, want %s", step.name, reservation.RetryAfter, step.wantRetry)
+		}
+	}
+}
+
+func TestLoginThrottleSuccessReleasesReservation(t *testing.T) {
+	policy := LoginThrottlePolicy{AccountThreshold: 3, IPThreshold: 3, BaseDelay: time.Second, MaxLockout: time.Minute, Window: time.Minute}
+	clock := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttle := newTestLoginThrottle(policy, &clock)
+	ctx := context.Background()
+	const ip = "203.0.113.5"
+
+	reserve := func(account string) LoginReservation {
+		t.Helper()
+		reservation, err := throttle.Reserve(ctx, account, ip)
+		if err != nil {
+			t.Fatalf("Reserve(%s): %v", account, err)
+		}
+		return reservation
+	}
+
+	// Две неудачи с адреса, третья попытка успешна: ее резерв заблокировал IP,
+	// но успех возвращает попытку и снимает блокировку.
+	reserve(LoginAccountKey(0, "mallory-1"))
+	reserve(LoginAccountKey(0, "mallory-2"))
+	success := reserve(LoginAccountKey(1, "alice"))
+	if success.RetryAfter != 0 {
+		t.Fatalf("third attempt RetryAfter = %s, want 0", success.RetryAfter)
+	}
+	if blocked := reserve(LoginAccountKey(2, "bob")); blocked.RetryAfter == 0 {
+		t.Fatal("IP is not locked while the third attempt is in flight")
+	}
+	if err := throttle.RecordSuccess(ctx, success); err != nil {
+		t.Fatalf("RecordSuccess: %v", err)
+	}
+
+	store := throttle.store.(*memoryLoginAttemptStore)
+	if got := store.attempts["ip:"+ip]; got.Failures != 2 || !got.LockedUntil.IsZero() {
+		t.Fatalf("ip attempts after success = %+v, want 2 failures and no lock", got)
+	}
+	if _, ok := store.attempts[LoginAccountKey(1, "alice")]; ok {
+		t.Fatal("alice counter survived a successful login")
+	}
+	// Попытка bob, отбитая блокировкой IP, не засчитана и его аккаунту.
+	if got := store.attempts[LoginAccountKey(2, "bob")]; got.Failures != 0 {
+		t.Fatalf("bob failures after a rejected attempt = %d, want 0", got.Failures)
+	}
+	if r := reserve(LoginAccountKey(2, "bob")); r.RetryAfter != 0 {
+		t.Fatalf("attempt after release: RetryAfter = %s, want 0", r.RetryAfter)
+	}
+}
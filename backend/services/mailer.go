@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidMail = errors.New("mail must have recipient and subject")
+
+// Mail — простое текстовое письмо.
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer отправляет письма пользователям (сброс пароля, подтверждение адреса).
+// Реализация выбирается в main: SMTP для окружений с почтой, консоль/файл для разработки.
+type Mailer interface {
+	Send(ctx context.Context, mail Mail) error
+}
+
+// SMTPConfig — параметры SMTP-сервера. Username пустой — без аутентификации.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+type smtpMailer struct {
+	cfg SMTPConfig
+}
+
+// NewSMTPMailer создает Mailer, отправляющий письма через SMTP.
+// STARTTLS включается автоматически, если сервер его поддерживает;
+// PLAIN-аутентификация без TLS разрешена только для localhost.
+func NewSMTPMailer(cfg SMTPConfig) Mailer {
+	return &smtpMailer{cfg: cfg}
+}
+
+func (m *smtpMailer) Send(ctx context.Context, mail Mail) error {
+	if err := mail.validate(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	message := formatMail(m.cfg.From, mail, time.Now())
+
+	// net/smtp не принимает context, поэтому отправка идет в горутине, а ctx
+	// ограничивает ожидание результата.
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, m.cfg.From, []string{mail.To}, message)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("failed to send mail via %s: %w", addr, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to send mail via %s: %w", addr, ctx.Err())
+	}
+}
+
+// writerMailer пишет письма в поток: stdout для консоли или файл для разработки.
+type writerMailer struct {
+	from string
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterMailer создает Mailer, который не отправляет письма, а печатает их в w
+// (os.Stdout или открытый файл). Ссылки из писем можно взять прямо из вывода.
+func NewWriterMailer(w io.Writer, from string) Mailer {
+	return &writerMailer{w: w, from: from}
+}
+
+func (m *writerMailer) Send(_ context.Context, mail Mail) error {
+	if err := mail.validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := fmt.Fprintf(m.w, "----- mail -----\n%s\n----- end mail -----\n", formatMail(m.from, mail, time.Now())); err != nil {
+		return fmt.Errorf("failed to write mail: %w", err)
+	}
+	return nil
+}
+
+func (m Mail) validate() error {
+	if strings.TrimSpace(m.To) == "" || strings.TrimSpace(m.Subject) == "" {
+		return ErrInvalidMail
+	}
+	// Перевод строки в заголовке позволил бы дописать произвольные заголовки письма.
+	if strings.ContainsAny(m.To+m.Subject, "\r\n") {
+		return fmt.Errorf("%w: header contains line break", ErrInvalidMail)
+	}
+	return nil
+}
+
+// formatMail собирает RFC 5322 письмо с текстовым телом в UTF-8.
+func formatMail(from string, mail Mail, now time.Time) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + mail.To + "\r\n")
+	b.WriteString("Subject: " + mail.Subject + "\r\n")
+	b.WriteString("Date: " + now.Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"goTodo/backend/logger"
+	"goTodo/backend/models"
+	"goTodo/backend/repository"
+)
+
+// Notifier доставляет пользователю уведомление о событии безопасности
+// (email, push, мессенджер). Реализация выбирается в main.
+type Notifier interface {
+	Notify(ctx context.Context, event models.SecurityEvent) error
+}
+
+type logNotifier struct{}
+
+// NewLogNotifier создает Notifier, который только пишет уведомление в лог.
+// Подходит для разработки и как заглушка, пока нет реального канала доставки.
+func NewLogNotifier() Notifier {
+	return logNotifier{}
+}
+
+func (logNotifier) Notify(ctx context.Context, event models.SecurityEvent) error {
+	logger.FromContext(ctx).Warn("security notification",
+		"user_id", event.UserID,
+		"type", event.Type,
+		"ip", event.IPAddress,
+		"event_id", event.ID,
+	)
+	return nil
+}
+
+type nopNotifier struct{}
+
+// NewNopNotifier создает Notifier, который ничего не делает.
+func NewNopNotifier() Notifier {
+	return nopNotifier{}
+}
+
+func (nopNotifier) Notify(context.Context, models.SecurityEvent) error {
+	return nil
+}
+
+// securityNotices — текст письма для типов событий, о которых уведомляется пользователь.
+var securityNotices = map[string]string{
+	SecurityEventRefreshReuse:          "An already used sign-in token was presented again. That sign-in has been ended on all devices.",
+	SecurityEventRepeatedLoginFailures: "There were several failed attempts to sign in to your account.",
+	SecurityEventPasswordChanged:       "Your password was changed. All other sessions have been ended.",
+	SecurityEventPasswordReset:         "Your password was reset using an emailed link. All sessions have been ended.",
+	SecurityEventEmailChanged:          "The email address of your account was changed. Security notices now go to the new address.",
+}
+
+type emailNotifier struct {
+	users    repository.UserRepository
+	mailer   Mailer
+	fallback Notifier
+}
+
+// NewEmailNotifier создает Notifier, который пишет пользователю на подтвержденный адрес.
+// О смене адреса пишет на прежний адрес (из Details["previous_email"]): новый подтвердил
+// тот, кто его сменил. Пользователи без адреса уведомляются через fallback (обычно log).
+func NewEmailNotifier(users repository.UserRepository, mailer Mailer, fallback Notifier) Notifier {
+	return &emailNotifier{users: users, mailer: mailer, fallback: fallback}
+}
+
+func (n *emailNotifier) Notify(ctx context.Context, event models.SecurityEvent) error {
+	user, err := n.users.FindByID(ctx, event.UserID)
+	if err != nil {
+		return err
+	}
+
+	var recipient string
+	switch {
+	case event.Type == SecurityEventEmailChanged:
+		recipient = event.Details["previous_email"]
+	case user.Email != nil:
+		recipient = *user.Email
+	}
+	if recipient == "" {
+		return n.fallback.Notify(ctx, event)
+	}
+
+	notice, ok := securityNotices[event.Type]
+	if !ok {
+		notice = fmt.Sprintf("Security event: %s.", event.Type)
+	}
+
+	return n.mailer.Send(ctx, Mail{
+		To:      recipient,
+		Subject: "Security alert for your account",
+		Body: fmt.Sprintf("%s\n\nAccount: %s\nIP address: %s\nDevice: %s\n\n"+
+			"If it was not you, change your password right away.\n",
+			notice, user.Username, event.IPAddress, event.UserAgent),
+	})
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"goTodo/backend/models"
+)
+
+// Правила парольной политики (PasswordViolation.Rule).
+const (
+	PasswordRuleMinLength   = "min_length"
+	PasswordRuleMaxLength   = "max_length"
+	PasswordRuleNotUsername = "not_username"
+	PasswordRuleBreached    = "breached"
+)
+
+// bcryptMaxPasswordBytes — bcrypt учитывает только первые 72 байта пароля.
+const bcryptMaxPasswordBytes = 72
+
+// PasswordPolicyError — пароль нарушает одно или несколько правил политики.
+type PasswordPolicyError struct {
+	Violations []models.PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	rules := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		rules = append(rules, violation.Rule)
+	}
+	return "password violates policy: " + strings.Join(rules, ", ")
+}
+
+// PasswordPolicyConfig — требования к паролю. MinLength считается в символах,
+// MaxBytes — в байтах UTF-8 (не больше 72 байт, которые учитывает bcrypt).
+type PasswordPolicyConfig struct {
+	MinLength int
+	MaxBytes  int
+}
+
+// PasswordPolicy проверяет новый пароль при регистрации, смене и сбросе.
+type PasswordPolicy interface {
+	// Validate возвращает *PasswordPolicyError со всеми нарушенными правилами
+	// или другую ошибку, если проверку не удалось выполнить.
+	Validate(ctx context.Context, password string, username string) error
+}
+
+type passwordPolicy struct {
+	cfg      PasswordPolicyConfig
+	breached BreachedPasswords
+}
+
+// NewPasswordPolicy создает парольную политику.
+// Параметры: cfg — ограничения длины (MaxBytes вне 1..72 заменяется на 72);
+// breached — список утечек (nil — без проверки).
+func NewPasswordPolicy(cfg PasswordPolicyConfig, breached BreachedPasswords) PasswordPolicy {
+	if cfg.MaxBytes <= 0 || cfg.MaxBytes > bcryptMaxPasswordBytes {
+		cfg.MaxBytes = bcryptMaxPasswordBytes
+	}
+	return &passwordPolicy{cfg: cfg, breached: breached}
+}
+
+func (p *passwordPolicy) Validate(ctx context.Context, password string, username string) error {
+	var violations []models.PasswordViolation
+
+	if utf8.RuneCountInString(password) < p.cfg.MinLength {
+		violations = append(violations, models.PasswordViolation{
+			Rule:    PasswordRuleMinLength,
+			Message: fmt.Sprintf("Password must be at least %d characters long", p.cfg.MinLength),
+			Limit:   p.cfg.MinLength,
+		})
+	}
+	if len(password) > p.cfg.MaxBytes {
+		violations = append(violations, models.PasswordViolation{
+			Rule:    PasswordRuleMaxLength,
+			Message: fmt.Sprintf("Password must be at most %d bytes long", p.cfg.MaxBytes),
+			Limit:   p.cfg.MaxBytes,
+		})
+	}
+	if username != "" && strings.EqualFold(strings.TrimSpace(password), strings.TrimSpace(username)) {
+		violations = append(violations, models.PasswordViolation{
+			Rule:    PasswordRuleNotUsername,
+			Message: "Password must not be the same as the username",
+		})
+	}
+
+	if p.breached != nil {
+		breached, err := p.breached.Breached(ctx, password)
+		if err != nil {
+			return fmt.Errorf("failed to check breached passwords: %w", err)
+		}
+		if breached {
+			violations = append(violations, models.PasswordViolation{
+				Rule:    PasswordRuleBreached,
+				Message: "This password has appeared in a data breach, choose another one",
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"goTodo/backend/logger"
+	"goTodo/backend/models"
+	"goTodo/backend/repository"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// mailTimeout — дедлайн на выпуск токена и отправку письма в фоне.
+const mailTimeout = 30 * time.Second
+
+// PasswordResetConfig — параметры сброса пароля.
+// URL — страница фронта, куда ведет ссылка из письма (токен добавляется параметром token);
+// Cooldown — минимальная пауза между письмами одному пользователю, MaxPerHour — не больше писем
+// ему за час; MaxPerIPPerHour — не больше писем за час по запросам с одного IP (0 — без предела).
+type PasswordResetConfig struct {
+	TokenTTL        time.Duration
+	URL             string
+	Cooldown        time.Duration
+	MaxPerHour      int
+	MaxPerIPPerHour int
+}
+
+// PasswordReset — восстановление доступа по одноразовой ссылке из письма.
+type PasswordReset interface {
+	// Request выпускает токен и отправляет письмо на подтвержденный адрес пользователя
+	// (login — логин или адрес). Для неизвестного пользователя или аккаунта без адреса ничего
+	// не делает и тоже возвращает nil, чтобы по ответу нельзя было проверить существование аккаунта.
+	Request(ctx context.Context, login string, client models.ClientMetadata) error
+	// Confirm меняет пароль по токену и завершает все сессии пользователя. Пароль, не прошедший
+	// политику (*PasswordPolicyError), токен не гасит: пользователь может ввести другой.
+	Confirm(ctx context.Context, token string, newPassword string, client models.ClientMetadata) error
+	// Wait дожидается писем, которые еще отправляются в фоне. Вызывается при остановке
+	// сервера после завершения HTTP-запросов и до закрытия пула БД.
+	Wait()
+}
+
+type passwordReset struct {
+	resets      repository.PasswordResetRepository
+	users       repository.UserRepository
+	refreshRepo repository.RefreshSessionRepository
+	auth        AuthService
+	passwords   PasswordPolicy
+	revocation  TokenRevocation
+	events      SecurityEvents
+	mailer      Mailer
+	cfg         PasswordResetConfig
+	now         func() time.Time
+
+	// pending — фоновые issue, которых ждет Wait.
+	pending sync.WaitGroup
+}
+
+// NewPasswordReset создает сервис сброса пароля.
+// Параметры: resets — токены сброса; users/refreshRepo — пользователи и их сессии;
+// auth — хеширование пароля; passwords — парольная политика; revocation — отзыв access-токенов; events — журнал;
+// mailer — доставка писем; cfg — срок токена, адрес страницы сброса и лимиты писем.
+func NewPasswordReset(
+	resets repository.PasswordResetRepository,
+	users repository.UserRepository,
+	refreshRepo repository.RefreshSessionRepository,
+	auth AuthService,
+	passwords PasswordPolicy,
+	revocation TokenRevocation,
+	events SecurityEvents,
+	mailer Mailer,
+	cfg PasswordResetConfig,
+) PasswordReset {
+	return &passwordReset{
+		resets:      resets,
+		users:       users,
+		refreshRepo: refreshRepo,
+		auth:        auth,
+		passwords:   passwords,
+		revocation:  revocation,
+		events:      events,
+		mailer:      mailer,
+		cfg:         cfg,
+		now:         time.Now,
+	}
+}
+
+func (s *passwordReset) Request(ctx context.Context, login string, client models.ClientMetadata) error {
+	user, err := s.users.FindByLogin(ctx, login)
+	if errors.Is(err, sql.ErrNoRows) {
+		logger.FromContext(ctx).Info("password reset requested for unknown user")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.Email == nil {
+		logger.FromContext(ctx).Info("password reset requested for user without verified email", "user_id", user.ID)
+		return nil
+	}
+
+	// Токен и письмо готовятся в фоне: время ответа не должно зависеть от того,
+	// существует ли пользователь (и от скорости SMTP). По той же причине лимиты писем
+	// проверяются там же, а сверх лимита запрос молча пропускается.
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		s.issue(context.WithoutCancel(ctx), user, client)
+	}()
+	return nil
+}
+
+func (s *passwordReset) Wait() {
+	s.pending.Wait()
+}
+
+func (s *passwordReset) Confirm(ctx context.Context, token string, newPassword string, client models.ClientMetadata) error {
+	tokenHash := hashOpaqueToken(token)
+	userID, err := s.resets.FindUserID(ctx, tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.passwords.Validate(ctx, newPassword, user.Username); err != nil {
+		return err
+	}
+	passwordHash, err := s.auth.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	// Токен гасится только после проверки пароля; из параллельных запросов с ним проходит один.
+	if _, err := s.resets.Consume(ctx, tokenHash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	// Токен уже погашен — доводим смену пароля до конца, даже если клиент оборвал соединение.
+	ctx = context.WithoutCancel(ctx)
+	if err := s.users.UpdatePassword(ctx, userID, passwordHash); err != nil {
+		return err
+	}
+	if _, err := s.revocation.RevokeAllForUser(ctx, userID); err != nil {
+		return err
+	}
+	if _, err := s.refreshRepo.RevokeAllForUser(ctx, userID, "password reset"); err != nil {
+		return fmt.Errorf("failed to revoke refresh sessions: %w", err)
+	}
+
+	s.events.Record(ctx, models.SecurityEvent{
+		UserID:    userID,
+		Type:      SecurityEventPasswordReset,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+	})
+	return nil
+}
+
+// issue выпускает токен сброса и отправляет письмо со ссылкой. Ошибки только логируются.
+func (s *passwordReset) issue(ctx context.Context, user *models.User, client models.ClientMetadata) {
+	ctx, cancel := context.WithTimeout(ctx, mailTimeout)
+	defer cancel()
+	log := logger.FromContext(ctx).With("user_id", user.ID)
+
+	if err := s.checkRate(ctx, user.ID, client.IPAddress); err != nil {
+		var rateLimited *RateLimitError
+		if errors.As(err, &rateLimited) {
+			log.Warn("password reset mail skipped: rate limit", "retry_after", rateLimited.RetryAfter.Round(time.Second))
+			return
+		}
+		log.Error("failed to check password reset rate", "error", err)
+		return
+	}
+
+	token, tokenHash, err := generateOpaqueToken()
+	if err != nil {
+		log.Error("failed to generate password reset token", "error", err)
+		return
+	}
+	link, err := tokenLink(s.cfg.URL, token)
+	if err != nil {
+		log.Error("failed to build password reset link", "error", err)
+		return
+	}
+	if err := s.resets.Create(ctx, user.ID, tokenHash, s.now().UTC().Add(s.cfg.TokenTTL), client.IPAddress); err != nil {
+		log.Error("failed to store password reset token", "error", err)
+		return
+	}
+
+	mail := Mail{
+		To:      *user.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("Someone requested a password reset for your account %q.\n\n"+
+			"Open the link below to set a new password. It is valid for %s and can be used once:\n%s\n\n"+
+			"If it was not you, ignore this message: your password stays the same.\n",
+			user.Username, s.cfg.TokenTTL, link),
+	}
+	if err := s.mailer.Send(ctx, mail); err != nil {
+		log.Error("failed to send password reset mail", "error", err)
+		return
+	}
+	log.Info("password reset mail sent")
+}
+
+// checkRate возвращает RateLimitError, если пользователю недавно уже отправлялось письмо,
+// за час исчерпан MaxPerHour или с адреса ip за час запрошено MaxPerIPPerHour писем.
+func (s *passwordReset) checkRate(ctx context.Context, userID int64, ip string) error {
+	now := s.now()
+	since := now.Add(-emailSendWindow)
+
+	sends, err := s.resets.SendsSince(ctx, userID, since)
+	if err != nil {
+		return err
+	}
+	if err := checkSendRate(sends, now, s.cfg.Cooldown, s.cfg.MaxPerHour); err != nil {
+		return err
+	}
+
+	if s.cfg.MaxPerIPPerHour <= 0 || ip == "" {
+		return nil
+	}
+	sends, err = s.resets.SendsFromIPSince(ctx, ip, since)
+	if err != nil {
+		return err
+	}
+	return checkSendRate(sends, now, 0, s.cfg.MaxPerIPPerHour)
+}
+
+// tokenLink добавляет токен к адресу страницы фронта параметром token.
+func tokenLink(base string, token string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid link url: %w", err)
+	}
+
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,526 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"goTodo/backend/models"
+	"goTodo/backend/repository"
+)
+
+// fakeAccountRepo — пользователи в памяти.
+type fakeAccountRepo struct {
+	repository.UserRepository
+
+	mu    sync.Mutex
+	users map[int64]*models.User
+}
+
+func newFakeAccountRepo(users ...models.User) *fakeAccountRepo {
+	repo := &fakeAccountRepo{users: make(map[int64]*models.User)}
+	for i := range users {
+		repo.users[users[i].ID] = &users[i]
+	}
+	return repo
+}
+
+func (r *fakeAccountRepo) find(match func(*models.User) bool) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if match(user) {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *fakeAccountRepo) FindByID(_ context.Context, id int64) (*models.User, error) {
+	return r.find(func(user *models.User) bool { return user.ID == id })
+}
+
+func (r *fakeAccountRepo) FindByUsername(_ context.Context, username string) (*models.User, error) {
+	return r.find(func(user *models.User) bool { return user.Username == username })
+}
+
+func (r *fakeAccountRepo) FindByEmail(_ context.Context, email string) (*models.User, error) {
+	return r.find(func(user *models.User) bool { return user.Email != nil && *user.Email == email })
+}
+
+func (r *fakeAccountRepo) FindByLogin(_ context.Context, login string) (*models.User, error) {
+	return r.find(func(user *models.User) bool {
+		return user.Username == login || (user.Email != nil && *user.Email == strings.ToLower(login))
+	})
+}
+
+func (r *fakeAccountRepo) UpdatePassword(_ context.Context, id int64, passwordHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	user.PasswordHash = passwordHash
+	return nil
+}
+
+func (r *fakeAccountRepo) user(id int64) models.User {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.users[id]
+}
+
+// fakeMailToken — токен из письма (сброс пароля или подтверждение адреса).
+type fakeMailToken struct {
+	userID    int64
+	email     string
+	ip        string
+	hash      string
+	createdAt time.Time
+	expiresAt time.Time
+	used      bool
+}
+
+// fakeMailTokens — токены в памяти; срок и однократность проверяются так же, как в SQL.
+type fakeMailTokens struct {
+	now func() time.Time
+
+	mu     sync.Mutex
+	tokens []*fakeMailToken
+}
+
+func (s *fakeMailTokens) create(token fakeMailToken) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token.createdAt = s.now()
+	s.tokens = append(s.tokens, &token)
+}
+
+// active возвращает действующий токен с хешем hash. Вызывается под s.mu.
+func (s *fakeMailTokens) active(hash string) (*fakeMailToken, bool) {
+	for _, token := range s.tokens {
+		if token.hash == hash && !token.used && s.now().Before(token.expiresAt) {
+			return token, true
+		}
+	}
+	return nil, false
+}
+
+func (s *fakeMailTokens) sends(since time.Time, match func(*fakeMailToken) bool) repository.TokenSends {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sends repository.TokenSends
+	for _, token := range s.tokens {
+		if token.createdAt.Before(since) || !match(token) {
+			continue
+		}
+		if sends.Count == 0 {
+			sends.First = token.createdAt
+		}
+		sends.Count++
+		sends.Latest = token.createdAt
+	}
+	return sends
+}
+
+type fakeResetRepo struct {
+	repository.PasswordResetRepository
+	fakeMailTokens
+}
+
+func (r *fakeResetRepo) Create(_ context.Context, userID int64, tokenHash string, expiresAt time.Time, requestedIP string) error {
+	r.create(fakeMailToken{userID: userID, hash: tokenHash, expiresAt: expiresAt, ip: requestedIP})
+	return nil
+}
+
+func (r *fakeResetRepo) FindUserID(_ context.Context, tokenHash string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.active(tokenHash)
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return token.userID, nil
+}
+
+func (r *fakeResetRepo) Consume(_ context.Context, tokenHash string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.active(tokenHash)
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	token.used = true
+	return token.userID, nil
+}
+
+func (r *fakeResetRepo) SendsSince(_ context.Context, userID int64, since time.Time) (repository.TokenSends, error) {
+	return r.sends(since, func(token *fakeMailToken) bool { return token.userID == userID }), nil
+}
+
+func (r *fakeResetRepo) SendsFromIPSince(_ context.Context, ip string, since time.Time) (repository.TokenSends, error) {
+	return r.sends(since, func(token *fakeMailToken) bool { return token.ip == ip }), nil
+}
+
+// fakeSessionRevoker запоминает, чьи refresh-сессии отозваны.
+type fakeSessionRevoker struct {
+	repository.RefreshSessionRepository
+
+	mu      sync.Mutex
+	revoked []int64
+}
+
+func (r *fakeSessionRevoker) RevokeAllForUser(_ context.Context, userID int64, _ string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revoked = append(r.revoked, userID)
+	return 1, nil
+}
+
+type fakeMailer struct {
+	mu    sync.Mutex
+	mails []Mail
+}
+
+func (m *fakeMailer) Send(_ context.Context, mail Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mails = append(m.mails, mail)
+	return nil
+}
+
+func (m *fakeMailer) sent() []Mail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Mail(nil), m.mails...)
+}
+
+type fakeEvents struct {
+	SecurityEvents
+
+	mu     sync.Mutex
+	events []models.SecurityEvent
+}
+
+func (e *fakeEvents) Record(_ context.Context, event models.SecurityEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, event)
+}
+
+func (e *fakeEvents) recorded(eventType string) []models.SecurityEvent {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var found []models.SecurityEvent
+	for _, event := range e.events {
+		if event.Type == eventType {
+			found = append(found, event)
+		}
+	}
+	return found
+}
+
+var mailLinkPattern = regexp.MustCompile(`https?://\S+`)
+
+// mailToken достает токен из ссылки в письме; пустая строка — ссылки нет.
+func mailToken(t *testing.T, mail Mail) string {
+	t.Helper()
+	link := mailLinkPattern.FindString(mail.Body)
+	if link == "" {
+		return ""
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("mail link %q: %v", link, err)
+	}
+	return u.Query().Get("token")
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+const (
+	testResetTTL      = time.Hour
+	testResetPassword = "new-password-2"
+)
+
+type resetTestEnv struct {
+	service     *passwordReset
+	clock       time.Time
+	users       *fakeAccountRepo
+	resets      *fakeResetRepo
+	sessions    *fakeSessionRevoker
+	revocations *fakeRevocationRepo
+	mailer      *fakeMailer
+	events      *fakeEvents
+}
+
+// newResetTestEnv создает сервис с пользователями alice, bob и carol (с адресами) и dave (без адреса).
+func newResetTestEnv(t *testing.T, cfg PasswordResetConfig) *resetTestEnv {
+	t.Helper()
+
+	env := &resetTestEnv{
+		clock: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		users: newFakeAccountRepo(
+			models.User{ID: 1, Username: "alice", Email: stringPtr("alice@example.com")},
+			models.User{ID: 2, Username: "bob", Email: stringPtr("bob@example.com")},
+			models.User{ID: 3, Username: "carol", Email: stringPtr("carol@example.com")},
+			models.User{ID: 4, Username: "dave"},
+		),
+		sessions:    &fakeSessionRevoker{},
+		revocations: newFakeRevocationRepo(1, 2, 3, 4),
+		mailer:      &fakeMailer{},
+		events:      &fakeEvents{},
+	}
+	now := func() time.Time { return env.clock }
+	env.resets = &fakeResetRepo{fakeMailTokens: fakeMailTokens{now: now}}
+
+	cfg.TokenTTL = testResetTTL
+	cfg.URL = "https://todo.example.com/reset"
+	env.service = NewPasswordReset(
+		env.resets,
+		env.users,
+		env.sessions,
+		newTestAuthService(t, now),
+		NewPasswordPolicy(PasswordPolicyConfig{MinLength: 8}, nil),
+		NewTokenRevocation(env.revocations, time.Minute),
+		env.events,
+		env.mailer,
+		cfg,
+	).(*passwordReset)
+	env.service.now = now
+	return env
+}
+
+// request запрашивает сброс и дожидается фоновой отправки письма.
+func (e *resetTestEnv) request(t *testing.T, login string, ip string) {
+	t.Helper()
+	if err := e.service.Request(context.Background(), login, models.ClientMetadata{IPAddress: ip}); err != nil {
+		t.Fatalf("Request(%q): %v", login, err)
+	}
+	e.service.Wait()
+}
+
+// issueToken запрашивает сброс для alice и возвращает токен из письма.
+func (e *resetTestEnv) issueToken(t *testing.T) string {
+	t.Helper()
+	e.request(t, "alice", "203.0.113.10")
+	mails := e.mailer.sent()
+	if len(mails) == 0 {
+		t.Fatal("no reset mail sent")
+	}
+	token := mailToken(t, mails[len(mails)-1])
+	if token == "" {
+		t.Fatal("reset mail without a token link")
+	}
+	return token
+}
+
+func TestPasswordResetConfirm(t *testing.T) {
+	tests := []struct {
+		name string
+		// before — что происходит между письмом и подтверждением.
+		before        func(t *testing.T, env *resetTestEnv, token string)
+		token         string
+		password      string
+		wantErr       error
+		wantPolicyErr bool
+	}{
+		{
+			name:     "token resets the password",
+			password: testResetPassword,
+		},
+		{
+			name: "valid just before expiry",
+			before: func(_ *testing.T, env *resetTestEnv, _ string) {
+				env.clock = env.clock.Add(testResetTTL - time.Second)
+			},
+			password: testResetPassword,
+		},
+		{
+			name: "token is single-use",
+			before: func(t *testing.T, env *resetTestEnv, token string) {
+				if err := env.service.Confirm(context.Background(), token, "first-password-1", models.ClientMetadata{}); err != nil {
+					t.Fatalf("first Confirm: %v", err)
+				}
+			},
+			password: testResetPassword,
+			wantErr:  ErrInvalidResetToken,
+		},
+		{
+			name: "expired token",
+			before: func(_ *testing.T, env *resetTestEnv, _ string) {
+				env.clock = env.clock.Add(testResetTTL)
+			},
+			password: testResetPassword,
+			wantErr:  ErrInvalidResetToken,
+		},
+		{
+			name:     "unknown token",
+			token:    "not-a-reset-token",
+			password: testResetPassword,
+			wantErr:  ErrInvalidResetToken,
+		},
+		{
+			name:          "policy violation",
+			password:      "short",
+			wantPolicyErr: true,
+		},
+		{
+			name: "policy violation keeps the token",
+			before: func(t *testing.T, env *resetTestEnv, token string) {
+				var policyErr *PasswordPolicyError
+				if err := env.service.Confirm(context.Background(), token, "short", models.ClientMetadata{}); !errors.As(err, &policyErr) {
+					t.Fatalf("Confirm with a weak password = %v, want *PasswordPolicyError", err)
+				}
+			},
+			password: testResetPassword,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newResetTestEnv(t, PasswordResetConfig{MaxPerHour: 5})
+			token := env.issueToken(t)
+			if tt.before != nil {
+				tt.before(t, env, token)
+			}
+			if tt.token != "" {
+				token = tt.token
+			}
+			hashBefore := env.users.user(1).PasswordHash
+
+			err := env.service.Confirm(context.Background(), token, tt.password, models.ClientMetadata{IPAddress: "203.0.113.10"})
+			switch {
+			case tt.wantPolicyErr:
+				var policyErr *PasswordPolicyError
+				if !errors.As(err, &policyErr) {
+					t.Fatalf("Confirm = %v, want *PasswordPolicyError", err)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Confirm = %v, want %v", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Fatalf("Confirm: %v", err)
+			}
+
+			user := env.users.user(1)
+			if tt.wantErr != nil || tt.wantPolicyErr {
+				if user.PasswordHash != hashBefore {
+					t.Fatal("password changed by a rejected reset")
+				}
+				return
+			}
+
+			if err := newTestAuthService(t, time.Now).VerifyPassword(tt.password, user.PasswordHash); err != nil {
+				t.Fatalf("new password does not verify: %v", err)
+			}
+			if len(env.sessions.revoked) != 1 || env.sessions.revoked[0] != 1 {
+				t.Fatalf("refresh sessions revoked for %v, want [1]", env.sessions.revoked)
+			}
+			if env.revocations.versions[1] == 0 {
+				t.Fatal("access tokens were not revoked")
+			}
+			if events := env.events.recorded(SecurityEventPasswordReset); len(events) != 1 || events[0].UserID != 1 {
+				t.Fatalf("password_reset events = %+v, want one for user 1", events)
+			}
+		})
+	}
+}
+
+func TestPasswordResetRequestRateLimits(t *testing.T) {
+	type step struct {
+		after    time.Duration
+		login    string
+		ip       string
+		wantMail bool
+	}
+	cfg := PasswordResetConfig{Cooldown: time.Minute, MaxPerHour: 3, MaxPerIPPerHour: 4}
+
+	tests := []struct {
+		name  string
+		cfg   PasswordResetConfig
+		steps []step
+	}{
+		{
+			name: "cooldown between mails",
+			cfg:  cfg,
+			steps: []step{
+				{login: "alice", ip: "203.0.113.1", wantMail: true},
+				{after: 30 * time.Second, login: "alice", ip: "203.0.113.2"},
+				{after: 30 * time.Second, login: "alice@example.com", ip: "203.0.113.3", wantMail: true},
+			},
+		},
+		{
+			name: "max per hour",
+			cfg:  cfg,
+			steps: []step{
+				{login: "alice", ip: "203.0.113.1", wantMail: true},
+				{after: 10 * time.Minute, login: "alice", ip: "203.0.113.2", wantMail: true},
+				{after: 10 * time.Minute, login: "alice", ip: "203.0.113.3", wantMail: true},
+				{after: 10 * time.Minute, login: "alice", ip: "203.0.113.4"},
+				// Первое письмо выходит из окна часа.
+				{after: 31 * time.Minute, login: "alice", ip: "203.0.113.5", wantMail: true},
+			},
+		},
+		{
+			name: "max per ip across users",
+			cfg:  PasswordResetConfig{Cooldown: time.Minute, MaxPerHour: 3, MaxPerIPPerHour: 2},
+			steps: []step{
+				{login: "alice", ip: "203.0.113.1", wantMail: true},
+				{login: "bob", ip: "203.0.113.1", wantMail: true},
+				{after: 5 * time.Minute, login: "carol", ip: "203.0.113.1"},
+				{login: "carol", ip: "203.0.113.2", wantMail: true},
+			},
+		},
+		{
+			name: "ip limit disabled",
+			cfg:  PasswordResetConfig{Cooldown: time.Minute, MaxPerHour: 3},
+			steps: []step{
+				{login: "alice", ip: "203.0.113.1", wantMail: true},
+				{login: "bob", ip: "203.0.113.1", wantMail: true},
+				{login: "carol", ip: "203.0.113.1", wantMail: true},
+			},
+		},
+		{
+			name: "unknown login and account without email",
+			cfg:  cfg,
+			steps: []step{
+				{login: "nobody", ip: "203.0.113.1"},
+				{login: "dave", ip: "203.0.113.1"},
+				{login: "alice", ip: "203.0.113.1", wantMail: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newResetTestEnv(t, tt.cfg)
+
+			for i, s := range tt.steps {
+				env.clock = env.clock.Add(s.after)
+				sentBefore := len(env.mailer.sent())
+				env.request(t, s.login, s.ip)
+
+				mails := env.mailer.sent()
+				if sent := len(mails) > sentBefore; sent != s.wantMail {
+					t.Fatalf("step %d (%s from %s): mail sent = %v, want %v", i, s.login, s.ip, sent, s.wantMail)
+				}
+				if s.wantMail && mailToken(t, mails[len(mails)-1]) == "" {
+					t.Fatalf("step %d: reset mail without a token link", i)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RotatedTokenPair — пара, выданная при ротации; в grace-окне отдается повторно как есть.
+type RotatedTokenPair struct {
+	AccessToken      string
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+	RememberMe       bool
+}
+
+// ErrGracePairUnavailable — у использованной сессии нет сохраненной пары (ее повернула
+// версия сервиса без grace_pair или grace-окно выключено).
+var ErrGracePairUnavailable = errors.New("rotated token pair is not available")
+
+// graceKeyContext отделяет ключ шифрования пары от hash самого токена в БД.
+const graceKeyContext = "goTodo refresh grace pair v1\x00"
+
+// RefreshGrace хранит, какая пара токенов была выдана взамен использованного refresh-токена.
+// Две вкладки браузера обновляют сессию одновременно: вторая приходит с уже
+// использованным токеном. В течение окна ей отдается та же новая пара, а не
+// срабатывает детектор повторного использования с отзывом всей семьи.
+//
+// Пара хранится в БД на использованной сессии (grace_pair), поэтому повтор работает на любом
+// инстансе. Она зашифрована AES-GCM ключом из сырого использованного токена: в БД есть только
+// его hash, так что расшифровать пару может лишь тот, кто этот токен предъявил.
+type RefreshGrace interface {
+	// Within сообщает, не закончилось ли окно для сессии, использованной в consumedAt.
+	Within(consumedAt time.Time) bool
+	// Seal шифрует пару, выданную взамен consumedToken, для RotateSession.
+	// При выключенном окне возвращает nil: хранить нечего.
+	Seal(consumedToken string, pair RotatedTokenPair) ([]byte, error)
+	// Open расшифровывает пару из grace_pair; пустой sealed — ErrGracePairUnavailable.
+	Open(consumedToken string, sealed []byte) (RotatedTokenPair, error)
+	// Lock сериализует refresh одним токеном внутри процесса: параллельный запрос ждет,
+	// пока первый повернет сессию, и получает пару из окна, а не конфликт ротации.
+	// Возвращает функцию снятия блокировки.
+	Lock(tokenHash string) (unlock func())
+}
+
+type refreshGrace struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	locks map[string]*tokenLock
+}
+
+// tokenLock — блокировка refresh одного токена; holders (под refreshGrace.mu) — сколько
+// запросов держат или ждут ее. Последний удаляет запись, поэтому карта не растет.
+type tokenLock struct {
+	mu      sync.Mutex
+	holders int
+}
+
+// NewRefreshGrace создает RefreshGrace. Параметры: window — длительность grace-окна (0 — выключено).
+func NewRefreshGrace(window time.Duration) RefreshGrace {
+	return &refreshGrace{
+		window: window,
+		now:    time.Now,
+		locks:  make(map[string]*tokenLock),
+	}
+}
+
+func (g *refreshGrace) Within(consumedAt time.Time) bool {
+	return g.window > 0 && g.now().Before(consumedAt.Add(g.window))
+}
+
+func (g *refreshGrace) Seal(consumedToken string, pair RotatedTokenPair) ([]byte, error) {
+	if g.window <= 0 {
+		return nil, nil
+	}
+
+	plaintext, err := json.Marshal(pair)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode rotated token pair: %w", err)
+	}
+	aead, err := graceCipher(consumedToken)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate grace pair nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (g *refreshGrace) Open(consumedToken string, sealed []byte) (RotatedTokenPair, error) {
+	if len(sealed) == 0 {
+		return RotatedTokenPair{}, ErrGracePairUnavailable
+	}
+
+	aead, err := graceCipher(consumedToken)
+	if err != nil {
+		return RotatedTokenPair{}, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return RotatedTokenPair{}, errors.New("grace pair is truncated")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return RotatedTokenPair{}, fmt.Errorf("failed to decrypt grace pair: %w", err)
+	}
+
+	var pair RotatedTokenPair
+	if err := json.Unmarshal(plaintext, &pair); err != nil {
+		return RotatedTokenPair{}, fmt.Errorf("failed to decode grace pair: %w", err)
+	}
+	return pair, nil
+}
+
+// graceCipher возвращает AES-256-GCM с ключом SHA-256(graceKeyContext || token).
+// Токен — 256 случайных бит, поэтому одного хеширования для вывода ключа достаточно.
+func graceCipher(consumedToken string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(graceKeyContext + consumedToken))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create grace pair cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create grace pair cipher: %w", err)
+	}
+	return aead, nil
+}
+
+func (g *refreshGrace) Lock(tokenHash string) func() {
+	g.mu.Lock()
+	lock, ok := g.locks[tokenHash]
+	if !ok {
+		lock = &tokenLock{}
+		g.locks[tokenHash] = lock
+	}
+	lock.holders++
+	g.mu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+
+		g.mu.Lock()
+		lock.holders--
+		if lock.holders == 0 {
+			delete(g.locks, tokenHash)
+		}
+		g.mu.Unlock()
+	}
+}
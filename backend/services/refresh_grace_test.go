@@ -0,0 +1,91 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRefreshGraceSealOpen(t *testing.T) {
+	grace := NewRefreshGrace(10 * time.Second)
+	consumed, _, err := generateOpaqueToken()
+	if err != nil {
+		t.Fatalf("generateOpaqueToken: %v", err)
+	}
+	pair := RotatedTokenPair{
+		AccessToken:      "access",
+		RefreshToken:     "successor-refresh-token",
+		RefreshExpiresAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		RememberMe:       true,
+	}
+
+	sealed, err := grace.Seal(consumed, pair)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	// В БД не должно попасть ничего, из чего токен преемника читается без consumed.
+	if bytes.Contains(sealed, []byte(pair.RefreshToken)) || bytes.Contains(sealed, []byte(pair.AccessToken)) {
+		t.Fatal("sealed pair contains plaintext tokens")
+	}
+
+	other, _, err := generateOpaqueToken()
+	if err != nil {
+		t.Fatalf("generateOpaqueToken: %v", err)
+	}
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name    string
+		token   string
+		sealed  []byte
+		wantErr error
+		anyErr  bool
+	}{
+		{name: "consumed token opens the pair", token: consumed, sealed: sealed},
+		{name: "another token cannot open it", token: other, sealed: sealed, anyErr: true},
+		{name: "hash of the token cannot open it", token: hashOpaqueToken(consumed), sealed: sealed, anyErr: true},
+		{name: "tampered ciphertext", token: consumed, sealed: tampered, anyErr: true},
+		{name: "truncated ciphertext", token: consumed, sealed: sealed[:5], anyErr: true},
+		{name: "row without a pair", token: consumed, sealed: nil, wantErr: ErrGracePairUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := grace.Open(tt.token, tt.sealed)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Open error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.anyErr:
+				if err == nil {
+					t.Fatal("Open succeeded, want an error")
+				}
+			default:
+				if err != nil {
+					t.Fatalf("Open: %v", err)
+				}
+				if got != pair {
+					t.Fatalf("Open = %+v, want %+v", got, pair)
+				}
+			}
+		})
+	}
+}
+
+func TestRefreshGraceDisabledStoresNothing(t *testing.T) {
+	grace := NewRefreshGrace(0)
+
+	sealed, err := grace.Seal("token", RotatedTokenPair{AccessToken: "access"})
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if sealed != nil {
+		t.Fatalf("Seal with a disabled window = %x, want nil", sealed)
+	}
+	if grace.Within(time.Now()) {
+		t.Fatal("Within is true with a disabled window")
+	}
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"goTodo/backend/logger"
+	"goTodo/backend/models"
+	"goTodo/backend/repository"
+)
+
+// Типы событий безопасности.
+const (
+	SecurityEventRefreshReuse          = "refresh_token_reuse"
+	SecurityEventRefreshExpired        = "refresh_token_expired"
+	SecurityEventLoginFailed           = "login_failed"
+	SecurityEventRepeatedLoginFailures = "repeated_login_failures"
+	SecurityEventPasswordChanged       = "password_changed"
+	SecurityEventPasswordReset         = "password_reset"
+	SecurityEventEmailChanged          = "email_changed"
+)
+
+// notifyTimeout — дедлайн на доставку одного уведомления.
+const notifyTimeout = 10 * time.Second
+
+// SecurityEvents записывает события безопасности в журнал пользователя
+// и уведомляет его о подозрительных (reuse refresh-токена, серия неудачных входов)
+// и о смене или сбросе пароля и адреса почты.
+type SecurityEvents interface {
+	Record(ctx context.Context, event models.SecurityEvent)
+	List(ctx context.Context, userID int64, limit int) ([]models.SecurityEvent, error)
+}
+
+// FailedLoginPolicy — когда серия неудачных входов считается подозрительной:
+// Threshold неудач за Window.
+type FailedLoginPolicy struct {
+	Threshold int
+	Window    time.Duration
+}
+
+type securityEvents struct {
+	repo        repository.SecurityEventRepository
+	notifier    Notifier
+	failedLogin FailedLoginPolicy
+	now         func() time.Time
+}
+
+// NewSecurityEvents создает журнал событий безопасности.
+// Параметры: repo — хранилище; notifier — канал уведомлений (nil — без уведомлений);
+// failedLogin — порог серии неудачных входов.
+func NewSecurityEvents(repo repository.SecurityEventRepository, notifier Notifier, failedLogin FailedLoginPolicy) SecurityEvents {
+	if notifier == nil {
+		notifier = NewNopNotifier()
+	}
+
+	return &securityEvents{
+		repo:        repo,
+		notifier:    notifier,
+		failedLogin: failedLogin,
+		now:         time.Now,
+	}
+}
+
+// Record сохраняет событие. Ошибки только логируются: журнал не должен ломать
+// вход или refresh. Запись не прерывается, если клиент оборвал соединение.
+func (s *securityEvents) Record(ctx context.Context, event models.SecurityEvent) {
+	ctx = context.WithoutCancel(ctx)
+	log := logger.FromContext(ctx)
+
+	if err := s.repo.Create(ctx, &event); err != nil {
+		log.Error("failed to store security event", "type", event.Type, "error", err)
+		return
+	}
+	log.Warn("security event", "type", event.Type, "event_id", event.ID)
+
+	switch event.Type {
+	case SecurityEventRefreshReuse, SecurityEventRepeatedLoginFailures, SecurityEventPasswordChanged, SecurityEventPasswordReset, SecurityEventEmailChanged:
+		s.notify(ctx, event)
+	case SecurityEventLoginFailed:
+		s.checkRepeatedLoginFailures(ctx, event)
+	}
+}
+
+// List возвращает последние события пользователя, новые первыми.
+func (s *securityEvents) List(ctx context.Context, userID int64, limit int) ([]models.SecurityEvent, error) {
+	return s.repo.ListByUserID(ctx, userID, limit)
+}
+
+// checkRepeatedLoginFailures пишет repeated_login_failures ровно один раз,
+// когда число неудач в окне достигает порога.
+func (s *securityEvents) checkRepeatedLoginFailures(ctx context.Context, event models.SecurityEvent) {
+	if s.failedLogin.Threshold <= 0 {
+		return
+	}
+
+	count, err := s.repo.CountSince(ctx, event.UserID, SecurityEventLoginFailed, s.now().Add(-s.failedLogin.Window))
+	if err != nil {
+		logger.FromContext(ctx).Error("failed to count failed logins", "error", err)
+		return
+	}
+	if count != s.failedLogin.Threshold {
+		return
+	}
+
+	s.Record(ctx, models.SecurityEvent{
+		UserID:    event.UserID,
+		Type:      SecurityEventRepeatedLoginFailures,
+		IPAddress: event.IPAddress,
+		UserAgent: event.UserAgent,
+		Details: map[string]string{
+			"failures": strconv.Itoa(count),
+			"window":   s.failedLogin.Window.String(),
+		},
+	})
+}
+
+// notify отправляет уведомление в фоне, чтобы медленный канал не задерживал ответ.
+func (s *securityEvents) notify(ctx context.Context, event models.SecurityEvent) {
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		defer cancel()
+
+		if err := s.notifier.Notify(ctx, event); err != nil {
+			logger.FromContext(ctx).Error("failed to send security notification", "type", event.Type, "event_id", event.ID, "error", err)
+		}
+	}()
+}
+
+// SecurityEventCleanup удаляет записи журнала старше срока хранения.
+type SecurityEventCleanup interface {
+	Run(ctx context.Context) (int64, error)
+}
+
+type securityEventCleanup struct {
+	repo      repository.SecurityEventRepository
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewSecurityEventCleanup создает задачу очистки журнала. Параметры: repo — журнал;
+// retention — сколько хранить событие.
+func NewSecurityEventCleanup(repo repository.SecurityEventRepository, retention time.Duration) SecurityEventCleanup {
+	return &securityEventCleanup{
+		repo:      repo,
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// Run удаляет события пачками по sessionCleanupBatchSize, как и очистка сессий,
+// и возвращает общее число удаленных (в том числе при ошибке на очередной пачке).
+func (c *securityEventCleanup) Run(ctx context.Context) (int64, error) {
+	cutoff := c.now().UTC().Add(-c.retention)
+
+	var total int64
+	for {
+		deleted, err := c.repo.DeleteBefore(ctx, cutoff, sessionCleanupBatchSize)
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+		if deleted < sessionCleanupBatchSize {
+			break
+		}
+	}
+
+	logger.FromContext(ctx).Debug("security events pruned", "cutoff", cutoff, "deleted", total)
+	return total, nil
+}
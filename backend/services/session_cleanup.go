@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"goTodo/backend/logger"
+	"goTodo/backend/repository"
+)
+
+// sessionCleanupBatchSize — сколько сессий удаляется одним запросом.
+const sessionCleanupBatchSize = 1000
+
+// SessionCleanup удаляет refresh-сессии, которые уже не могут понадобиться:
+// истекшие или отозванные раньше срока хранения. Пока срок не вышел, строки
+// остаются для журнала и детектора повторного использования.
+type SessionCleanup interface {
+	Run(ctx context.Context) (int64, error)
+}
+
+type sessionCleanup struct {
+	repo      repository.RefreshSessionRepository
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewSessionCleanup создает задачу очистки. Параметры: repo — refresh-сессии;
+// retention — сколько хранить сессию после истечения или отзыва.
+func NewSessionCleanup(repo repository.RefreshSessionRepository, retention time.Duration) SessionCleanup {
+	return &sessionCleanup{
+		repo:      repo,
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// Run удаляет сессии пачками, пока они не кончатся или не истечет ctx,
+// и возвращает общее число удаленных (в том числе при ошибке на очередной пачке).
+func (c *sessionCleanup) Run(ctx context.Context) (int64, error) {
+	cutoff := c.now().UTC().Add(-c.retention)
+
+	var total int64
+	for {
+		deleted, err := c.repo.DeleteInactiveBefore(ctx, cutoff, sessionCleanupBatchSize)
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+		if deleted < sessionCleanupBatchSize {
+			break
+		}
+	}
+
+	logger.FromContext(ctx).Debug("refresh sessions pruned", "cutoff", cutoff, "deleted", total)
+	return total, nil
+}
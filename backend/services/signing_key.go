@@ -0,0 +1,149 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"goTodo/backend/models"
+)
+
+// Поддерживаемые алгоритмы подписи access-токенов.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// minRSAKeyBits — минимальный размер RSA-ключа.
+const minRSAKeyBits = 2048
+
+var ErrUnsupportedKeyType = errors.New("unsupported jwt private key type")
+
+// SigningKey — ключ подписи JWT с идентификатором kid.
+// Для HS256 заполняется Secret, для RS256/EdDSA — PrivateKey.
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	Secret     []byte
+	PrivateKey crypto.Signer
+}
+
+// NewHMACKey создает симметричный ключ HS256.
+func NewHMACKey(kid string, secret []byte) SigningKey {
+	return SigningKey{ID: kid, Algorithm: AlgHS256, Secret: secret}
+}
+
+// NewAsymmetricKey создает ключ RS256 или EdDSA по типу приватного ключа.
+func NewAsymmetricKey(kid string, privateKey crypto.Signer) (SigningKey, error) {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		if bits := key.N.BitLen(); bits < minRSAKeyBits {
+			return SigningKey{}, fmt.Errorf("jwt key %q: rsa key must be at least %d bits, got %d", kid, minRSAKeyBits, bits)
+		}
+		return SigningKey{ID: kid, Algorithm: AlgRS256, PrivateKey: key}, nil
+	case ed25519.PrivateKey:
+		return SigningKey{ID: kid, Algorithm: AlgEdDSA, PrivateKey: key}, nil
+	default:
+		return SigningKey{}, fmt.Errorf("%w: %T", ErrUnsupportedKeyType, privateKey)
+	}
+}
+
+// ParsePrivateKeyPEM разбирает приватный ключ RSA (PKCS#1 или PKCS#8) или Ed25519 (PKCS#8).
+func ParsePrivateKeyPEM(kid string, data []byte) (SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return SigningKey{}, fmt.Errorf("jwt key %q: no PEM block found", kid)
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return SigningKey{}, fmt.Errorf("jwt key %q: unsupported PEM block %q", kid, block.Type)
+	}
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("jwt key %q: failed to parse private key: %w", kid, err)
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return SigningKey{}, fmt.Errorf("%w: %T", ErrUnsupportedKeyType, parsed)
+	}
+
+	return NewAsymmetricKey(kid, signer)
+}
+
+func (k SigningKey) validate() error {
+	if k.ID == "" {
+		return errors.New("jwt key id is required")
+	}
+
+	switch k.Algorithm {
+	case AlgHS256:
+		if len(k.Secret) == 0 {
+			return fmt.Errorf("jwt key %q: secret is required", k.ID)
+		}
+	case AlgRS256, AlgEdDSA:
+		if k.PrivateKey == nil {
+			return fmt.Errorf("jwt key %q: private key is required", k.ID)
+		}
+	default:
+		return fmt.Errorf("jwt key %q: unsupported algorithm %q", k.ID, k.Algorithm)
+	}
+
+	return nil
+}
+
+func (k SigningKey) signingMethod() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// signKey возвращает материал для подписи в формате, который ждет golang-jwt.
+func (k SigningKey) signKey() any {
+	if k.Algorithm == AlgHS256 {
+		return k.Secret
+	}
+	return k.PrivateKey
+}
+
+// verifyKey возвращает материал для проверки подписи: секрет или публичный ключ.
+func (k SigningKey) verifyKey() any {
+	if k.Algorithm == AlgHS256 {
+		return k.Secret
+	}
+	return k.PrivateKey.Public()
+}
+
+// PublicJWK возвращает публичную часть ключа в формате JWK (RFC 7517).
+// Для HMAC-ключей возвращает ошибку: их нельзя публиковать.
+func (k SigningKey) PublicJWK() (models.JWK, error) {
+	jwk := models.JWK{KeyID: k.ID, Algorithm: k.Algorithm, Use: "sig"}
+
+	switch pub := k.verifyKey().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return models.JWK{}, fmt.Errorf("jwt key %q: %s key has no public part", k.ID, k.Algorithm)
+	}
+
+	return jwk, nil
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"goTodo/backend/models"
+	"goTodo/backend/repository"
+)
+
+var (
+	ErrNothingToUndo       = errors.New("nothing to undo")
+	ErrNothingToRedo       = errors.New("nothing to redo")
+	ErrTodoChanged         = errors.New("todo has changed since the operation")
+	ErrInvalidHistoryLimit = errors.New("history limit must be greater than zero")
+)
+
+const (
+	TodoOperationCreate = "create"
+	TodoOperationDelete = "delete"
+)
+
+// TodoCommand описывает обратимую мутацию задач пользователя.
+// Undo откатывает операцию, Redo применяет её повторно. Обе возвращают
+// ErrTodoChanged, если задача изменилась с момента записи команды.
+type TodoCommand interface {
+	Operation() string
+	Undo(ctx context.Context, repo repository.TodoRepository, userID int64) (*models.Todo, error)
+	Redo(ctx context.Context, repo repository.TodoRepository, userID int64) (*models.Todo, error)
+}
+
+// TodoHistoryResult — результат undo/redo: какая операция затронута и итоговое состояние задачи.
+type TodoHistoryResult struct {
+	Operation string
+	Todo      *models.Todo
+}
+
+// TodoHistory хранит ограниченные стеки undo/redo для каждого пользователя.
+// История живёт в памяти процесса и сбрасывается при рестарте сервера.
+type TodoHistory interface {
+	Record(userID int64, cmd TodoCommand)
+	Undo(ctx context.Context, userID int64) (*TodoHistoryResult, error)
+	Redo(ctx context.Context, userID int64) (*TodoHistoryResult, error)
+}
+
+// todoHistoryIdleTTL — история пользователя, к которой не обращались дольше, удаляется.
+const todoHistoryIdleTTL = time.Hour
+
+type userTodoHistory struct {
+	mu   sync.Mutex
+	undo []TodoCommand
+	redo []TodoCommand
+
+	// active и lastUsed защищены todoHistory.mu.
+	active   int
+	lastUsed time.Time
+}
+
+// todoHistory — in-memory реализация TodoHistory.
+// Операции одного пользователя сериализуются его собственным mutex,
+// поэтому параллельные undo/redo разных пользователей не блокируют друг друга.
+// Запись пользователя удаляется, как только оба стека пусты, и после todoHistoryIdleTTL
+// без обращений, поэтому память не растет с числом пользователей, когда-либо менявших задачи.
+type todoHistory struct {
+	repo  repository.TodoRepository
+	limit int
+	now   func() time.Time
+
+	mu        sync.Mutex
+	users     map[int64]*userTodoHistory
+	lastSweep time.Time
+}
+
+// NewTodoHistory создает историю операций над задачами.
+// Параметры: repo — репозиторий задач; limit — максимальная глубина стека на пользователя.
+func NewTodoHistory(repo repository.TodoRepository, limit int) (TodoHistory, error) {
+	if limit <= 0 {
+		return nil, ErrInvalidHistoryLimit
+	}
+
+	return &todoHistory{
+		repo:  repo,
+		limit: limit,
+		now:   time.Now,
+		users: make(map[int64]*userTodoHistory),
+	}, nil
+}
+
+// Record кладет выполненную команду в стек undo и очищает стек redo.
+// Самые старые команды вытесняются при превышении лимита.
+func (h *todoHistory) Record(userID int64, cmd TodoCommand) {
+	history := h.acquire(userID)
+	defer h.release(userID, history)
+	history.mu.Lock()
+	defer history.mu.Unlock()
+
+	history.undo = append(history.undo, cmd)
+	if len(history.undo) > h.limit {
+		history.undo = history.undo[len(history.undo)-h.limit:]
+	}
+	history.redo = nil
+}
+
+// Undo откатывает последнюю операцию пользователя и переносит её в стек redo.
+// Если задача изменилась, команда выбрасывается из истории и возвращается ErrTodoChanged.
+func (h *todoHistory) Undo(ctx context.Context, userID int64) (*TodoHistoryResult, error) {
+	history := h.acquire(userID)
+	defer h.release(userID, history)
+	history.mu.Lock()
+	defer history.mu.Unlock()
+
+	if len(history.undo) == 0 {
+		return nil, ErrNothingToUndo
+	}
+
+	cmd := history.undo[len(history.undo)-1]
+	todo, err := cmd.Undo(ctx, h.repo, userID)
+	if err != nil {
+		if errors.Is(err, ErrTodoChanged) {
+			history.undo = history.undo[:len(history.undo)-1]
+		}
+		return nil, err
+	}
+
+	history.undo = history.undo[:len(history.undo)-1]
+	history.redo = append(history.redo, cmd)
+
+	return &TodoHistoryResult{Operation: cmd.Operation(), Todo: todo}, nil
+}
+
+// Redo повторно применяет последнюю отмененную операцию и возвращает её в стек undo.
+func (h *todoHistory) Redo(ctx context.Context, userID int64) (*TodoHistoryResult, error) {
+	history := h.acquire(userID)
+	defer h.release(userID, history)
+	history.mu.Lock()
+	defer history.mu.Unlock()
+
+	if len(history.redo) == 0 {
+		return nil, ErrNothingToRedo
+	}
+
+	cmd := history.redo[len(history.redo)-1]
+	todo, err := cmd.Redo(ctx, h.repo, userID)
+	if err != nil {
+		if errors.Is(err, ErrTodoChanged) {
+			history.redo = history.redo[:len(history.redo)-1]
+		}
+		return nil, err
+	}
+
+	history.redo = history.redo[:len(history.redo)-1]
+	history.undo = append(history.undo, cmd)
+
+	return &TodoHistoryResult{Operation: cmd.Operation(), Todo: todo}, nil
+}
+
+// acquire возвращает историю пользователя (создает при необходимости) и помечает ее
+// занятой: пока операция не вызвала release, запись не будет удалена.
+func (h *todoHistory) acquire(userID int64) *userTodoHistory {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	if now.Sub(h.lastSweep) >= todoHistoryIdleTTL {
+		h.sweepIdle(now)
+		h.lastSweep = now
+	}
+
+	history, ok := h.users[userID]
+	if !ok {
+		history = &userTodoHistory{}
+		h.users[userID] = history
+	}
+	history.active++
+	history.lastUsed = now
+
+	return history
+}
+
+// release снимает отметку acquire и удаляет запись, если ею больше никто не пользуется
+// и оба стека пусты. Вызывается без history.mu; порядок блокировок — h.mu, затем history.mu.
+func (h *todoHistory) release(userID int64, history *userTodoHistory) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	history.active--
+	if history.active > 0 {
+		return
+	}
+
+	history.mu.Lock()
+	empty := len(history.undo) == 0 && len(history.redo) == 0
+	history.mu.Unlock()
+
+	if empty && h.users[userID] == history {
+		delete(h.users, userID)
+	}
+}
+
+// sweepIdle удаляет незанятые истории, к которым не обращались дольше todoHistoryIdleTTL.
+// Вызывается под h.mu.
+func (h *todoHistory) sweepIdle(now time.Time) {
+	for userID, history := range h.users {
+		if history.active == 0 && now.Sub(history.lastUsed) > todoHistoryIdleTTL {
+			delete(h.users, userID)
+		}
+	}
+}
+
+// createTodoCommand — обратная команда для создания задачи.
+// Undo удаляет созданную задачу, Redo восстанавливает её с тем же id.
+type createTodoCommand struct {
+	todo models.Todo
+}
+
+// NewCreateTodoCommand возвращает команду для только что созданной задачи.
+func NewCreateTodoCommand(todo models.Todo) TodoCommand {
+	return &createTodoCommand{todo: todo}
+}
+
+func (c *createTodoCommand) Operation() string {
+	return TodoOperationCreate
+}
+
+func (c *createTodoCommand) Undo(ctx context.Context, repo repository.TodoRepository, userID int64) (*models.Todo, error) {
+	return deleteUnchangedTodo(ctx, repo, c.todo, userID)
+}
+
+func (c *createTodoCommand) Redo(ctx context.Context, repo repository.TodoRepository, userID int64) (*models.Todo, error) {
+	return restoreTodo(ctx, repo, c.todo, userID)
+}
+
+// deleteTodoCommand — обратная команда для удаления задачи.
+// Undo восстанавливает удалённую задачу, Redo удаляет её снова.
+type deleteTodoCommand struct {
+	todo models.Todo
+}
+
+// NewDeleteTodoCommand возвращает команду для удалённой задачи.
+func NewDeleteTodoCommand(todo models.Todo) TodoCommand {
+	return &deleteTodoCommand{todo: todo}
+}
+
+func (c *deleteTodoCommand) Operation() string {
+	return TodoOperationDelete
+}
+
+func (c *deleteTodoCommand) Undo(ctx context.Context, repo repository.TodoRepository, userID int64) (*models.Todo, error) {
+	return restoreTodo(ctx, repo, c.todo, userID)
+}
+
+func (c *deleteTodoCommand) Redo(ctx context.Context, repo repository.TodoRepository, userID int64) (*models.Todo, error) {
+	return deleteUnchangedTodo(ctx, repo, c.todo, userID)
+}
+
+func restoreTodo(ctx context.Context, repo repository.TodoRepository, todo models.Todo, userID int64) (*models.Todo, error) {
+	if err := repo.Restore(ctx, &todo, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %v", ErrTodoChanged, err)
+		}
+		return nil, err
+	}
+
+	return &todo, nil
+}
+
+func deleteUnchangedTodo(ctx context.Context, repo repository.TodoRepository, todo models.Todo, userID int64) (*models.Todo, error) {
+	if err := repo.DeleteIfUnchanged(ctx, &todo, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %v", ErrTodoChanged, err)
+		}
+		return nil, err
+	}
+
+	return &todo, nil
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"goTodo/backend/repository"
+)
+
+var ErrTokenRevoked = errors.New("access token has been revoked")
+
+// revocationCacheSweepSize — при таком числе записей кэш вычищает устаревшие.
+const revocationCacheSweepSize = 10_000
+
+// TokenRevocation отзывает access-токены до истечения exp и проверяет их в middleware.
+// Отзыв бывает двух видов: конкретный токен по jti (logout) и все токены пользователя
+// (logout everywhere, смена пароля): версия токенов пользователя увеличивается, и токены
+// со старой версией в claim ver отклоняются.
+type TokenRevocation interface {
+	Check(ctx context.Context, claims *AccessTokenClaims) error
+	RevokeToken(ctx context.Context, claims *AccessTokenClaims) error
+	// RevokeAllForUser возвращает новую версию: ее несут токены, выпущенные после отзыва.
+	RevokeAllForUser(ctx context.Context, userID int64) (int64, error)
+}
+
+// tokenRevocation кэширует результаты проверок на cacheTTL, чтобы middleware
+// не ходил в БД на каждый запрос. Отзыв через этот же инстанс сразу обновляет кэш,
+// на других инстансах он вступает в силу не позже чем через cacheTTL.
+type tokenRevocation struct {
+	repo     repository.AccessTokenRevocationRepository
+	cacheTTL time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	versions map[int64]versionEntry
+	jtis     map[string]jtiEntry
+}
+
+type versionEntry struct {
+	version int64
+	expires time.Time
+}
+
+type jtiEntry struct {
+	revoked bool
+	expires time.Time
+}
+
+// NewTokenRevocation создает сервис отзыва access-токенов.
+// Параметры: repo — хранилище отзывов; cacheTTL — время жизни кэша проверок (0 — без кэша).
+func NewTokenRevocation(repo repository.AccessTokenRevocationRepository, cacheTTL time.Duration) TokenRevocation {
+	return &tokenRevocation{
+		repo:     repo,
+		cacheTTL: cacheTTL,
+		now:      time.Now,
+		versions: make(map[int64]versionEntry),
+		jtis:     make(map[string]jtiEntry),
+	}
+}
+
+// Check возвращает ErrTokenRevoked, если токен в deny list или его версия меньше текущей версии
+// токенов пользователя (в том числе если пользователь удален). Другие ошибки — технические.
+func (s *tokenRevocation) Check(ctx context.Context, claims *AccessTokenClaims) error {
+	version, revoked, ok := s.cached(claims.UserID, claims.ID)
+	if !ok {
+		var err error
+		version, revoked, err = s.load(ctx, claims.UserID, claims.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTokenRevoked
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if revoked {
+		return ErrTokenRevoked
+	}
+	if claims.TokenVersion < version {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+// RevokeToken отзывает один токен до его exp.
+func (s *tokenRevocation) RevokeToken(ctx context.Context, claims *AccessTokenClaims) error {
+	if claims.ID == "" {
+		return ErrMissingTokenID
+	}
+
+	expiresAt := s.now().Add(time.Hour)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	if err := s.repo.RevokeToken(ctx, claims.ID, claims.UserID, expiresAt); err != nil {
+		return err
+	}
+
+	if s.cacheTTL > 0 {
+		s.mu.Lock()
+		s.jtis[claims.ID] = jtiEntry{revoked: true, expires: expiresAt}
+		s.mu.Unlock()
+	}
+
+	return nil
+}
+
+// RevokeAllForUser отзывает все уже выданные токены пользователя. Токен, который выпускается
+// после отзыва (например, при смене пароля), должен нести возвращенную версию.
+func (s *tokenRevocation) RevokeAllForUser(ctx context.Context, userID int64) (int64, error) {
+	version, err := s.repo.RevokeAllForUser(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	if s.cacheTTL > 0 {
+		s.mu.Lock()
+		s.versions[userID] = versionEntry{version: version, expires: s.now().Add(s.cacheTTL)}
+		s.mu.Unlock()
+	}
+
+	return version, nil
+}
+
+func (s *tokenRevocation) cached(userID int64, jti string) (int64, bool, bool) {
+	if s.cacheTTL <= 0 {
+		return 0, false, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	version, ok := s.versions[userID]
+	if !ok || !now.Before(version.expires) {
+		return 0, false, false
+	}
+	entry, ok := s.jtis[jti]
+	if !ok || !now.Before(entry.expires) {
+		return 0, false, false
+	}
+
+	return version.version, entry.revoked, true
+}
+
+func (s *tokenRevocation) load(ctx context.Context, userID int64, jti string) (int64, bool, error) {
+	version, revoked, err := s.repo.GetRevocationState(ctx, userID, jti)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if s.cacheTTL > 0 {
+		s.mu.Lock()
+		now := s.now()
+		if len(s.versions)+len(s.jtis) > revocationCacheSweepSize {
+			s.sweepLocked(now)
+		}
+		expires := now.Add(s.cacheTTL)
+		// Версия только растет: более новая, записанная RevokeAllForUser, не откатывается.
+		if current, ok := s.versions[userID]; !ok || current.version <= version {
+			s.versions[userID] = versionEntry{version: version, expires: expires}
+		}
+		// Отозванный jti остается отозванным навсегда — его можно не перепроверять.
+		if current, ok := s.jtis[jti]; !ok || !current.revoked {
+			s.jtis[jti] = jtiEntry{revoked: revoked, expires: expires}
+		}
+		s.mu.Unlock()
+	}
+
+	return version, revoked, nil
+}
+
+func (s *tokenRevocation) sweepLocked(now time.Time) {
+	for userID, entry := range s.versions {
+		if !now.Before(entry.expires) {
+			delete(s.versions, userID)
+		}
+	}
+	for jti, entry := range s.jtis {
+		if !now.Before(entry.expires) {
+			delete(s.jtis, jti)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"goTodo/backend/models"
+	"goTodo/backend/repository"
+)
+
+// fakeRevocationRepo — версии токенов и deny list в памяти.
+type fakeRevocationRepo struct {
+	repository.AccessTokenRevocationRepository
+
+	mu       sync.Mutex
+	versions map[int64]int64
+	jtis     map[string]bool
+}
+
+func newFakeRevocationRepo(userIDs ...int64) *fakeRevocationRepo {
+	repo := &fakeRevocationRepo{versions: make(map[int64]int64), jtis: make(map[string]bool)}
+	for _, id := range userIDs {
+		repo.versions[id] = 0
+	}
+	return repo
+}
+
+func (r *fakeRevocationRepo) RevokeToken(_ context.Context, jti string, _ int64, _ time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jtis[jti] = true
+	return nil
+}
+
+func (r *fakeRevocationRepo) RevokeAllForUser(_ context.Context, userID int64) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	version, ok := r.versions[userID]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	r.versions[userID] = version + 1
+	return version + 1, nil
+}
+
+func (r *fakeRevocationRepo) GetRevocationState(_ context.Context, userID int64, jti string) (int64, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	version, ok := r.versions[userID]
+	if !ok {
+		return 0, false, sql.ErrNoRows
+	}
+	return version, r.jtis[jti], nil
+}
+
+// newTestAuthService создает AuthService с HS256-ключом и часами clock.
+func newTestAuthService(t *testing.T, clock func() time.Time) AuthService {
+	t.Helper()
+
+	ring, err := NewKeyRing(NewStaticKeySource([]SigningKey{
+		NewHMACKey("test", []byte("0123456789abcdef0123456789abcdef")),
+	}, "test"), time.Minute, nil)
+	if err != nil {
+		t.Fatalf("NewKeyRing: %v", err)
+	}
+	auth, err := NewAuthService(ring, 15*time.Minute, time.Hour, TokenClaimsConfig{Issuer: "test", Audience: "test"})
+	if err != nil {
+		t.Fatalf("NewAuthService: %v", err)
+	}
+	auth.(*authService).now = clock
+	return auth
+}
+
+func TestRevokeAllForUserWithinOneSecond(t *testing.T) {
+	// Все токены и отзыв — в пределах одной секунды, кроме "one second after".
+	revokedAt := time.Date(2026, 1, 2, 3, 4, 5, 500_000_000, time.UTC)
+
+	tests := []struct {
+		name string
+		// issuedAt — смещение выпуска от момента отзыва.
+		issuedAt time.Duration
+		// afterRevoke — токен выпущен с версией, которую вернул отзыв (как при смене пароля);
+		// иначе — с версией, прочитанной до отзыва.
+		afterRevoke bool
+		wantRevoked bool
+	}{
+		{name: "issued in the same second before revoke", issuedAt: -400 * time.Millisecond, wantRevoked: true},
+		{name: "issued at the revoke instant with the old version", issuedAt: 0, wantRevoked: true},
+		{name: "issued after revoke with a stale version", issuedAt: 300 * time.Millisecond, wantRevoked: true},
+		{name: "issued right after revoke in the same second", issuedAt: time.Microsecond, afterRevoke: true},
+		{name: "issued at the revoke instant with the new version", issuedAt: 0, afterRevoke: true},
+		{name: "issued one second after revoke", issuedAt: time.Second, afterRevoke: true},
+	}
+
+	for _, cacheTTL := range []time.Duration{0, 5 * time.Second} {
+		for _, tt := range tests {
+			t.Run(tt.name+"/cache "+cacheTTL.String(), func(t *testing.T) {
+				now := revokedAt
+				clock := func() time.Time { return now }
+				auth := newTestAuthService(t, clock)
+				revocation := NewTokenRevocation(newFakeRevocationRepo(1), cacheTTL)
+				revocation.(*tokenRevocation).now = clock
+
+				user := &models.User{ID: 1, Username: "alice"}
+				// Версию, прочитанную до отзыва, несет токен, который выпускается параллельно с ним.
+				staleUser := *user
+				// Проверка до отзыва кладет старую версию в кэш.
+				warmup, err := auth.GenerateAccessToken(user, "family-1")
+				if err != nil {
+					t.Fatalf("GenerateAccessToken: %v", err)
+				}
+				warmupClaims, err := auth.ValidateAccessToken(warmup)
+				if err != nil {
+					t.Fatalf("ValidateAccessToken: %v", err)
+				}
+				if err := revocation.Check(context.Background(), warmupClaims); err != nil {
+					t.Fatalf("Check before revoke: %v", err)
+				}
+
+				version, err := revocation.RevokeAllForUser(context.Background(), user.ID)
+				if err != nil {
+					t.Fatalf("RevokeAllForUser: %v", err)
+				}
+				user.TokenVersion = version
+
+				issuer := &staleUser
+				if tt.afterRevoke {
+					issuer = user
+				}
+				now = revokedAt.Add(tt.issuedAt)
+				token, err := auth.GenerateAccessToken(issuer, "family-1")
+				if err != nil {
+					t.Fatalf("GenerateAccessToken: %v", err)
+				}
+				claims, err := auth.ValidateAccessToken(token)
+				if err != nil {
+					t.Fatalf("ValidateAccessToken: %v", err)
+				}
+				if claims.IssuedAt.Time.Nanosecond() != 0 {
+					t.Fatalf("iat = %v, want whole seconds", claims.IssuedAt.Time)
+				}
+
+				err = revocation.Check(context.Background(), claims)
+				if tt.wantRevoked && !errors.Is(err, ErrTokenRevoked) {
+					t.Fatalf("Check = %v, want ErrTokenRevoked", err)
+				}
+				if !tt.wantRevoked && err != nil {
+					t.Fatalf("Check = %v, want nil", err)
+				}
+			})
+		}
+	}
+}
+
+func TestRevokeTokenByJTI(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := func() time.Time { return now }
+	auth := newTestAuthService(t, clock)
+	revocation := NewTokenRevocation(newFakeRevocationRepo(1), 5*time.Second)
+
+	user := &models.User{ID: 1, Username: "alice"}
+	revoked, _ := auth.GenerateAccessToken(user, "family-1")
+	kept, _ := auth.GenerateAccessToken(user, "family-1")
+	revokedClaims, err := auth.ValidateAccessToken(revoked)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken: %v", err)
+	}
+	keptClaims, err := auth.ValidateAccessToken(kept)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken: %v", err)
+	}
+
+	if err := revocation.RevokeToken(context.Background(), revokedClaims); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if err := revocation.Check(context.Background(), revokedClaims); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("Check(revoked) = %v, want ErrTokenRevoked", err)
+	}
+	if err := revocation.Check(context.Background(), keptClaims); err != nil {
+		t.Fatalf("Check(kept) = %v, want nil", err)
+	}
+}
+
+func TestCheckUnknownUserIsRevoked(t *testing.T) {
+	revocation := NewTokenRevocation(newFakeRevocationRepo(), 0)
+	claims := &AccessTokenClaims{UserID: 42}
+	claims.ID = "jti"
+
+	if err := revocation.Check(context.Background(), claims); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("Check = %v, want ErrTokenRevoked", err)
+	}
+}